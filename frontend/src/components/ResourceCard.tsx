@@ -71,7 +71,7 @@ export function ResourceCard({ resource, onAddToCart }: ResourceCardProps) {
             {resource.description}
           </p>
           <div className="flex items-center justify-between text-sm text-gray-500">
-            <span>Available: {resource.quantity}</span>
+            <span>Available: {resource.available_quantity}</span>
             <span>Max: {resource.max_per_team}/team</span>
           </div>
           {!resource.is_non_returnable && (
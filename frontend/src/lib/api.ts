@@ -25,6 +25,8 @@ export interface AdminProfile {
   id: number;
   username: string;
   email: string;
+  role: "super-admin" | "cashier" | "logistics" | "viewer";
+  permissions: string[]; // Admin routes the role allows, e.g. "purchases.approve"
 }
 
 // Team Composition interfaces
@@ -56,7 +58,9 @@ export interface Resource {
   name: string;
   description: string;
   cost: number;
-  quantity: number;
+  quantity: number; // Units in stock, including the ones reserved by pending purchases
+  reserved_quantity: number; // Units held by pending purchases
+  available_quantity: number; // Units that can still be ordered
   max_per_team: number;
   type: "service" | "matériel" | "avantage";
   image_url: string;
@@ -212,6 +216,65 @@ async function handleResponse<T>(response: Response): Promise<T> {
   return response.json();
 }
 
+// Purchase listings are paginated (limit/offset) with the total in X-Total-Count.
+// Fetch every page for the views that display the full list.
+const PURCHASE_PAGE_SIZE = 1000;
+
+async function fetchAllPurchasePages(
+  url: string,
+  params: URLSearchParams = new URLSearchParams()
+): Promise<Purchase[]> {
+  const purchases: Purchase[] = [];
+  for (;;) {
+    params.set("limit", PURCHASE_PAGE_SIZE.toString());
+    params.set("offset", purchases.length.toString());
+    const response = await fetch(`${url}?${params.toString()}`, {
+      headers: getAuthHeaders(),
+      credentials: "include",
+    });
+    const total = Number(response.headers.get("X-Total-Count"));
+    const page = await handleResponse<Purchase[]>(response);
+    purchases.push(...page);
+    if (page.length === 0 || !total || purchases.length >= total) {
+      return purchases;
+    }
+  }
+}
+
+// Refresh requested by authApi.tryRefreshToken, shared by its concurrent callers
+let refreshing: Promise<boolean> | null = null;
+
+async function refreshTokens(): Promise<boolean> {
+  const refreshToken = TokenManager.getRefreshToken();
+  if (!refreshToken) {
+    return false;
+  }
+
+  try {
+    const response = await fetch(`${API_BASE}/auth/refresh`, {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+      credentials: "include",
+    });
+
+    if (response.ok) {
+      const tokenData: TokenData = await response.json();
+      TokenManager.setTokens(tokenData);
+      return true;
+    } else {
+      // Refresh failed, clear tokens
+      TokenManager.clearTokens();
+      return false;
+    }
+  } catch {
+    TokenManager.clearTokens();
+    return false;
+  }
+}
+
 // Auth functions
 export const authApi = {
   // Check if user is authenticated
@@ -252,9 +315,9 @@ export const authApi = {
         if (data.token) {
           TokenManager.setTokens({
             access_token: data.token,
-            refresh_token: data.token,
-            token_type: "Bearer",
-            expires_in: 86400, // 24 hours
+            refresh_token: data.refresh_token,
+            token_type: data.token_type,
+            expires_in: data.expires_in,
           });
         }
         return { success: true, team: data.team, token: data.token };
@@ -285,36 +348,15 @@ export const authApi = {
     }
   },
 
-  // Try to refresh the access token
-  async tryRefreshToken(): Promise<boolean> {
-    const refreshToken = TokenManager.getRefreshToken();
-    if (!refreshToken) {
-      return false;
-    }
-
-    try {
-      const response = await fetch(`${API_BASE}/auth/refresh`, {
-        method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-        },
-        body: JSON.stringify({ refresh_token: refreshToken }),
-        credentials: "include",
+  // Try to refresh the access token. Each refresh token works once, so concurrent
+  // callers share the same request.
+  tryRefreshToken(): Promise<boolean> {
+    if (!refreshing) {
+      refreshing = refreshTokens().finally(() => {
+        refreshing = null;
       });
-
-      if (response.ok) {
-        //const tokenData: LoginResponse = await response.json();
-        //TokenManager.setTokens(tokenData);
-        return true;
-      } else {
-        // Refresh failed, clear tokens
-        TokenManager.clearTokens();
-        return false;
-      }
-    } catch {
-      TokenManager.clearTokens();
-      return false;
     }
+    return refreshing;
   },
 
   // Admin login
@@ -337,9 +379,9 @@ export const authApi = {
         if (data.token) {
           TokenManager.setTokens({
             access_token: data.token,
-            refresh_token: data.token,
-            token_type: "Bearer",
-            expires_in: 86400, // 24 hours
+            refresh_token: data.refresh_token,
+            token_type: data.token_type,
+            expires_in: data.expires_in,
           });
           // Store admin flag
           localStorage.setItem("is_admin", "true");
@@ -380,11 +422,7 @@ export const teamApi = {
 
   // Get team purchases
   async getPurchases(): Promise<Purchase[]> {
-    const response = await fetch(`${API_BASE}/team/purchases`, {
-      headers: getAuthHeaders(),
-      credentials: "include",
-    });
-    return handleResponse(response);
+    return fetchAllPurchasePages(`${API_BASE}/team/purchases`);
   },
 };
 
@@ -455,14 +493,9 @@ export const purchaseApi = {
 
   // Get team's purchases
   async getTeamPurchases(needsReturn?: boolean): Promise<Purchase[]> {
-    const url = needsReturn
-      ? `${API_BASE}/team/purchases?needs_return=true`
-      : `${API_BASE}/team/purchases`;
-    const response = await fetch(url, {
-      headers: getAuthHeaders(),
-      credentials: "include",
-    });
-    return handleResponse(response);
+    const params = new URLSearchParams();
+    if (needsReturn) params.append("needs_return", "true");
+    return fetchAllPurchasePages(`${API_BASE}/team/purchases`, params);
   },
 };
 
@@ -479,16 +512,7 @@ export const adminApi = {
     if (teamId) params.append("team_id", teamId.toString());
     if (needsReturn) params.append("needs_return", "true");
 
-    const url =
-      params.toString() === ""
-        ? `${API_BASE}/admin/purchases`
-        : `${API_BASE}/admin/purchases?${params.toString()}`;
-
-    const response = await fetch(url, {
-      headers: getAuthHeaders(),
-      credentials: "include",
-    });
-    return handleResponse(response);
+    return fetchAllPurchasePages(`${API_BASE}/admin/purchases`, params);
   },
 
   // Update purchase status (admin only)
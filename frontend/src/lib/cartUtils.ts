@@ -34,11 +34,11 @@ export async function validateAddToCart(
   }
 
   // Check if enough stock available
-  if (resource.quantity < quantityToAdd) {
+  if (resource.available_quantity < quantityToAdd) {
     return {
       success: false,
-      error: `Seulement ${resource.quantity} article(s) disponible(s)`,
-      maxQuantityAllowed: resource.quantity,
+      error: `Seulement ${resource.available_quantity} article(s) disponible(s)`,
+      maxQuantityAllowed: resource.available_quantity,
     };
   }
 
@@ -81,7 +81,7 @@ export async function validateAddToCart(
     }
 
     // Also check against available stock
-    const maxByStock = resource.quantity - quantityInCart;
+    const maxByStock = resource.available_quantity - quantityInCart;
     if (quantityToAdd > maxByStock) {
       return {
         success: false,
@@ -128,7 +128,7 @@ export async function getMaxQuantityAllowed(
 
     const remainingByQuota =
       resource.max_per_team - purchasedQuantity - quantityInCart;
-    const remainingByStock = resource.quantity - quantityInCart;
+    const remainingByStock = resource.available_quantity - quantityInCart;
 
     return Math.max(0, Math.min(remainingByQuota, remainingByStock));
   } catch (error) {
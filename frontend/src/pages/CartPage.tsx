@@ -231,7 +231,7 @@ export function CartPage() {
                                   )
                                 }
                                 disabled={
-                                  item.quantity >= item.resource.quantity ||
+                                  item.quantity >= item.resource.available_quantity ||
                                   item.quantity >= item.resource.max_per_team
                                 }
                                 className="p-1 hover:bg-gray-100 disabled:opacity-50"
@@ -208,7 +208,7 @@ export function ResourceDetailPage() {
 
               <div className="flex items-center justify-between py-3 border-b">
                 <span className="text-gray-600">Quantité disponible:</span>
-                <span className="font-semibold">{resource.quantity}</span>
+                <span className="font-semibold">{resource.available_quantity}</span>
               </div>
 
               <div className="flex items-center justify-between py-3 border-b">
@@ -225,7 +225,7 @@ export function ResourceDetailPage() {
               )}
             </div>
 
-            {resource.is_active && resource.quantity > 0 && (
+            {resource.is_active && resource.available_quantity > 0 && (
               <div className="space-y-4">
                 {/* Quantity Selector */}
                 <div className="flex items-center space-x-4">
@@ -299,7 +299,7 @@ export function ResourceDetailPage() {
               </div>
             )}
 
-            {resource.is_active && resource.quantity === 0 && (
+            {resource.is_active && resource.available_quantity === 0 && (
               <div className="bg-gray-50 rounded-lg p-4 text-center">
                 <p className="text-gray-600">
                   Cette ressource est en rupture de stock
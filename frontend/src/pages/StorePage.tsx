@@ -84,7 +84,7 @@ export function StorePage() {
   };
 
   const recommendations = resources
-    .filter((r) => r.is_active && r.quantity > 0)
+    .filter((r) => r.is_active && r.available_quantity > 0)
     .slice(0, 3);
 
   return (
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/ericp/ylab-hackathon/config"
+	"github.com/ericp/ylab-hackathon/importer"
+	"github.com/ericp/ylab-hackathon/migrations"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
+	"gorm.io/gorm"
+)
+
+// errDryRun is used to roll back the import transaction in dry-run mode
+var errDryRun = errors.New("dry run")
+
+var importers = map[string]func(tx *gorm.DB, r io.Reader, opts importer.Options) (*importer.Report, error){
+	"teams":     importer.ImportTeams,
+	"resources": importer.ImportResources,
+}
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Check the file without writing anything")
+	eventID := flag.Uint("event", 0, "ID of the event to import into (default the current event)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: import [--dry-run] [--event id] teams|resources <file.csv>\n\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Team columns: name, email, [password], [credit]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Resource columns: name, type, cost, quantity, max_per_team, [description], [image_url], [is_active], [is_non_returnable], [low_stock_threshold], [rental_minutes]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	run, ok := importers[flag.Arg(0)]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	config.LoadConfig()
+	config.ConnectDatabase()
+
+	if err := migrations.CheckSchema(config.DB); err != nil {
+		log.Fatalf("Database schema check failed: %v (run `migrate up` first)", err)
+	}
+
+	var event models.Event
+	if *eventID != 0 {
+		if err := config.DB.First(&event, *eventID).Error; err != nil {
+			log.Fatalf("Event %d not found", *eventID)
+		}
+	} else {
+		current, err := services.CurrentEvent(config.DB)
+		if err != nil {
+			log.Fatal("No current event, pass --event")
+		}
+		event = *current
+	}
+
+	// Everything runs in one transaction, rolled back in dry-run mode
+	var report *importer.Report
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if report, err = run(tx, file, importer.Options{Event: event}); err != nil {
+			return err
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if errors.Is(err, importer.ErrInvalidRows) {
+		for _, rowErr := range report.Errors {
+			fmt.Println(rowErr.String())
+		}
+		log.Fatalf("Import failed, nothing was written: %d invalid row(s)", len(report.Errors))
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Fatal("Import failed, nothing was written: ", err)
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d row(s) are valid, no change was written\n", report.Rows)
+		return
+	}
+
+	fmt.Printf("%d %s created in %s\n", report.Created, flag.Arg(0), event.Name)
+	for _, cred := range report.Credentials {
+		fmt.Printf("team %q (%s) password: %s\n", cred.Name, cred.Email, cred.Password)
+	}
+}
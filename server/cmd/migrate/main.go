@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/ericp/ylab-hackathon/config"
+	"github.com/ericp/ylab-hackathon/migrations"
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: migrate up        apply the pending migrations\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       migrate down [n]  revert the last n migrations (default 1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "       migrate status    list the migrations and whether they are applied\n")
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	steps := 1
+	switch flag.Arg(0) {
+	case "up", "status":
+		if flag.NArg() != 1 {
+			flag.Usage()
+			os.Exit(2)
+		}
+	case "down":
+		if flag.NArg() > 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if flag.NArg() == 2 {
+			n, err := strconv.Atoi(flag.Arg(1))
+			if err != nil || n < 1 {
+				log.Fatalf("Invalid number of migrations to revert: %q", flag.Arg(1))
+			}
+			steps = n
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config.LoadConfig()
+	config.ConnectDatabase()
+
+	migrator, err := migrations.New(config.DB)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	switch flag.Arg(0) {
+	case "up":
+		applied, err := migrator.Up()
+		for _, m := range applied {
+			fmt.Printf("applied   %s\n", m)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Database schema is at version %04d (%d migration(s) applied)\n", migrator.Latest(), len(applied))
+
+	case "down":
+		reverted, err := migrator.Down(steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted  %s\n", m)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d migration(s) reverted\n", len(reverted))
+
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			switch {
+			case s.Unknown:
+				fmt.Printf("unknown   %s  applied %s by a newer version\n", s.Migration, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			case s.AppliedAt != nil:
+				fmt.Printf("applied   %s  %s\n", s.Migration, s.AppliedAt.Format("2006-01-02 15:04:05"))
+			default:
+				fmt.Printf("pending   %s\n", s.Migration)
+			}
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"gorm.io/gorm"
+)
+
+type changeAction string
+
+const (
+	actionCreate    changeAction = "create"
+	actionUpdate    changeAction = "update"
+	actionUnchanged changeAction = "ok"
+)
+
+type fieldChange struct {
+	Field string
+	Old   interface{}
+	New   interface{}
+}
+
+// change describes what the seed does (or would do in dry-run mode) to one entity
+type change struct {
+	Kind    string
+	Key     string
+	Action  changeAction
+	Fields  []fieldChange
+	Notes   []string
+	Secrets []string // Generated passwords, only printed on a real run
+}
+
+func (ch *change) diff(field string, old, new interface{}) bool {
+	if reflect.DeepEqual(old, new) {
+		return false
+	}
+	ch.Fields = append(ch.Fields, fieldChange{Field: field, Old: old, New: new})
+	return true
+}
+
+func (ch *change) String() string {
+	line := fmt.Sprintf("[%-6s] %s %q", ch.Action, ch.Kind, ch.Key)
+	parts := make([]string, 0, len(ch.Fields)+len(ch.Notes))
+	for _, f := range ch.Fields {
+		if ch.Action == actionCreate {
+			parts = append(parts, fmt.Sprintf("%s=%v", f.Field, f.New))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s: %v -> %v", f.Field, f.Old, f.New))
+		}
+	}
+	parts = append(parts, ch.Notes...)
+	if len(parts) > 0 {
+		line += ": " + strings.Join(parts, ", ")
+	}
+	return line
+}
+
+// seeder upserts an event definition using natural keys (username, name, question)
+type seeder struct {
+	tx      *gorm.DB
+	event   models.Event // Event of the teams, resources, polls and team compositions
+	changes []*change
+}
+
+func (s *seeder) record(ch *change) {
+	if ch.Action == "" {
+		if len(ch.Fields) > 0 {
+			ch.Action = actionUpdate
+		} else {
+			ch.Action = actionUnchanged
+		}
+	}
+	s.changes = append(s.changes, ch)
+}
+
+func (s *seeder) apply(def *EventDefinition) error {
+	steps := []func(*EventDefinition) error{
+		s.seedEvent,
+		s.seedAdmins,
+		s.seedTeams,
+		s.seedResources,
+		s.seedPolls,
+		s.seedTeamCompositions,
+	}
+	for _, step := range steps {
+		if err := step(def); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedEvent upserts the event of the file, or picks the current one when the file
+// has none
+func (s *seeder) seedEvent(def *EventDefinition) error {
+	if def.Edition == nil {
+		if err := s.tx.Where("is_current = ?", true).First(&s.event).Error; err != nil {
+			return errors.New("the file has no event and there is no current event")
+		}
+		return nil
+	}
+
+	d := def.Edition
+	want := d.model()
+	ch := &change{Kind: "event", Key: want.Name}
+
+	err := s.tx.Where("name = ?", want.Name).First(&s.event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.event = want
+		if err := s.tx.Create(&s.event).Error; err != nil {
+			return fmt.Errorf("event %q: %w", want.Name, err)
+		}
+		// GORM skips zero values on create and would keep the column default
+		if want.StartingCredit == 0 {
+			if err := s.tx.Model(&s.event).Update("starting_credit", 0).Error; err != nil {
+				return err
+			}
+		}
+		ch.Action = actionCreate
+		ch.diff("starts_at", nil, want.StartsAt)
+		ch.diff("ends_at", nil, want.EndsAt)
+		ch.diff("starting_credit", nil, want.StartingCredit)
+	} else if err != nil {
+		return err
+	} else {
+		if !s.event.StartsAt.Equal(want.StartsAt) {
+			ch.diff("starts_at", s.event.StartsAt, want.StartsAt)
+			s.event.StartsAt = want.StartsAt
+		}
+		if !s.event.EndsAt.Equal(want.EndsAt) {
+			ch.diff("ends_at", s.event.EndsAt, want.EndsAt)
+			s.event.EndsAt = want.EndsAt
+		}
+		if d.StartingCredit != nil && ch.diff("starting_credit", s.event.StartingCredit, want.StartingCredit) {
+			s.event.StartingCredit = want.StartingCredit
+		}
+		if ch.diff("logo_url", s.event.LogoURL, want.LogoURL) {
+			s.event.LogoURL = want.LogoURL
+		}
+		if ch.diff("primary_color", s.event.PrimaryColor, want.PrimaryColor) {
+			s.event.PrimaryColor = want.PrimaryColor
+		}
+		if len(ch.Fields) > 0 {
+			if err := s.tx.Omit("is_current").Save(&s.event).Error; err != nil {
+				return fmt.Errorf("event %q: %w", want.Name, err)
+			}
+		}
+	}
+
+	if d.Current && !s.event.IsCurrent {
+		if err := s.tx.Model(&models.Event{}).Where("is_current = ?", true).Update("is_current", false).Error; err != nil {
+			return err
+		}
+		if err := s.tx.Model(&s.event).Update("is_current", true).Error; err != nil {
+			return err
+		}
+		ch.diff("is_current", false, true)
+	}
+	s.record(ch)
+	return nil
+}
+
+func (s *seeder) seedAdmins(def *EventDefinition) error {
+	for _, d := range def.Admins {
+		ch := &change{Kind: "admin", Key: d.Username}
+		email := utils.NormalizeEmail(d.Email)
+
+		var admin models.Admin
+		err := s.tx.Where("username = ?", d.Username).First(&admin).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if d.Password == "" {
+				return fmt.Errorf("admin %q: a password is required to create it", d.Username)
+			}
+			hash, err := utils.HashPassword(d.Password)
+			if err != nil {
+				return err
+			}
+			role := models.AdminRole(d.Role)
+			if role == "" {
+				role = models.RoleSuperAdmin
+			}
+			admin = models.Admin{Username: d.Username, Email: email, PasswordHash: hash, Role: role}
+			if err := s.tx.Create(&admin).Error; err != nil {
+				return fmt.Errorf("admin %q: %w", d.Username, err)
+			}
+			ch.Action = actionCreate
+			ch.diff("email", nil, email)
+			ch.diff("role", nil, role)
+			s.record(ch)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		ch.diff("email", admin.Email, email)
+		admin.Email = email
+		if d.Role != "" {
+			ch.diff("role", admin.Role, models.AdminRole(d.Role))
+			admin.Role = models.AdminRole(d.Role)
+		}
+		if d.Password != "" && !utils.CheckPasswordHash(d.Password, admin.PasswordHash) {
+			hash, err := utils.HashPassword(d.Password)
+			if err != nil {
+				return err
+			}
+			admin.PasswordHash = hash
+			ch.Fields = append(ch.Fields, fieldChange{Field: "password", Old: "***", New: "***"})
+		}
+		if len(ch.Fields) > 0 {
+			if err := s.tx.Save(&admin).Error; err != nil {
+				return fmt.Errorf("admin %q: %w", d.Username, err)
+			}
+		}
+		s.record(ch)
+	}
+	return nil
+}
+
+func (s *seeder) seedTeams(def *EventDefinition) error {
+	for _, d := range def.Teams {
+		ch := &change{Kind: "team", Key: d.Name}
+		email := utils.NormalizeEmail(d.Email)
+
+		var team models.Team
+		err := s.tx.Where("event_id = ? AND name = ?", s.event.ID, d.Name).First(&team).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			password := d.Password
+			if password == "" {
+				if password, err = utils.GeneratePassword(12); err != nil {
+					return err
+				}
+				ch.Secrets = append(ch.Secrets, fmt.Sprintf("team %q password: %s", d.Name, password))
+			}
+			hash, err := utils.HashPassword(password)
+			if err != nil {
+				return err
+			}
+
+			credit := s.event.StartingCredit
+			if d.Credit != nil {
+				credit = *d.Credit
+			}
+
+			team = models.Team{EventID: s.event.ID, Name: d.Name, Email: email, PasswordHash: hash, Credit: credit}
+			if err := s.tx.Create(&team).Error; err != nil {
+				return fmt.Errorf("team %q: %w", d.Name, err)
+			}
+			// GORM skips zero values on create and would keep the column default
+			if credit == 0 {
+				if err := s.tx.Model(&team).Update("credit", 0).Error; err != nil {
+					return err
+				}
+			}
+			opening := models.CreditTransaction{
+				TeamID:       team.ID,
+				Amount:       credit,
+				BalanceAfter: credit,
+				Reason:       models.CreditReasonOpening,
+			}
+			if err := s.tx.Create(&opening).Error; err != nil {
+				return err
+			}
+
+			ch.Action = actionCreate
+			ch.diff("email", nil, email)
+			ch.diff("credit", nil, credit)
+			s.record(ch)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if ch.diff("email", team.Email, email) {
+			if err := s.tx.Model(&team).Update("email", email).Error; err != nil {
+				return fmt.Errorf("team %q: %w", d.Name, err)
+			}
+		}
+		if d.Password != "" && !utils.CheckPasswordHash(d.Password, team.PasswordHash) {
+			hash, err := utils.HashPassword(d.Password)
+			if err != nil {
+				return err
+			}
+			if err := s.tx.Model(&team).Update("password_hash", hash).Error; err != nil {
+				return err
+			}
+			ch.Fields = append(ch.Fields, fieldChange{Field: "password", Old: "***", New: "***"})
+		}
+		// The starting credit is never re-applied: the balance now lives in the ledger
+		if d.Credit != nil && *d.Credit != team.Credit {
+			ch.Notes = append(ch.Notes, fmt.Sprintf("credit left at %d (starting credit %d only applies on creation)", team.Credit, *d.Credit))
+		}
+		s.record(ch)
+	}
+	return nil
+}
+
+func (s *seeder) seedResources(def *EventDefinition) error {
+	for _, d := range def.Resources {
+		ch := &change{Kind: "resource", Key: d.Name}
+		isActive := true
+		if d.IsActive != nil {
+			isActive = *d.IsActive
+		}
+
+		var resource models.Resource
+		err := s.tx.Where("event_id = ? AND name = ?", s.event.ID, d.Name).First(&resource).Error
+		created := errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && !created {
+			return err
+		}
+		if created {
+			ch.Action = actionCreate
+		}
+
+		ch.diff("description", resource.Description, d.Description)
+		ch.diff("cost", resource.Cost, d.Cost)
+		ch.diff("quantity", resource.Quantity, d.Quantity)
+		ch.diff("max_per_team", resource.MaxPerTeam, d.MaxPerTeam)
+		ch.diff("type", resource.Type, d.Type)
+		ch.diff("image_url", resource.ImageURL, d.ImageURL)
+		ch.diff("is_active", resource.IsActive, isActive)
+		ch.diff("is_non_returnable", resource.IsNonReturnable, d.IsNonReturnable)
+		ch.diff("low_stock_threshold", resource.LowStockThreshold, d.LowStockThreshold)
+		ch.diff("rental_minutes", resource.RentalMinutes, d.RentalMinutes)
+
+		resource.EventID = s.event.ID
+		resource.Name = d.Name
+		resource.Description = d.Description
+		resource.Cost = d.Cost
+		resource.Quantity = d.Quantity
+		resource.MaxPerTeam = d.MaxPerTeam
+		resource.Type = d.Type
+		resource.ImageURL = d.ImageURL
+		resource.IsActive = isActive
+		resource.IsNonReturnable = d.IsNonReturnable
+		resource.LowStockThreshold = d.LowStockThreshold
+		resource.RentalMinutes = d.RentalMinutes
+
+		if created || len(ch.Fields) > 0 {
+			if err := s.tx.Omit("reserved").Save(&resource).Error; err != nil {
+				return fmt.Errorf("resource %q: %w", d.Name, err)
+			}
+			// GORM skips false booleans on create and would keep the column default (true)
+			if created && !isActive {
+				if err := s.tx.Model(&resource).Update("is_active", false).Error; err != nil {
+					return err
+				}
+			}
+		}
+		s.record(ch)
+	}
+	return nil
+}
+
+func (s *seeder) seedPolls(def *EventDefinition) error {
+	for _, d := range def.Polls {
+		ch := &change{Kind: "poll", Key: d.Question}
+
+		var poll models.Poll
+		err := s.tx.Where("event_id = ? AND question = ?", s.event.ID, d.Question).First(&poll).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			poll = models.Poll{
+				EventID:   s.event.ID,
+				Question:  d.Question,
+				Options:   d.Options,
+				StartDate: d.StartDate,
+				EndDate:   d.EndDate,
+				Status:    models.PollStatusOpen,
+			}
+			if err := s.tx.Create(&poll).Error; err != nil {
+				return fmt.Errorf("poll %q: %w", d.Question, err)
+			}
+			ch.Action = actionCreate
+			ch.diff("options", nil, d.Options)
+			ch.diff("start_date", nil, d.StartDate)
+			ch.diff("end_date", nil, d.EndDate)
+			s.record(ch)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !reflect.DeepEqual([]string(poll.Options), d.Options) {
+			var voteCount int64
+			if err := s.tx.Model(&models.Vote{}).Where("poll_id = ?", poll.ID).Count(&voteCount).Error; err != nil {
+				return err
+			}
+			if voteCount > 0 {
+				ch.Notes = append(ch.Notes, "options left unchanged because the poll already has votes")
+			} else {
+				ch.diff("options", []string(poll.Options), d.Options)
+				poll.Options = d.Options
+			}
+		}
+		if !poll.StartDate.Equal(d.StartDate) {
+			ch.diff("start_date", poll.StartDate, d.StartDate)
+			poll.StartDate = d.StartDate
+		}
+		if !poll.EndDate.Equal(d.EndDate) {
+			ch.diff("end_date", poll.EndDate, d.EndDate)
+			poll.EndDate = d.EndDate
+		}
+
+		if len(ch.Fields) > 0 {
+			if err := s.tx.Save(&poll).Error; err != nil {
+				return fmt.Errorf("poll %q: %w", d.Question, err)
+			}
+		}
+		s.record(ch)
+	}
+	return nil
+}
+
+func (s *seeder) seedTeamCompositions(def *EventDefinition) error {
+	for _, d := range def.TeamCompositions {
+		ch := &change{Kind: "team composition", Key: d.Name}
+
+		var composition models.TeamComposition
+		err := s.tx.Where("event_id = ? AND name = ?", s.event.ID, d.Name).First(&composition).Error
+		created := errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && !created {
+			return err
+		}
+		if created {
+			ch.Action = actionCreate
+		}
+
+		ch.diff("dev_total", composition.DevTotal, d.DevTotal)
+		ch.diff("infra_total", composition.InfraTotal, d.InfraTotal)
+		ch.diff("data_total", composition.DataTotal, d.DataTotal)
+		ch.diff("iot_total", composition.IoTTotal, d.IoTTotal)
+		ch.diff("sysemb_total", composition.SysembTotal, d.SysembTotal)
+
+		composition.EventID = s.event.ID
+		composition.Name = d.Name
+		composition.DevTotal = d.DevTotal
+		composition.InfraTotal = d.InfraTotal
+		composition.DataTotal = d.DataTotal
+		composition.IoTTotal = d.IoTTotal
+		composition.SysembTotal = d.SysembTotal
+
+		// Filled slots cannot exceed the new capacity
+		composition.DevFilled = min(composition.DevFilled, composition.DevTotal)
+		composition.InfraFilled = min(composition.InfraFilled, composition.InfraTotal)
+		composition.DataFilled = min(composition.DataFilled, composition.DataTotal)
+		composition.IoTFilled = min(composition.IoTFilled, composition.IoTTotal)
+		composition.SysembFilled = min(composition.SysembFilled, composition.SysembTotal)
+
+		if created || len(ch.Fields) > 0 {
+			if err := s.tx.Save(&composition).Error; err != nil {
+				return fmt.Errorf("team composition %q: %w", d.Name, err)
+			}
+		}
+		s.record(ch)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"go.yaml.in/yaml/v3"
+)
+
+// EventDefinition describes everything needed to set up a hackathon edition
+type EventDefinition struct {
+	Edition          *EditionDefinition          `json:"event" yaml:"event"` // The current event when omitted
+	Admins           []AdminDefinition           `json:"admins" yaml:"admins"`
+	Teams            []TeamDefinition            `json:"teams" yaml:"teams"`
+	Resources        []ResourceDefinition        `json:"resources" yaml:"resources"`
+	Polls            []PollDefinition            `json:"polls" yaml:"polls"`
+	TeamCompositions []TeamCompositionDefinition `json:"team_compositions" yaml:"team_compositions"`
+}
+
+// EditionDefinition is the event the teams, resources, polls and team compositions
+// of the file belong to. Admins are shared by every event.
+type EditionDefinition struct {
+	Name           string    `json:"name" yaml:"name"`
+	StartsAt       time.Time `json:"starts_at" yaml:"starts_at"`
+	EndsAt         time.Time `json:"ends_at" yaml:"ends_at"`
+	StartingCredit *int      `json:"starting_credit" yaml:"starting_credit"` // Default credit of the new teams
+	LogoURL        string    `json:"logo_url" yaml:"logo_url"`
+	PrimaryColor   string    `json:"primary_color" yaml:"primary_color"`
+	Current        bool      `json:"current" yaml:"current"` // Makes it the current event, never unsets it
+}
+
+type AdminDefinition struct {
+	Username string `json:"username" yaml:"username"`
+	Email    string `json:"email" yaml:"email"`
+	Password string `json:"password" yaml:"password"`
+	Role     string `json:"role" yaml:"role"` // super-admin when created without one, unchanged otherwise
+}
+
+type TeamDefinition struct {
+	Name     string `json:"name" yaml:"name"`
+	Email    string `json:"email" yaml:"email"`
+	Password string `json:"password" yaml:"password"` // Generated when empty
+	Credit   *int   `json:"credit" yaml:"credit"`     // Starting credit, only applied when the team is created
+}
+
+type ResourceDefinition struct {
+	Name              string `json:"name" yaml:"name"`
+	Description       string `json:"description" yaml:"description"`
+	Cost              int    `json:"cost" yaml:"cost"`
+	Quantity          int    `json:"quantity" yaml:"quantity"`
+	MaxPerTeam        int    `json:"max_per_team" yaml:"max_per_team"`
+	Type              string `json:"type" yaml:"type"`
+	ImageURL          string `json:"image_url" yaml:"image_url"`
+	IsActive          *bool  `json:"is_active" yaml:"is_active"`
+	IsNonReturnable   bool   `json:"is_non_returnable" yaml:"is_non_returnable"`
+	LowStockThreshold int    `json:"low_stock_threshold" yaml:"low_stock_threshold"`
+	RentalMinutes     int    `json:"rental_minutes" yaml:"rental_minutes"` // Until the end of the event when 0
+}
+
+type PollDefinition struct {
+	Question  string    `json:"question" yaml:"question"`
+	Options   []string  `json:"options" yaml:"options"`
+	StartDate time.Time `json:"start_date" yaml:"start_date"`
+	EndDate   time.Time `json:"end_date" yaml:"end_date"`
+}
+
+type TeamCompositionDefinition struct {
+	Name        string `json:"name" yaml:"name"`
+	DevTotal    int    `json:"dev_total" yaml:"dev_total"`
+	InfraTotal  int    `json:"infra_total" yaml:"infra_total"`
+	DataTotal   int    `json:"data_total" yaml:"data_total"`
+	IoTTotal    int    `json:"iot_total" yaml:"iot_total"`
+	SysembTotal int    `json:"sysemb_total" yaml:"sysemb_total"`
+}
+
+// loadEventDefinition reads a YAML or JSON event file, based on its extension
+func loadEventDefinition(path string) (*EventDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var def EventDefinition
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &def)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &def)
+	default:
+		return nil, fmt.Errorf("unsupported event file extension %q (expected .yaml, .yml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &def, nil
+}
+
+// validate checks that every entry has its natural key and that keys are unique
+func (def *EventDefinition) validate() error {
+	seen := map[string]bool{}
+	check := func(kind, key string) error {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("%s without name", kind)
+		}
+		if seen[kind+"/"+key] {
+			return fmt.Errorf("duplicate %s %q", kind, key)
+		}
+		seen[kind+"/"+key] = true
+		return nil
+	}
+
+	if def.Edition != nil {
+		event := def.Edition.model()
+		if msg := event.Validate(); msg != "" {
+			return fmt.Errorf("event %q: %s", def.Edition.Name, msg)
+		}
+	}
+	for _, a := range def.Admins {
+		if err := check("admin", a.Username); err != nil {
+			return err
+		}
+		if a.Role != "" && !models.IsValidAdminRole(a.Role) {
+			return fmt.Errorf("admin %q has an invalid role %q", a.Username, a.Role)
+		}
+	}
+	for _, t := range def.Teams {
+		if err := check("team", t.Name); err != nil {
+			return err
+		}
+		if !utils.IsValidEmail(utils.NormalizeEmail(t.Email)) {
+			return fmt.Errorf("team %q has an invalid email", t.Name)
+		}
+	}
+	for _, r := range def.Resources {
+		if err := check("resource", r.Name); err != nil {
+			return err
+		}
+		if !models.IsValidResourceType(r.Type) {
+			return fmt.Errorf("resource %q has an invalid type %q", r.Name, r.Type)
+		}
+		if r.Cost < 0 || r.Quantity < 0 || r.MaxPerTeam < 1 {
+			return fmt.Errorf("resource %q needs a positive cost and quantity and a max per team of at least 1", r.Name)
+		}
+		if r.LowStockThreshold < 0 {
+			return fmt.Errorf("resource %q needs a positive low stock threshold", r.Name)
+		}
+		if r.RentalMinutes < 0 {
+			return fmt.Errorf("resource %q needs a positive rental duration", r.Name)
+		}
+	}
+	for _, p := range def.Polls {
+		if err := check("poll", p.Question); err != nil {
+			return err
+		}
+		if len(p.Options) < 2 {
+			return fmt.Errorf("poll %q needs at least two options", p.Question)
+		}
+		if !p.EndDate.After(p.StartDate) {
+			return fmt.Errorf("poll %q ends before it starts", p.Question)
+		}
+	}
+	for _, tc := range def.TeamCompositions {
+		if err := check("team composition", tc.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// model returns the event described by the definition
+func (d *EditionDefinition) model() models.Event {
+	event := models.Event{
+		Name:           strings.TrimSpace(d.Name),
+		StartsAt:       d.StartsAt,
+		EndsAt:         d.EndsAt,
+		StartingCredit: models.DefaultTeamCredit,
+		LogoURL:        d.LogoURL,
+		PrimaryColor:   d.PrimaryColor,
+	}
+	if d.StartingCredit != nil {
+		event.StartingCredit = *d.StartingCredit
+	}
+	return event
+}
@@ -1,26 +1,94 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/ericp/ylab-hackathon/config"
+	"github.com/ericp/ylab-hackathon/migrations"
 	"github.com/ericp/ylab-hackathon/utils"
+	"gorm.io/gorm"
 )
 
+// errDryRun is used to roll back the seed transaction in dry-run mode
+var errDryRun = errors.New("dry run")
+
 func main() {
-	// Create admin user
-	adminPassword, _ := utils.HashPassword("HackathonM1:)")
-	log.Printf("Admin password: %s\n", "HackathonM1:)")
-	log.Printf("Admin hashed password: %s\n", adminPassword)
-
-	// Team password prefix
-	var teamPasswordPrefix = "HackathonTeam"
-
-	// create new index int var
-	var index int = 1
-	for i := 0; i < 1; i++ {
-		teamName := "Team" + string(rune('A'+i))
-		teamPassword, _ := utils.HashPassword(teamPasswordPrefix + string(rune('A'+i)))
-		log.Printf("Team: %s, Password: %s, Hashed Password: %s\n", teamName, teamPasswordPrefix+string(rune('A'+i)), teamPassword)
-		index++
+	file := flag.String("file", "", "Event definition file (.yaml, .yml or .json)")
+	dryRun := flag.Bool("dry-run", false, "Show the changes without writing them")
+	hash := flag.String("hash", "", "Only print the bcrypt hash of the given password")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: seed [--dry-run] --file event.yaml\n       seed --hash <password>\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *hash != "" {
+		hashed, err := utils.HashPassword(*hash)
+		if err != nil {
+			log.Fatal("Failed to hash password:", err)
+		}
+		fmt.Println(hashed)
+		return
+	}
+
+	if *file == "" && flag.NArg() == 1 {
+		*file = flag.Arg(0)
+	}
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	def, err := loadEventDefinition(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := def.validate(); err != nil {
+		log.Fatal("Invalid event definition: ", err)
+	}
+
+	config.LoadConfig()
+	config.ConnectDatabase()
+
+	if err := migrations.CheckSchema(config.DB); err != nil {
+		log.Fatalf("Database schema check failed: %v (run `migrate up` first)", err)
+	}
+
+	// Everything runs in one transaction, rolled back in dry-run mode
+	s := &seeder{}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		s.tx = tx
+		if err := s.apply(def); err != nil {
+			return err
+		}
+		if *dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		log.Fatal("Seed failed, nothing was written: ", err)
+	}
+
+	counts := map[changeAction]int{}
+	for _, ch := range s.changes {
+		fmt.Println(ch.String())
+		counts[ch.Action]++
+	}
+	fmt.Printf("\n%d to create, %d to update, %d unchanged\n", counts[actionCreate], counts[actionUpdate], counts[actionUnchanged])
+
+	if *dryRun {
+		fmt.Println("Dry run: no change was written")
+		return
+	}
+
+	for _, ch := range s.changes {
+		for _, secret := range ch.Secrets {
+			fmt.Println(secret)
+		}
 	}
 }
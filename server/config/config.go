@@ -3,23 +3,27 @@ package config
 import (
 	"log"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DBUser     string
-	DBPassword string
-	DBHost     string
-	DBPort     string
-	DBName     string
-	JWTSecret  string
-	ServerPort string
-	SMTPHost   string
-	SMTPPort   string
-	SMTPUser   string
-	SMTPPass   string
-	SMTPFrom   string
+	DBUser         string
+	DBPassword     string
+	DBHost         string
+	DBPort         string
+	DBName         string
+	JWTSecret      string
+	ServerPort     string
+	SMTPHost       string
+	SMTPPort       string
+	SMTPUser       string
+	SMTPPass       string
+	SMTPFrom       string
+	EventName      string   // Shown in the emails
+	PublicURL      string   // Address of the app, used in the links of the emails
+	TrustedProxies []string // Proxies allowed to pass the client address in X-Forwarded-For
 }
 
 var AppConfig *Config
@@ -31,21 +35,35 @@ func LoadConfig() {
 	}
 
 	AppConfig = &Config{
-		DBUser:     getEnv("DB_USER", "admin"),
-		DBPassword: getEnv("DB_PASSWORD", "securepassword123"),
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBName:     getEnv("DB_NAME", "ylab_hackathon"),
-		JWTSecret:  getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		SMTPHost:   getEnv("SMTP_HOST", "smtp.gmail.com"),
-		SMTPPort:   getEnv("SMTP_PORT", "587"),
-		SMTPUser:   getEnv("SMTP_USER", ""),
-		SMTPPass:   getEnv("SMTP_PASS", ""),
-		SMTPFrom:   getEnv("SMTP_FROM", "noreply@ylabhackathon.com"),
+		DBUser:         getEnv("DB_USER", "admin"),
+		DBPassword:     getEnv("DB_PASSWORD", "securepassword123"),
+		DBHost:         getEnv("DB_HOST", "localhost"),
+		DBPort:         getEnv("DB_PORT", "5432"),
+		DBName:         getEnv("DB_NAME", "ylab_hackathon"),
+		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key-change-this-in-production"),
+		ServerPort:     getEnv("SERVER_PORT", "8080"),
+		SMTPHost:       getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:       getEnv("SMTP_PORT", "587"),
+		SMTPUser:       getEnv("SMTP_USER", ""),
+		SMTPPass:       getEnv("SMTP_PASS", ""),
+		SMTPFrom:       getEnv("SMTP_FROM", "noreply@ylabhackathon.com"),
+		EventName:      getEnv("EVENT_NAME", "YLab Hackathon 2025"),
+		PublicURL:      strings.TrimRight(getEnv("PUBLIC_URL", "http://localhost:8080"), "/"),
+		TrustedProxies: splitList(getEnv("TRUSTED_PROXIES", "127.0.0.0/8,::1,10.0.0.0/8,172.16.0.0/12,192.168.0.0/16")),
 	}
 }
 
+// splitList reads a comma-separated setting
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAdmins godoc
+// @Summary Liste des admins (Admin)
+// @Description Récupère les comptes admin avec leur rôle : super-admin (tout), cashier (valide les achats), logistics (retours et places des équipes) ou viewer (lecture seule) (super-admin uniquement)
+// @Tags Admins
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Admin "Admins"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Permission insuffisante"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/admins [get]
+func (h *Handler) GetAdmins(c *gin.Context) {
+	var admins []models.Admin
+	if err := h.db.Order("username").Find(&admins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch admins"})
+		return
+	}
+
+	c.JSON(http.StatusOK, admins)
+}
+
+// UpdateAdminRole godoc
+// @Summary Changer le rôle d'un admin (Admin)
+// @Description Change le rôle d'un admin. Ses sessions sont fermées pour que le nouveau rôle s'applique immédiatement. Le dernier super-admin ne peut pas être rétrogradé (super-admin uniquement)
+// @Tags Admins
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'admin"
+// @Param role body models.UpdateAdminRoleRequest true "Nouveau rôle (super-admin, cashier, logistics ou viewer)"
+// @Success 200 {object} models.Admin "Admin mis à jour"
+// @Failure 400 {object} map[string]string "Rôle invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Permission insuffisante"
+// @Failure 404 {object} map[string]string "Admin introuvable"
+// @Failure 409 {object} map[string]string "Dernier super-admin"
+// @Router /api/admin/admins/{id}/role [put]
+func (h *Handler) UpdateAdminRole(c *gin.Context) {
+	var req models.UpdateAdminRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	admin, err := h.services.Admins.UpdateRole(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, admin)
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuditLog godoc
+// @Summary Journal des actions admin (Admin)
+// @Description Récupère les actions effectuées par les administrateurs, des plus récentes aux plus anciennes. Le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param admin_id query int false "Filtrer par administrateur"
+// @Param action query string false "Filtrer par action (ex: purchase.confirm)"
+// @Param entity_type query string false "Filtrer par type d'entité" Enums(purchase, team_composition, resource, poll, team)
+// @Param entity_id query int false "Filtrer par entité"
+// @Param from query string false "Date de début (RFC3339)"
+// @Param to query string false "Date de fin (RFC3339)"
+// @Param limit query int false "Nombre maximum de résultats (défaut 100, max 1000)"
+// @Param offset query int false "Nombre de résultats à ignorer"
+// @Success 200 {array} models.AuditEntry "Entrées du journal"
+// @Failure 400 {object} map[string]string "Filtre invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/audit [get]
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	query := h.db.Model(&models.AuditEntry{})
+
+	if adminID := c.Query("admin_id"); adminID != "" {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if entityType := c.Query("entity_type"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityID := c.Query("entity_id"); entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date (expected RFC3339)"})
+			return
+		}
+		query = query.Where("created_at >= ?", fromDate)
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date (expected RFC3339)"})
+			return
+		}
+		query = query.Where("created_at <= ?", toDate)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-1000)"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	var entries []models.AuditEntry
+	if err := query.Preload("Admin").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit log"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, entries)
+}
@@ -6,69 +6,91 @@ import (
 
 	"github.com/ericp/ylab-hackathon/config"
 	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
 	"github.com/ericp/ylab-hackathon/utils"
 	"github.com/gin-gonic/gin"
 )
 
 // TeamLogin godoc
 // @Summary Connexion équipe
-// @Description Authentification d'une équipe avec nom et mot de passe
+// @Description Authentification d'une équipe de l'événement en cours avec nom et mot de passe. Après 3 échecs, les tentatives suivantes sont espacées ; après 10, le compte est bloqué 15 minutes, comme une adresse IP après 50 échecs
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body models.TeamLoginRequest true "Identifiants de connexion"
-// @Success 200 {object} map[string]interface{} "Token JWT et informations de l'équipe"
+// @Success 200 {object} map[string]interface{} "Token JWT, refresh token et informations de l'équipe"
 // @Failure 400 {object} map[string]string "Requête invalide"
 // @Failure 401 {object} map[string]string "Identifiants invalides"
+// @Failure 429 {object} map[string]interface{} "Trop de tentatives, réessayer après retry_after secondes"
 // @Router /api/auth/team/login [post]
-func TeamLogin(c *gin.Context) {
+func (h *Handler) TeamLogin(c *gin.Context) {
 	var req models.TeamLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Only the teams of the current event can log in, past editions are archived
 	var team models.Team
-	if err := config.DB.Where("name = ?", req.Name).First(&team).Error; err != nil {
-		if err = config.DB.Where("email = ?", req.Name).First(&team).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
-			return
-		}
+	found := false
+	if event, err := services.CurrentEvent(h.db); err == nil {
+		found = h.db.Where("event_id = ? AND name = ?", event.ID, req.Name).First(&team).Error == nil ||
+			h.db.Where("event_id = ? AND email = ?", event.ID, req.Name).First(&team).Error == nil
 	}
 
-	if !utils.CheckPasswordHash(req.Password, team.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	attempt := services.LoginAttempt{UserType: "team", Identifier: req.Name, IPAddress: c.ClientIP()}
+	if found {
+		attempt.UserID = team.ID
+	}
+	if err := h.services.Logins.Check(attempt); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Update last activity
-	team.LastActivity = time.Now()
-	config.DB.Save(&team)
+	if !found || !utils.CheckPasswordHash(req.Password, team.PasswordHash) {
+		h.refuseLogin(c, attempt)
+		return
+	}
+	if err := h.services.Logins.Succeed(attempt); err != nil {
+		respondError(c, err)
+		return
+	}
 
-	token, err := utils.GenerateToken(team.ID, "team", config.AppConfig.JWTSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	if team.IsSuspended {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Team is suspended"})
+		return
+	}
+
+	// Update last activity only, the rest of the row may have changed since it was read
+	h.db.Model(&team).Update("last_activity", time.Now())
+
+	tokens, ok := h.startSession(c, "team", team.ID, "")
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"team":  team.ToResponse(),
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"team":          team.ToResponse(),
 	})
 }
 
 // AdminLogin godoc
 // @Summary Connexion administrateur
-// @Description Authentification d'un administrateur avec username et mot de passe
+// @Description Authentification d'un administrateur avec username et mot de passe, limitée comme celle des équipes
 // @Tags Authentication
 // @Accept json
 // @Produce json
 // @Param credentials body models.AdminLoginRequest true "Identifiants de connexion admin"
-// @Success 200 {object} map[string]interface{} "Token JWT et informations de l'admin"
+// @Success 200 {object} map[string]interface{} "Token JWT, refresh token et informations de l'admin"
 // @Failure 400 {object} map[string]string "Requête invalide"
 // @Failure 401 {object} map[string]string "Identifiants invalides"
+// @Failure 429 {object} map[string]interface{} "Trop de tentatives, réessayer après retry_after secondes"
 // @Router /api/auth/admin/login [post]
-func AdminLogin(c *gin.Context) {
+func (h *Handler) AdminLogin(c *gin.Context) {
 	var req models.AdminLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -76,42 +98,180 @@ func AdminLogin(c *gin.Context) {
 	}
 
 	var admin models.Admin
-	if err := config.DB.Where("username = ?", req.Username).First(&admin).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	found := h.db.Where("username = ?", req.Username).First(&admin).Error == nil
+
+	attempt := services.LoginAttempt{UserType: "admin", Identifier: req.Username, IPAddress: c.ClientIP()}
+	if found {
+		attempt.UserID = admin.ID
+	}
+	if err := h.services.Logins.Check(attempt); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	if !utils.CheckPasswordHash(req.Password, admin.PasswordHash) {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	if !found || !utils.CheckPasswordHash(req.Password, admin.PasswordHash) {
+		h.refuseLogin(c, attempt)
+		return
+	}
+	if err := h.services.Logins.Succeed(attempt); err != nil {
+		respondError(c, err)
 		return
 	}
 
-	token, err := utils.GenerateToken(admin.ID, "admin", config.AppConfig.JWTSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+	tokens, ok := h.startSession(c, "admin", admin.ID, admin.Role)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"admin": gin.H{
-			"id":       admin.ID,
-			"username": admin.Username,
-			"email":    admin.Email,
-		},
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"admin":         adminProfile(admin),
 	})
 }
 
+// adminProfile describes the logged in admin, with what its role lets it do
+func adminProfile(admin models.Admin) gin.H {
+	return gin.H{
+		"id":          admin.ID,
+		"username":    admin.Username,
+		"email":       admin.Email,
+		"role":        admin.Role,
+		"permissions": admin.Role.Permissions(),
+	}
+}
+
+// refuseLogin counts a failed login and answers it
+func (h *Handler) refuseLogin(c *gin.Context, attempt services.LoginAttempt) {
+	if err := h.services.Logins.Fail(attempt); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+}
+
+// startSession opens a session for an account that just logged in and returns its
+// tokens. It writes the error response and returns false when it fails.
+func (h *Handler) startSession(c *gin.Context, userType string, userID uint, role models.AdminRole) (*models.TokenResponse, bool) {
+	session, refreshToken, err := h.services.Sessions.Start(userType, userID, clientFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+
+	tokens, err := issueTokens(session, refreshToken, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return nil, false
+	}
+	return tokens, true
+}
+
+// issueTokens signs an access token for a session, with the role of the admin
+func issueTokens(session *models.Session, refreshToken string, role models.AdminRole) (*models.TokenResponse, error) {
+	token, err := utils.GenerateToken(session.UserID, session.UserType, string(role), session.ID, config.AppConfig.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenResponse{
+		AccessToken:  token,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(utils.AccessTokenTTL / time.Second),
+	}, nil
+}
+
+// RefreshToken godoc
+// @Summary Renouveler le token
+// @Description Échange le refresh token d'une session contre un nouveau token JWT et un nouveau refresh token. Chaque refresh token ne sert qu'une fois : le présenter à nouveau ferme la session
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.TokenResponse "Nouveaux tokens"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Refresh token invalide, expiré ou révoqué"
+// @Failure 403 {object} map[string]string "Équipe suspendue"
+// @Router /api/auth/refresh [post]
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, refreshToken, err := h.services.Sessions.Refresh(req.RefreshToken, clientFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// The new token carries the current role of the admin
+	var admin models.Admin
+	if session.UserType == "admin" {
+		if err := h.db.Select("id", "role").First(&admin, session.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			return
+		}
+	}
+
+	tokens, err := issueTokens(session, refreshToken, admin.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout godoc
+// @Summary Déconnexion
+// @Description Ferme la session du token : il est refusé dès maintenant et son refresh token ne peut plus servir
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string "Session fermée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Router /api/auth/logout [post]
+func (h *Handler) Logout(c *gin.Context) {
+	if err := h.services.Sessions.Logout(actorFromContext(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll godoc
+// @Summary Déconnexion de toutes les sessions
+// @Description Ferme toutes les sessions de l'équipe ou de l'admin connecté, y compris celle du token
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Nombre de sessions fermées"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Router /api/auth/logout-all [post]
+func (h *Handler) LogoutAll(c *gin.Context) {
+	revoked, err := h.services.Sessions.LogoutAll(actorFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of every session", "revoked": revoked})
+}
+
 // VerifyToken godoc
 // @Summary Vérifier le token
 // @Description Vérifie si le token JWT est valide et retourne les informations de l'utilisateur
 // @Tags Authentication
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} map[string]interface{} "Token valide"
+// @Success 200 {object} map[string]interface{} "Token valide, avec l'événement de l'équipe ou celui choisi par l'admin"
 // @Failure 401 {object} map[string]string "Token invalide"
 // @Router /api/auth/verify [get]
-func VerifyToken(c *gin.Context) {
+func (h *Handler) VerifyToken(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
@@ -120,9 +280,15 @@ func VerifyToken(c *gin.Context) {
 
 	userType, _ := c.Get("user_type")
 
+	// The interface shows the name and branding of the event
+	var event *models.Event
+	if e, err := h.services.Events.Get(eventIDFromContext(c)); err == nil {
+		event = e
+	}
+
 	if userType == "team" {
 		var team models.Team
-		if err := config.DB.First(&team, userID).Error; err != nil {
+		if err := h.db.First(&team, userID).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
@@ -131,10 +297,11 @@ func VerifyToken(c *gin.Context) {
 			"valid": true,
 			"type":  "team",
 			"user":  team.ToResponse(),
+			"event": event,
 		})
 	} else if userType == "admin" {
 		var admin models.Admin
-		if err := config.DB.First(&admin, userID).Error; err != nil {
+		if err := h.db.First(&admin, userID).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
 			return
 		}
@@ -142,13 +309,87 @@ func VerifyToken(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
 			"valid": true,
 			"type":  "admin",
-			"user": gin.H{
-				"id":       admin.ID,
-				"username": admin.Username,
-				"email":    admin.Email,
-			},
+			"user":  adminProfile(admin),
+			"event": event,
 		})
 	} else {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user type"})
 	}
 }
+
+// ForgotPassword godoc
+// @Summary Mot de passe oublié
+// @Description Envoie par email un lien de réinitialisation du mot de passe, utilisable une seule fois pendant une heure. La réponse est la même que le compte existe ou non
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Type de compte (team ou admin) et nom, username ou email"
+// @Success 200 {object} map[string]string "Demande prise en compte"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Router /api/auth/forgot-password [post]
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.services.Accounts.RequestPasswordReset(req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists, a reset link has been sent to its email address"})
+}
+
+// ResetPassword godoc
+// @Summary Réinitialiser le mot de passe
+// @Description Choisit un nouveau mot de passe avec le jeton reçu par email. Le jeton, les autres liens en cours et les sessions ouvertes ne sont plus utilisables ensuite
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Jeton et nouveau mot de passe"
+// @Success 200 {object} map[string]string "Mot de passe modifié"
+// @Failure 400 {object} map[string]string "Jeton invalide ou expiré, ou mot de passe trop faible"
+// @Router /api/auth/reset-password [post]
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.services.Accounts.ResetPassword(req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
+
+// ChangePassword godoc
+// @Summary Changer de mot de passe
+// @Description Change le mot de passe de l'équipe ou de l'admin connecté et ferme ses autres sessions. Le nouveau mot de passe doit compter au moins 10 caractères dont une lettre et un chiffre, sans contenir le nom du compte
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Mot de passe actuel et nouveau mot de passe"
+// @Success 200 {object} map[string]string "Mot de passe modifié"
+// @Failure 400 {object} map[string]string "Mot de passe actuel incorrect ou nouveau mot de passe trop faible"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Router /api/auth/password [put]
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.services.Accounts.ChangePassword(actorFromContext(c), req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password updated"})
+}
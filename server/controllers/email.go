@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailPreviewResponse is a template rendered with its sample data
+type EmailPreviewResponse struct {
+	Template string `json:"template"`
+	Locale   string `json:"locale"`
+	emails.Message
+}
+
+// GetEmailTemplates godoc
+// @Summary Liste des modèles d'email (Admin)
+// @Description Liste les modèles d'email et les langues disponibles (admin uniquement)
+// @Tags Emails
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string][]string "Modèles et langues"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/emails/templates [get]
+func (h *Handler) GetEmailTemplates(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"templates": h.templates.Names(),
+		"locales":   emails.Locales,
+	})
+}
+
+// PreviewEmailTemplate godoc
+// @Summary Aperçu d'un modèle d'email (Admin)
+// @Description Affiche un modèle d'email rendu avec des données d'exemple, en JSON ou directement en HTML ou en texte (admin uniquement)
+// @Tags Emails
+// @Produce json
+// @Produce text/html
+// @Produce text/plain
+// @Security BearerAuth
+// @Param name path string true "Nom du modèle"
+// @Param locale query string false "Langue (défaut fr)" Enums(fr, en)
+// @Param format query string false "Format de la réponse (défaut json)" Enums(json, html, text)
+// @Success 200 {object} EmailPreviewResponse
+// @Failure 400 {object} map[string]string "Langue ou format invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Modèle introuvable"
+// @Router /api/admin/emails/templates/{name}/preview [get]
+func (h *Handler) PreviewEmailTemplate(c *gin.Context) {
+	name := c.Param("name")
+	if !h.templates.Has(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email template not found"})
+		return
+	}
+	locale := c.DefaultQuery("locale", emails.DefaultLocale)
+	if !emails.IsLocale(locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locale (expected fr or en)"})
+		return
+	}
+
+	msg, err := h.templates.Preview(name, locale)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, EmailPreviewResponse{Template: name, Locale: locale, Message: *msg})
+	case "html":
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(msg.Text))
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format (expected json, html or text)"})
+	}
+}
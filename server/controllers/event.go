@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCurrentEvent godoc
+// @Summary Événement en cours
+// @Description Récupère le nom, les dates et l'habillage (logo, couleur) de l'événement en cours
+// @Tags Events
+// @Produce json
+// @Success 200 {object} models.Event "Événement en cours"
+// @Failure 404 {object} map[string]string "Aucun événement en cours"
+// @Router /api/event [get]
+func (h *Handler) GetCurrentEvent(c *gin.Context) {
+	event, err := services.CurrentEvent(h.db)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// GetEvents godoc
+// @Summary Liste des événements (Admin)
+// @Description Récupère toutes les éditions du hackathon, la plus récente d'abord. L'événement choisi par l'admin est renvoyé par /api/auth/verify (admin uniquement)
+// @Tags Events
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Event "Liste des événements"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/events [get]
+func (h *Handler) GetEvents(c *gin.Context) {
+	events, err := h.services.Events.List()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// CreateEvent godoc
+// @Summary Créer un événement (Admin)
+// @Description Ajoute une édition du hackathon, sans la rendre courante (super-admin uniquement)
+// @Tags Events
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param event body models.CreateEventRequest true "Détails de l'événement"
+// @Success 201 {object} models.Event "Événement créé"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Permission insuffisante"
+// @Router /api/admin/events [post]
+func (h *Handler) CreateEvent(c *gin.Context) {
+	var req models.CreateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.services.Events.Create(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, event)
+}
+
+// UpdateEvent godoc
+// @Summary Modifier un événement (Admin)
+// @Description Met à jour les champs fournis d'un événement : nom, dates, crédit de départ des nouvelles équipes et habillage (super-admin uniquement)
+// @Tags Events
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'événement"
+// @Param event body models.UpdateEventRequest true "Champs à modifier"
+// @Success 200 {object} models.Event "Événement mis à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Permission insuffisante"
+// @Failure 404 {object} map[string]string "Événement non trouvé"
+// @Router /api/admin/events/{id} [put]
+func (h *Handler) UpdateEvent(c *gin.Context) {
+	var req models.UpdateEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	event, err := h.services.Events.Update(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// ActivateEvent godoc
+// @Summary Rendre un événement courant (Admin)
+// @Description Fait de l'événement celui des pages publiques et des connexions des équipes ; les équipes des autres événements ne peuvent plus se connecter (super-admin uniquement)
+// @Tags Events
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'événement"
+// @Success 200 {object} models.Event "Événement courant"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Permission insuffisante"
+// @Failure 404 {object} map[string]string "Événement non trouvé"
+// @Router /api/admin/events/{id}/activate [post]
+func (h *Handler) ActivateEvent(c *gin.Context) {
+	event, err := h.services.Events.Activate(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
+
+// SwitchEvent godoc
+// @Summary Changer d'événement (Admin)
+// @Description Choisit l'événement affiché et modifié par la session de l'admin, par exemple pour consulter une édition passée. Les autres sessions et les équipes ne sont pas concernées (admin uniquement)
+// @Tags Events
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'événement"
+// @Success 200 {object} models.Event "Événement choisi"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Événement non trouvé"
+// @Router /api/admin/events/{id}/switch [post]
+func (h *Handler) SwitchEvent(c *gin.Context) {
+	event, err := h.services.Events.Select(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, event)
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat keeps idle connections open through proxies
+const streamHeartbeat = 25 * time.Second
+
+// StreamEvents godoc
+// @Summary Flux d'événements en direct
+// @Description Server-Sent Events : les équipes reçoivent les mises à jour de leurs achats et de leur crédit, les admins les nouvelles demandes et les retours de l'événement sélectionné. Le flux est fermé quand la session prend fin. Le token peut être passé dans le paramètre "token" car EventSource ne permet pas d'envoyer d'en-tête.
+// @Tags Events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param token query string false "Token JWT (alternative à l'en-tête Authorization)"
+// @Success 200 {string} string "Flux d'événements"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Équipe suspendue"
+// @Router /api/events [get]
+func (h *Handler) StreamEvents(c *gin.Context) {
+	actor := actorFromContext(c)
+
+	sub := h.events.Subscribe(actor.UserType, actor.UserID, actor.EventID)
+	defer h.events.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	// Tell the client the stream is open
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// Close the stream once the session ends or the admin selects another
+			// event: the client reconnects with a new token
+			if eventID, err := services.SessionEvent(h.db, actor.UserType, actor.UserID, actor.SessionID); err != nil || eventID != actor.EventID {
+				return false
+			}
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case event, ok := <-sub.Events:
+			if !ok {
+				return false
+			}
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return true
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, payload)
+			return true
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/export"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of rows loaded from the database at a time
+const exportBatchSize = 500
+
+// startExport validates the requested format and writes the download headers. Once it
+// returns, the response has started: later errors can only be logged.
+func startExport(c *gin.Context, name string) (export.Writer, bool) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.IsValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format (expected csv or xlsx)"})
+		return nil, false
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-1504"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer, name)
+	if err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+		return nil, false
+	}
+	return w, true
+}
+
+// finishExport completes the file, or logs why the export was cut short
+func finishExport(w export.Writer, name string, err error) {
+	if err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Export %s failed: %v", name, err)
+	}
+}
+
+// ExportPurchases godoc
+// @Summary Export des achats (Admin)
+// @Description Télécharge les achats en CSV ou XLSX, avec les mêmes filtres et le même tri que la liste des achats (admin uniquement)
+// @Tags Export
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "Format du fichier (défaut csv)" Enums(csv, xlsx)
+// @Param status query string false "Filtrer par statut" Enums(en attente, confirmé, annulé)
+// @Param team_id query int false "Filtrer par équipe"
+// @Param resource_id query int false "Filtrer par ressource"
+// @Param type query string false "Filtrer par type de ressource" Enums(service, matériel, avantage)
+// @Param batch_id query string false "Filtrer par commande groupée"
+// @Param needs_return query bool false "Filtrer par articles à retourner"
+// @Param overdue query bool false "Filtrer par articles loués non rendus après leur date de retour"
+// @Param from query string false "Date de début (RFC3339)"
+// @Param to query string false "Date de fin (RFC3339)"
+// @Param q query string false "Recherche dans le commentaire"
+// @Param sort query string false "Tri (défaut date)" Enums(date, cost, team, quantity, status, due)
+// @Param order query string false "Ordre du tri (défaut desc)" Enums(asc, desc)
+// @Success 200 {file} file "Fichier d'export"
+// @Failure 400 {object} map[string]string "Filtre ou format invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/export/purchases [get]
+func (h *Handler) ExportPurchases(c *gin.Context) {
+	q, err := parsePurchaseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	q.EventID = eventIDFromContext(c)
+	// Every matching purchase is exported, page by page
+	q.Limit = exportBatchSize
+
+	w, ok := startExport(c, "achats")
+	if !ok {
+		return
+	}
+
+	err = w.WriteRow("ID", "Date", "Équipe", "Ressource", "Type", "Quantité demandée", "Quantité",
+		"Coût unitaire", "Coût total", "Statut", "À retourner", "Date de retour", "Retourné", "Commande groupée", "Commentaire")
+	if err == nil {
+		err = h.services.Purchases.Export(q, func(purchases []models.Purchase) error {
+			for _, p := range purchases {
+				if err := w.WriteRow(p.ID, p.PurchaseDate, p.Team.Name, p.Resource.Name, p.Resource.Type,
+					p.RequestedQuantity, p.Quantity, p.UnitCost, p.TotalCost(),
+					string(p.Status), p.NeedsReturn, p.ReturnDueAt, p.IsReturned, p.BatchID, p.Comment); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		}, "Team", "Resource")
+	}
+	finishExport(w, "achats", err)
+}
+
+// ExportInventory godoc
+// @Summary Export de l'inventaire (Admin)
+// @Description Télécharge l'état actuel du stock de chaque ressource de l'événement sélectionné en CSV ou XLSX (admin uniquement)
+// @Tags Export
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "Format du fichier (défaut csv)" Enums(csv, xlsx)
+// @Success 200 {file} file "Fichier d'export"
+// @Failure 400 {object} map[string]string "Format invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/export/inventory [get]
+func (h *Handler) ExportInventory(c *gin.Context) {
+	w, ok := startExport(c, "inventaire")
+	if !ok {
+		return
+	}
+
+	err := w.WriteRow("ID", "Nom", "Type", "Coût", "Stock", "Réservé", "Disponible", "Max par équipe", "Active", "Non retournable", "Seuil de stock faible", "Durée de location (min)")
+	if err == nil {
+		var resources []models.Resource
+		err = h.db.Where("event_id = ?", eventIDFromContext(c)).FindInBatches(&resources, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, r := range resources {
+				if err := w.WriteRow(r.ID, r.Name, r.Type, r.Cost, r.Quantity, r.Reserved, r.AvailableQuantity(),
+					r.MaxPerTeam, r.IsActive, r.IsNonReturnable, r.LowStockThreshold, r.RentalMinutes); err != nil {
+					return err
+				}
+			}
+			return w.Flush()
+		}).Error
+	}
+	finishExport(w, "inventaire", err)
+}
+
+// ExportTeamBalances godoc
+// @Summary Export des soldes des équipes (Admin)
+// @Description Télécharge le crédit restant et le total dépensé de chaque équipe de l'événement sélectionné en CSV ou XLSX (admin uniquement)
+// @Tags Export
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security BearerAuth
+// @Param format query string false "Format du fichier (défaut csv)" Enums(csv, xlsx)
+// @Success 200 {file} file "Fichier d'export"
+// @Failure 400 {object} map[string]string "Format invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/export/teams [get]
+func (h *Handler) ExportTeamBalances(c *gin.Context) {
+	w, ok := startExport(c, "equipes")
+	if !ok {
+		return
+	}
+
+	type teamBalance struct {
+		ID          uint
+		Name        string
+		Email       string
+		Credit      int
+		IsSuspended bool
+		Spent       int // Cost paid for the pending and confirmed purchases
+	}
+
+	err := w.WriteRow("ID", "Nom", "Email", "Crédit restant", "Crédit dépensé", "Suspendue")
+	if err == nil {
+		lastID := uint(0)
+		for err == nil {
+			var balances []teamBalance
+			err = h.db.Model(&models.Team{}).
+				Select(`teams.id, teams.name, teams.email, teams.credit, teams.is_suspended,
+					COALESCE((SELECT SUM(p.quantity * p.unit_cost) FROM purchases p
+						WHERE p.team_id = teams.id AND p.status IN ? AND p.deleted_at IS NULL), 0) AS spent`,
+					[]models.PurchaseStatus{models.StatusPending, models.StatusConfirmed}).
+				Where("teams.event_id = ? AND teams.id > ?", eventIDFromContext(c), lastID).
+				Order("teams.id ASC").
+				Limit(exportBatchSize).
+				Scan(&balances).Error
+			if err != nil || len(balances) == 0 {
+				break
+			}
+			for _, b := range balances {
+				if err = w.WriteRow(b.ID, b.Name, b.Email, b.Credit, b.Spent, b.IsSuspended); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = w.Flush()
+			}
+			lastID = balances[len(balances)-1].ID
+		}
+	}
+	finishExport(w, "equipes", err)
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Handler serves the API. The business rules live in the services: handlers bind
+// the requests, call a service and write its result.
+type Handler struct {
+	db        *gorm.DB // Read-only listings, exports and imports
+	services  *services.Services
+	templates *emails.Registry
+	events    *events.Broker
+}
+
+// New builds the handlers and their services from the shared dependencies
+func New(deps services.Deps) *Handler {
+	return &Handler{
+		db:        deps.DB,
+		services:  services.New(deps),
+		templates: deps.Templates,
+		events:    deps.Events,
+	}
+}
+
+// errorStatus maps the kinds of service errors to HTTP statuses
+var errorStatus = map[services.Kind]int{
+	services.KindInvalid:         http.StatusBadRequest,
+	services.KindNotFound:        http.StatusNotFound,
+	services.KindForbidden:       http.StatusForbidden,
+	services.KindConflict:        http.StatusConflict,
+	services.KindUnauthorized:    http.StatusUnauthorized,
+	services.KindTooManyRequests: http.StatusTooManyRequests,
+	services.KindInternal:        http.StatusInternalServerError,
+}
+
+// respondError writes the response of a failed service call
+func respondError(c *gin.Context, err error) {
+	var svcErr *services.Error
+	if !errors.As(err, &svcErr) {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	if svcErr.Kind == services.KindInternal {
+		log.Printf("%s %s: %v", c.Request.Method, c.FullPath(), svcErr)
+	}
+
+	body := gin.H{"error": svcErr.Message}
+	for key, value := range svcErr.Details {
+		body[key] = value
+	}
+	if retryAfter, ok := svcErr.Details["retry_after"]; ok {
+		c.Header("Retry-After", fmt.Sprint(retryAfter))
+	}
+	c.JSON(errorStatus[svcErr.Kind], body)
+}
+
+// actorFromContext returns the authenticated user, set by the auth middleware
+func actorFromContext(c *gin.Context) services.Actor {
+	userID, _ := c.Get("user_id")
+	userType, _ := c.Get("user_type")
+	sessionID, _ := c.Get("session_id")
+	eventID, _ := c.Get("event_id")
+	actor := services.Actor{}
+	actor.UserID, _ = userID.(uint)
+	actor.UserType, _ = userType.(string)
+	actor.SessionID, _ = sessionID.(uint)
+	actor.EventID, _ = eventID.(uint)
+	return actor
+}
+
+// eventIDFromContext returns the event of the authenticated user, set by the auth
+// middleware
+func eventIDFromContext(c *gin.Context) uint {
+	eventID, _ := c.Get("event_id")
+	id, _ := eventID.(uint)
+	return id
+}
+
+// currentEventID returns the event of the public routes. It writes the error response
+// and returns false when there is none.
+func (h *Handler) currentEventID(c *gin.Context) (uint, bool) {
+	event, err := services.CurrentEvent(h.db)
+	if err != nil {
+		respondError(c, err)
+		return 0, false
+	}
+	return event.ID, true
+}
+
+// clientFromContext describes the client of the request, recorded on its session
+func clientFromContext(c *gin.Context) services.Client {
+	return services.Client{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// teamIDFromContext returns the ID of the authenticated team
+func teamIDFromContext(c *gin.Context) uint {
+	userID, _ := c.Get("user_id")
+	teamID, _ := userID.(uint)
+	return teamID
+}
+
+// paramID reads a numeric path parameter. Invalid IDs are returned as 0, which
+// matches no row, so that they are reported as not found.
+func paramID(c *gin.Context, name string) uint {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
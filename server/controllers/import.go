@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/importer"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportSize limits the size of an uploaded CSV file
+const maxImportSize = 5 << 20
+
+type importFunc func(tx *gorm.DB, r io.Reader, opts importer.Options) (*importer.Report, error)
+
+// importFile returns the uploaded CSV, sent either as the "file" field of a multipart
+// form or as the raw request body
+func importFile(c *gin.Context) (io.ReadCloser, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, errors.New("A CSV file is required in the \"file\" field")
+		}
+		return header.Open()
+	}
+	return c.Request.Body, nil
+}
+
+// runImport runs an import in a transaction that is only committed when every row is
+// valid. With ?dry_run=true the rows are checked and the transaction rolled back.
+func (h *Handler) runImport(c *gin.Context, run importFunc, auditAction, entityType string) {
+	file, err := importFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+	dryRun := c.Query("dry_run") == "true"
+
+	actor := actorFromContext(c)
+	event, err := h.services.Events.Get(actor.EventID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Start transaction
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	report, err := run(tx, file, importer.Options{Event: *event, AdminID: actor.AdminID()})
+	if err != nil {
+		tx.Rollback()
+		switch {
+		case errors.Is(err, importer.ErrInvalidRows):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Import failed, nothing was written", "report": report})
+		case errors.Is(err, importer.ErrInvalidFile):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Import failed, nothing was written"})
+		}
+		return
+	}
+
+	if dryRun {
+		tx.Rollback()
+		report.Credentials = nil // Generated passwords were not saved
+		c.JSON(http.StatusOK, gin.H{"dry_run": true, "report": report})
+		return
+	}
+
+	summary := gin.H{"rows": report.Rows, "created": report.Created}
+	if err := services.RecordAudit(tx, actor, auditAction, entityType, 0, nil, summary); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit entry"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transaction failed"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"dry_run": false, "report": report})
+}
+
+// ImportTeams godoc
+// @Summary Import d'équipes en CSV (Admin)
+// @Description Crée dans l'événement sélectionné les équipes d'un fichier CSV (colonnes name, email, password et credit optionnelles). Les mots de passe manquants sont générés et renvoyés une seule fois. Tout ou rien : si une ligne est invalide, rien n'est écrit (admin uniquement)
+// @Tags Import
+// @Accept multipart/form-data
+// @Accept text/csv
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file false "Fichier CSV (ou corps de la requête en text/csv)"
+// @Param dry_run query bool false "Vérifier le fichier sans rien écrire"
+// @Success 201 {object} map[string]interface{} "Rapport d'import (équipes créées et mots de passe générés)"
+// @Failure 400 {object} map[string]interface{} "Fichier invalide, erreurs par ligne"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/import/teams [post]
+func (h *Handler) ImportTeams(c *gin.Context) {
+	h.runImport(c, importer.ImportTeams, models.AuditTeamImport, models.AuditEntityTeam)
+}
+
+// ImportResources godoc
+// @Summary Import de ressources en CSV (Admin)
+// @Description Crée dans l'événement sélectionné les ressources d'un fichier CSV (colonnes name, type, cost, quantity, max_per_team, et description, image_url, is_active, is_non_returnable, low_stock_threshold, rental_minutes optionnelles). Tout ou rien : si une ligne est invalide, rien n'est écrit (admin uniquement)
+// @Tags Import
+// @Accept multipart/form-data
+// @Accept text/csv
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file false "Fichier CSV (ou corps de la requête en text/csv)"
+// @Param dry_run query bool false "Vérifier le fichier sans rien écrire"
+// @Success 201 {object} map[string]interface{} "Rapport d'import"
+// @Failure 400 {object} map[string]interface{} "Fichier invalide, erreurs par ligne"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/import/resources [post]
+func (h *Handler) ImportResources(c *gin.Context) {
+	h.runImport(c, importer.ImportResources, models.AuditResourceImport, models.AuditEntityResource)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTeamLedger godoc
+// @Summary Historique des crédits de l'équipe
+// @Description Récupère toutes les opérations de crédit de l'équipe connectée
+// @Tags Team
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.LedgerResponse "Historique des crédits"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/team/ledger [get]
+func (h *Handler) GetTeamLedger(c *gin.Context) {
+	ledger, err := h.services.Teams.Ledger(teamIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
+}
+
+// GetTeamLedgerAdmin godoc
+// @Summary Historique des crédits d'une équipe (Admin)
+// @Description Récupère toutes les opérations de crédit d'une équipe et vérifie la cohérence avec son solde (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'équipe"
+// @Success 200 {object} models.LedgerResponse "Historique des crédits"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id}/ledger [get]
+func (h *Handler) GetTeamLedgerAdmin(c *gin.Context) {
+	ledger, err := h.services.Teams.Ledger(paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ledger)
+}
+
+// CheckLedgers godoc
+// @Summary Vérifier la cohérence des crédits (Admin)
+// @Description Compare le solde de chaque équipe avec la somme de ses opérations de crédit (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Équipes dont le solde ne correspond pas"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/ledger/check [get]
+func (h *Handler) CheckLedgers(c *gin.Context) {
+	checked, mismatches, err := h.services.Teams.CheckLedgers()
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"checked":    checked,
+		"consistent": len(mismatches) == 0,
+		"mismatches": mismatches,
+	})
+}
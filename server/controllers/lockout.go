@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLoginLockouts godoc
+// @Summary Blocages de connexion (Admin)
+// @Description Liste les comptes et adresses IP bloqués après trop d'échecs de connexion, des plus récents aux plus anciens, avec l'identifiant et l'adresse de la dernière tentative. Le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count (admin uniquement)
+// @Tags Sessions
+// @Produce json
+// @Security BearerAuth
+// @Param scope query string false "Filtrer par type de blocage" Enums(account, ip)
+// @Param user_type query string false "Filtrer par type de compte" Enums(team, admin)
+// @Param user_id query int false "Filtrer par compte (avec user_type)"
+// @Param active query bool false "Seulement les blocages en cours"
+// @Param limit query int false "Nombre maximum de résultats (défaut 100, max 1000)"
+// @Param offset query int false "Nombre de résultats à ignorer"
+// @Success 200 {array} models.LoginLockout "Blocages"
+// @Failure 400 {object} map[string]string "Filtre invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/login-lockouts [get]
+func (h *Handler) GetLoginLockouts(c *gin.Context) {
+	query := h.db.Model(&models.LoginLockout{})
+
+	if scope := c.Query("scope"); scope != "" {
+		if scope != models.LoginScopeAccount && scope != models.LoginScopeIP {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scope"})
+			return
+		}
+		query = query.Where("scope = ?", scope)
+	}
+	if userType := c.Query("user_type"); userType != "" {
+		if userType != "team" && userType != "admin" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user type"})
+			return
+		}
+		query = query.Where("user_type = ?", userType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	if active := c.Query("active"); active != "" {
+		onlyActive, err := strconv.ParseBool(active)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active filter"})
+			return
+		}
+		if onlyActive {
+			query = query.Where("unlocked_at IS NULL AND locked_until > ?", time.Now())
+		}
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-1000)"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	var lockouts []models.LoginLockout
+	if err := query.Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&lockouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, lockouts)
+}
+
+// UnlockLogin godoc
+// @Summary Débloquer un compte ou une adresse IP (Admin)
+// @Description Lève un blocage de connexion avant son terme et remet à zéro les échecs du compte ou de l'adresse (admin uniquement)
+// @Tags Sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du blocage"
+// @Success 200 {object} models.LoginLockout "Blocage levé"
+// @Failure 400 {object} map[string]string "Blocage déjà terminé"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Blocage introuvable"
+// @Router /api/admin/login-lockouts/{id}/unlock [post]
+func (h *Handler) UnlockLogin(c *gin.Context) {
+	lockout, err := h.services.Logins.Unlock(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, lockout)
+}
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// GetNotificationChannels godoc
+// @Summary Canaux de notification (Admin)
+// @Description Récupère les adresses email et webhooks notifiés de l'événement sélectionné, avec les événements qui leur sont envoyés (admin uniquement)
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.NotificationChannel "Canaux de notification"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/notifications/channels [get]
+func (h *Handler) GetNotificationChannels(c *gin.Context) {
+	channels, err := h.services.Notifications.ListChannels(eventIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+// CreateNotificationChannel godoc
+// @Summary Créer un canal de notification (Admin)
+// @Description Ajoute une adresse email ou un webhook (Discord, Slack ou autre) notifié des événements choisis de l'événement sélectionné : purchase.pending, resource.low_stock, return.overdue, poll.closed. Le secret signant les requêtes d'un webhook n'est renvoyé qu'à la création (admin uniquement)
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param channel body models.CreateNotificationChannelRequest true "Détails du canal"
+// @Success 201 {object} models.CreateNotificationChannelResponse "Canal créé"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/notifications/channels [post]
+func (h *Handler) CreateNotificationChannel(c *gin.Context) {
+	var req models.CreateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, secret, err := h.services.Notifications.CreateChannel(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CreateNotificationChannelResponse{Channel: *channel, Secret: secret})
+}
+
+// UpdateNotificationChannel godoc
+// @Summary Modifier un canal de notification (Admin)
+// @Description Met à jour les champs fournis d'un canal de notification. Son type et son secret ne peuvent pas être modifiés (admin uniquement)
+// @Tags Notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du canal"
+// @Param channel body models.UpdateNotificationChannelRequest true "Champs à modifier"
+// @Success 200 {object} models.NotificationChannel "Canal mis à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Canal non trouvé"
+// @Router /api/admin/notifications/channels/{id} [put]
+func (h *Handler) UpdateNotificationChannel(c *gin.Context) {
+	var req models.UpdateNotificationChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel, err := h.services.Notifications.UpdateChannel(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// DeleteNotificationChannel godoc
+// @Summary Supprimer un canal de notification (Admin)
+// @Description Supprime un canal de notification. Ses notifications en attente sont abandonnées (admin uniquement)
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du canal"
+// @Success 200 {object} map[string]string "Canal supprimé"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Canal non trouvé"
+// @Router /api/admin/notifications/channels/{id} [delete]
+func (h *Handler) DeleteNotificationChannel(c *gin.Context) {
+	if err := h.services.Notifications.DeleteChannel(actorFromContext(c), paramID(c, "id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted"})
+}
+
+// GetNotificationDeliveries godoc
+// @Summary Historique des notifications (Admin)
+// @Description Liste les envois de notifications aux canaux de l'événement sélectionné, des plus récents aux plus anciens, avec leurs tentatives et leur dernière erreur. Le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count (admin uniquement)
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filtrer par statut" Enums(en attente, livré, échoué)
+// @Param channel_id query int false "Filtrer par canal"
+// @Param event query string false "Filtrer par événement" Enums(purchase.pending, resource.low_stock, return.overdue, poll.closed)
+// @Param limit query int false "Nombre maximum de résultats (défaut 100, max 1000)"
+// @Param offset query int false "Nombre de résultats à ignorer"
+// @Success 200 {array} models.NotificationDelivery "Envois"
+// @Failure 400 {object} map[string]string "Filtre invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/notifications/deliveries [get]
+func (h *Handler) GetNotificationDeliveries(c *gin.Context) {
+	// Deliveries of the channels of the selected event, removed ones included
+	query := h.db.Model(&models.NotificationDelivery{}).
+		Where("channel_id IN (?)", h.db.Unscoped().Model(&models.NotificationChannel{}).Select("id").Where("event_id = ?", eventIDFromContext(c)))
+
+	if status := c.Query("status"); status != "" {
+		switch models.DeliveryStatus(status) {
+		case models.DeliveryStatusPending, models.DeliveryStatusDelivered, models.DeliveryStatusFailed:
+			query = query.Where("status = ?", status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+	}
+	if channelID := c.Query("channel_id"); channelID != "" {
+		query = query.Where("channel_id = ?", channelID)
+	}
+	if event := c.Query("event"); event != "" {
+		if !notify.IsEvent(event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event"})
+			return
+		}
+		query = query.Where("event = ?", event)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-1000)"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := query.Preload("Channel").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// ResendNotificationDelivery godoc
+// @Summary Renvoyer une notification échouée (Admin)
+// @Description Remet en attente une notification abandonnée après trop d'échecs, pour une nouvelle série de tentatives (admin uniquement)
+// @Tags Notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'envoi"
+// @Success 200 {object} models.NotificationDelivery
+// @Failure 400 {object} map[string]string "L'envoi n'est pas en échec"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Envoi introuvable"
+// @Router /api/admin/notifications/deliveries/{id}/resend [post]
+func (h *Handler) ResendNotificationDelivery(c *gin.Context) {
+	delivery, err := h.services.Notifications.ResendDelivery(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetEmails godoc
+// @Summary Emails de la file d'envoi (Admin)
+// @Description Liste les emails de la file d'envoi, des plus récents aux plus anciens, sans leur contenu. Le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count (admin uniquement)
+// @Tags Emails
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filtrer par statut" Enums(en attente, envoyé, échoué)
+// @Param team_id query int false "Filtrer par équipe"
+// @Param template query string false "Filtrer par modèle"
+// @Param limit query int false "Nombre maximum de résultats (défaut 100, max 1000)"
+// @Param offset query int false "Nombre de résultats à ignorer"
+// @Success 200 {array} models.EmailOutbox "Emails"
+// @Failure 400 {object} map[string]string "Filtre invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/emails [get]
+func (h *Handler) GetEmails(c *gin.Context) {
+	query := h.db.Model(&models.EmailOutbox{})
+
+	if status := c.Query("status"); status != "" {
+		switch models.EmailStatus(status) {
+		case models.EmailStatusPending, models.EmailStatusSent, models.EmailStatusFailed:
+			query = query.Where("status = ?", status)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+	}
+	if teamID := c.Query("team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
+	if template := c.Query("template"); template != "" {
+		query = query.Where("template = ?", template)
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-1000)"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emails"})
+		return
+	}
+
+	var outbox []models.EmailOutbox
+	if err := query.Omit("html", "text").
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&outbox).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch emails"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, outbox)
+}
+
+// GetEmail godoc
+// @Summary Détail d'un email de la file d'envoi (Admin)
+// @Description Récupère un email de la file d'envoi avec son contenu et sa dernière erreur. Le contenu des emails de réinitialisation de mot de passe n'est pas renvoyé (admin uniquement)
+// @Tags Emails
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'email"
+// @Success 200 {object} models.EmailOutbox
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Email introuvable"
+// @Router /api/admin/emails/{id} [get]
+func (h *Handler) GetEmail(c *gin.Context) {
+	var email models.EmailOutbox
+	if err := h.db.First(&email, paramID(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email not found"})
+		return
+	}
+	redactEmail(&email)
+	c.JSON(http.StatusOK, email)
+}
+
+// redactEmail blanks the content of the password reset emails: the reset link would
+// let the reader take over the account
+func redactEmail(email *models.EmailOutbox) {
+	if email.Template == emails.PasswordReset {
+		email.HTML, email.Text = "", ""
+	}
+}
+
+// ResendEmail godoc
+// @Summary Renvoyer un email échoué (Admin)
+// @Description Remet dans la file d'envoi un email abandonné après trop d'échecs, pour une nouvelle série de tentatives. Le contenu des emails de réinitialisation de mot de passe n'est pas renvoyé (admin uniquement)
+// @Tags Emails
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'email"
+// @Success 200 {object} models.EmailOutbox
+// @Failure 400 {object} map[string]string "L'email n'est pas en échec"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Email introuvable"
+// @Router /api/admin/emails/{id}/resend [post]
+func (h *Handler) ResendEmail(c *gin.Context) {
+	email, err := h.services.Outbox.Resend(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	redactEmail(email)
+	c.JSON(http.StatusOK, email)
+}
@@ -3,80 +3,210 @@ package controllers
 import (
 	"net/http"
 
-	"github.com/ericp/ylab-hackathon/config"
 	"github.com/ericp/ylab-hackathon/models"
 	"github.com/gin-gonic/gin"
 )
 
 // GetPolls godoc
 // @Summary Liste des sondages
-// @Description Récupère tous les sondages avec filtre optionnel par statut
+// @Description Récupère tous les sondages de l'événement en cours avec filtre optionnel par statut
 // @Tags Polls
 // @Produce json
 // @Param status query string false "Filtrer par statut" Enums(ouvert, fermé)
 // @Success 200 {array} models.Poll "Liste des sondages"
 // @Failure 500 {object} map[string]string "Erreur serveur"
 // @Router /api/polls [get]
-func GetPolls(c *gin.Context) {
-	var polls []models.Poll
-	query := config.DB
-
-	// Filter by status if provided
-	status := c.Query("status")
-	if status != "" {
-		query = query.Where("status = ?", status)
+func (h *Handler) GetPolls(c *gin.Context) {
+	eventID, ok := h.currentEventID(c)
+	if !ok {
+		return
 	}
 
-	if err := query.Order("start_date DESC").Find(&polls).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch polls"})
+	polls, err := h.services.Polls.List(eventID, c.Query("status"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, polls)
 }
 
-func GetPoll(c *gin.Context) {
-	id := c.Param("id")
-
-	var poll models.Poll
-	if err := config.DB.Preload("Votes").First(&poll, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+func (h *Handler) GetPoll(c *gin.Context) {
+	poll, err := h.services.Polls.Get(paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, poll)
 }
 
-func GetPollResults(c *gin.Context) {
-	id := c.Param("id")
+func (h *Handler) GetPollResults(c *gin.Context) {
+	results, err := h.services.Polls.Results(paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
 
-	var poll models.Poll
-	if err := config.DB.First(&poll, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found"})
+// CreatePoll godoc
+// @Summary Créer un sondage (Admin)
+// @Description Crée un nouveau sondage avec ses options et ses dates d'ouverture (admin uniquement)
+// @Tags Polls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param poll body models.CreatePollRequest true "Détails du sondage"
+// @Success 201 {object} models.Poll "Sondage créé"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/polls [post]
+func (h *Handler) CreatePoll(c *gin.Context) {
+	var req models.CreatePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	var votes []models.Vote
-	if err := config.DB.Where("poll_id = ?", id).Find(&votes).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch votes"})
+	poll, err := h.services.Polls.Create(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	// Calculate results
-	results := make(map[string]struct {
-		Count        int `json:"count"`
-		TotalCredits int `json:"total_credits"`
+	c.JSON(http.StatusCreated, poll)
+}
+
+// UpdatePoll godoc
+// @Summary Modifier un sondage (Admin)
+// @Description Met à jour un sondage. Les options ne peuvent plus être modifiées après le premier vote (admin uniquement)
+// @Tags Polls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du sondage"
+// @Param poll body models.UpdatePollRequest true "Champs à modifier"
+// @Success 200 {object} models.Poll "Sondage mis à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Sondage non trouvé"
+// @Failure 409 {object} map[string]string "Le sondage a déjà reçu des votes"
+// @Router /api/admin/polls/{id} [put]
+func (h *Handler) UpdatePoll(c *gin.Context) {
+	var req models.UpdatePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	poll, err := h.services.Polls.Update(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, poll)
+}
+
+// ClosePoll godoc
+// @Summary Fermer un sondage (Admin)
+// @Description Ferme un sondage ouvert avant sa date de fin (admin uniquement)
+// @Tags Polls
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du sondage"
+// @Success 200 {object} models.Poll "Sondage fermé"
+// @Failure 400 {object} map[string]string "Sondage non ouvert"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Sondage non trouvé"
+// @Router /api/admin/polls/{id}/close [post]
+func (h *Handler) ClosePoll(c *gin.Context) {
+	poll, err := h.services.Polls.Close(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, poll)
+}
+
+// CancelPoll godoc
+// @Summary Annuler un sondage (Admin)
+// @Description Annule un sondage et restitue les crédits misés aux équipes (admin uniquement)
+// @Tags Polls
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du sondage"
+// @Success 200 {object} map[string]interface{} "Sondage annulé et mises remboursées"
+// @Failure 400 {object} map[string]string "Sondage déjà annulé"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Sondage non trouvé"
+// @Router /api/admin/polls/{id}/cancel [post]
+func (h *Handler) CancelPoll(c *gin.Context) {
+	cancellation, err := h.services.Polls.Cancel(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Poll cancelled",
+		"poll":             cancellation.Poll,
+		"refunded_votes":   cancellation.RefundedVotes,
+		"refunded_credits": cancellation.RefundedCredits,
 	})
+}
 
-	for _, vote := range votes {
-		result := results[vote.ChosenOption]
-		result.Count++
-		result.TotalCredits += vote.CreditStaked
-		results[vote.ChosenOption] = result
+// ResolvePoll godoc
+// @Summary Résoudre un sondage (Admin)
+// @Description Enregistre l'option gagnante et redistribue les crédits misés aux gagnants au prorata de leur mise, après une commission optionnelle. Un second appel avec la même option ne redistribue rien (admin uniquement)
+// @Tags Polls
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du sondage"
+// @Param resolution body models.ResolvePollRequest true "Option gagnante et commission"
+// @Success 200 {object} map[string]interface{} "Sondage résolu"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Sondage non trouvé"
+// @Failure 409 {object} map[string]string "Sondage déjà résolu avec une autre option"
+// @Router /api/admin/polls/{id}/resolve [post]
+func (h *Handler) ResolvePoll(c *gin.Context) {
+	var req models.ResolvePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolution, err := h.services.Polls.Resolve(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	if resolution.AlreadyResolved {
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Poll already resolved",
+			"already_resolved": true,
+			"poll":             resolution.Poll,
+			"votes":            resolution.Votes,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"poll":    poll,
-		"results": results,
+		"message":    "Poll resolved",
+		"poll":       resolution.Poll,
+		"pool":       resolution.Pool,
+		"house_take": resolution.HouseTake,
+		"votes":      resolution.Votes,
 	})
 }
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPurchasePageSize = 100
+	maxPurchasePageSize     = 1000
+)
+
+// parsePurchaseQuery reads the listing options from the query string
+func parsePurchaseQuery(c *gin.Context) (services.PurchaseQuery, error) {
+	q := services.PurchaseQuery{
+		TeamID:       c.Query("team_id"),
+		ResourceID:   c.Query("resource_id"),
+		ResourceType: c.Query("type"),
+		BatchID:      c.Query("batch_id"),
+		Status:       c.Query("status"),
+		NeedsReturn:  c.Query("needs_return") == "true",
+		Overdue:      c.Query("overdue") == "true",
+		Search:       strings.TrimSpace(c.Query("q")),
+		Sort:         c.DefaultQuery("sort", "date"),
+	}
+
+	if !services.IsValidPurchaseSort(q.Sort) {
+		return q, errors.New("Invalid sort (expected date, cost, team, quantity, status or due)")
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		q.Desc = false
+	case "desc":
+		q.Desc = true
+	default:
+		return q, errors.New("Invalid order (expected asc or desc)")
+	}
+
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return q, errors.New("Invalid from date (expected RFC3339)")
+		}
+		q.From = &fromDate
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return q, errors.New("Invalid to date (expected RFC3339)")
+		}
+		q.To = &toDate
+	}
+
+	var err error
+	q.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultPurchasePageSize)))
+	if err != nil || q.Limit < 1 || q.Limit > maxPurchasePageSize {
+		return q, fmt.Errorf("Invalid limit (1-%d)", maxPurchasePageSize)
+	}
+	q.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || q.Offset < 0 {
+		return q, errors.New("Invalid offset")
+	}
+
+	return q, nil
+}
+
+// respondPurchasePage runs a purchase listing and writes the page, with the total
+// number of results in the X-Total-Count header
+func (h *Handler) respondPurchasePage(c *gin.Context, q services.PurchaseQuery, preloads ...string) {
+	purchases, total, err := h.services.Purchases.List(q, preloads...)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, purchases)
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetQuotaRules godoc
+// @Summary Liste des quotas (Admin)
+// @Description Récupère toutes les règles de quota de l'événement sélectionné (admin uniquement)
+// @Tags Quotas
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.QuotaRule "Liste des quotas"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/quotas [get]
+func (h *Handler) GetQuotaRules(c *gin.Context) {
+	rules, err := h.services.Quotas.List(eventIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateQuotaRule godoc
+// @Summary Créer un quota (Admin)
+// @Description Ajoute une limite par ressource ou par type, optionnellement sur une fenêtre de temps (admin uniquement)
+// @Tags Quotas
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body models.CreateQuotaRuleRequest true "Détails du quota"
+// @Success 201 {object} models.QuotaRule "Quota créé"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/quotas [post]
+func (h *Handler) CreateQuotaRule(c *gin.Context) {
+	var req models.CreateQuotaRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.services.Quotas.Create(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateQuotaRule godoc
+// @Summary Modifier un quota (Admin)
+// @Description Met à jour les champs fournis d'une règle de quota (admin uniquement)
+// @Tags Quotas
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du quota"
+// @Param rule body models.UpdateQuotaRuleRequest true "Champs à modifier"
+// @Success 200 {object} models.QuotaRule "Quota mis à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Quota non trouvé"
+// @Router /api/admin/quotas/{id} [put]
+func (h *Handler) UpdateQuotaRule(c *gin.Context) {
+	var req models.UpdateQuotaRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.services.Quotas.Update(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteQuotaRule godoc
+// @Summary Supprimer un quota (Admin)
+// @Description Supprime une règle de quota (admin uniquement)
+// @Tags Quotas
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID du quota"
+// @Success 200 {object} map[string]string "Quota supprimé"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Quota non trouvé"
+// @Router /api/admin/quotas/{id} [delete]
+func (h *Handler) DeleteQuotaRule(c *gin.Context) {
+	if err := h.services.Quotas.Delete(actorFromContext(c), paramID(c, "id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota rule deleted"})
+}
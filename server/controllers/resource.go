@@ -3,32 +3,28 @@ package controllers
 import (
 	"net/http"
 
-	"github.com/ericp/ylab-hackathon/config"
 	"github.com/ericp/ylab-hackathon/models"
 	"github.com/gin-gonic/gin"
 )
 
 // GetResources godoc
 // @Summary Liste des ressources
-// @Description Récupère la liste de toutes les ressources actives, avec filtre optionnel par type
+// @Description Récupère la liste de toutes les ressources actives de l'événement en cours, avec filtre optionnel par type
 // @Tags Resources
 // @Produce json
 // @Param type query string false "Type de ressource" Enums(service, matériel, avantage)
 // @Success 200 {array} models.Resource "Liste des ressources"
 // @Failure 500 {object} map[string]string "Erreur serveur"
 // @Router /api/resources [get]
-func GetResources(c *gin.Context) {
-	var resources []models.Resource
-	query := config.DB.Where("is_active = ?", true)
-
-	// Optional filters
-	resourceType := c.Query("type")
-	if resourceType != "" {
-		query = query.Where("type = ?", resourceType)
+func (h *Handler) GetResources(c *gin.Context) {
+	eventID, ok := h.currentEventID(c)
+	if !ok {
+		return
 	}
 
-	if err := query.Find(&resources).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch resources"})
+	resources, err := h.services.Resources.ListActive(eventID, c.Query("type"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -44,12 +40,180 @@ func GetResources(c *gin.Context) {
 // @Success 200 {object} models.Resource "Détails de la ressource"
 // @Failure 404 {object} map[string]string "Ressource non trouvée"
 // @Router /api/resources/{id} [get]
-func GetResource(c *gin.Context) {
-	id := c.Param("id")
+func (h *Handler) GetResource(c *gin.Context) {
+	resource, err := h.services.Resources.Get(paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// GetAllResourcesAdmin godoc
+// @Summary Liste de toutes les ressources (Admin)
+// @Description Récupère toutes les ressources de l'événement sélectionné, y compris les ressources inactives ou supprimées (admin uniquement)
+// @Tags Resources
+// @Produce json
+// @Security BearerAuth
+// @Param type query string false "Type de ressource" Enums(service, matériel, avantage)
+// @Param deleted query bool false "Lister uniquement les ressources supprimées"
+// @Success 200 {array} models.Resource "Liste des ressources"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/resources [get]
+func (h *Handler) GetAllResourcesAdmin(c *gin.Context) {
+	resources, err := h.services.Resources.ListAll(eventIDFromContext(c), c.Query("type"), c.Query("deleted") == "true")
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resources)
+}
+
+// CreateResource godoc
+// @Summary Créer une ressource (Admin)
+// @Description Ajoute une nouvelle ressource au catalogue de la boutique (admin uniquement)
+// @Tags Resources
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param resource body models.CreateResourceRequest true "Détails de la ressource"
+// @Success 201 {object} models.Resource "Ressource créée"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/resources [post]
+func (h *Handler) CreateResource(c *gin.Context) {
+	var req models.CreateResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resource, err := h.services.Resources.Create(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resource)
+}
+
+// UpdateResource godoc
+// @Summary Modifier une ressource (Admin)
+// @Description Met à jour les champs fournis d'une ressource (admin uniquement)
+// @Tags Resources
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la ressource"
+// @Param resource body models.UpdateResourceRequest true "Champs à modifier"
+// @Success 200 {object} models.Resource "Ressource mise à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Ressource non trouvée"
+// @Router /api/admin/resources/{id} [put]
+func (h *Handler) UpdateResource(c *gin.Context) {
+	var req models.UpdateResourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resource, err := h.services.Resources.Update(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// ActivateResource godoc
+// @Summary Activer une ressource (Admin)
+// @Description Rend une ressource de nouveau disponible à l'achat (admin uniquement)
+// @Tags Resources
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la ressource"
+// @Success 200 {object} models.Resource "Ressource activée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Ressource non trouvée"
+// @Router /api/admin/resources/{id}/activate [post]
+func (h *Handler) ActivateResource(c *gin.Context) {
+	h.setResourceActive(c, true)
+}
+
+// DeactivateResource godoc
+// @Summary Désactiver une ressource (Admin)
+// @Description Retire une ressource de la boutique sans la supprimer (admin uniquement)
+// @Tags Resources
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la ressource"
+// @Success 200 {object} models.Resource "Ressource désactivée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Ressource non trouvée"
+// @Router /api/admin/resources/{id}/deactivate [post]
+func (h *Handler) DeactivateResource(c *gin.Context) {
+	h.setResourceActive(c, false)
+}
+
+func (h *Handler) setResourceActive(c *gin.Context, active bool) {
+	resource, err := h.services.Resources.SetActive(actorFromContext(c), paramID(c, "id"), active)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resource)
+}
+
+// DeleteResource godoc
+// @Summary Supprimer une ressource (Admin)
+// @Description Supprime (soft delete) une ressource sans achat en attente ni matériel non retourné (admin uniquement)
+// @Tags Resources
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la ressource"
+// @Success 200 {object} map[string]string "Ressource supprimée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Ressource non trouvée"
+// @Failure 409 {object} map[string]interface{} "Achats en cours sur la ressource"
+// @Router /api/admin/resources/{id} [delete]
+func (h *Handler) DeleteResource(c *gin.Context) {
+	if err := h.services.Resources.Delete(actorFromContext(c), paramID(c, "id")); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Resource deleted"})
+}
 
-	var resource models.Resource
-	if err := config.DB.First(&resource, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+// RestoreResource godoc
+// @Summary Restaurer une ressource supprimée (Admin)
+// @Description Annule la suppression d'une ressource (admin uniquement)
+// @Tags Resources
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la ressource"
+// @Success 200 {object} models.Resource "Ressource restaurée"
+// @Failure 400 {object} map[string]string "Ressource non supprimée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Ressource non trouvée"
+// @Router /api/admin/resources/{id}/restore [post]
+func (h *Handler) RestoreResource(c *gin.Context) {
+	resource, err := h.services.Resources.Restore(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GetSessions godoc
+// @Summary Sessions ouvertes (Admin)
+// @Description Liste les sessions des équipes et des admins, des plus récemment utilisées aux plus anciennes, avec l'adresse IP et le navigateur qui les utilisent. Le nombre total de résultats est renvoyé dans l'en-tête X-Total-Count (admin uniquement)
+// @Tags Sessions
+// @Produce json
+// @Security BearerAuth
+// @Param user_type query string false "Filtrer par type de compte" Enums(team, admin)
+// @Param user_id query int false "Filtrer par compte (avec user_type)"
+// @Param active query bool false "Seulement les sessions ouvertes (défaut true)"
+// @Param limit query int false "Nombre maximum de résultats (défaut 100, max 1000)"
+// @Param offset query int false "Nombre de résultats à ignorer"
+// @Success 200 {array} models.Session "Sessions"
+// @Failure 400 {object} map[string]string "Filtre invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/sessions [get]
+func (h *Handler) GetSessions(c *gin.Context) {
+	query := h.db.Model(&models.Session{})
+
+	if userType := c.Query("user_type"); userType != "" {
+		if userType != "team" && userType != "admin" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user type"})
+			return
+		}
+		query = query.Where("user_type = ?", userType)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	active, err := strconv.ParseBool(c.DefaultQuery("active", "true"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active filter"})
+		return
+	}
+	if active {
+		query = query.Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit (1-1000)"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	var sessions []models.Session
+	if err := query.Order("last_used_at DESC, id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession godoc
+// @Summary Révoquer une session (Admin)
+// @Description Ferme une session compromise : ses tokens sont refusés immédiatement et son refresh token ne peut plus servir (admin uniquement)
+// @Tags Sessions
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de la session"
+// @Success 200 {object} models.Session "Session révoquée"
+// @Failure 400 {object} map[string]string "Session déjà révoquée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Session introuvable"
+// @Router /api/admin/sessions/{id}/revoke [post]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	session, err := h.services.Sessions.Revoke(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
@@ -2,10 +2,9 @@ package controllers
 
 import (
 	"net/http"
+	"strconv"
 
-	"github.com/ericp/ylab-hackathon/config"
 	"github.com/ericp/ylab-hackathon/models"
-	"github.com/ericp/ylab-hackathon/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,13 +18,10 @@ import (
 // @Failure 401 {object} map[string]string "Non authentifié"
 // @Failure 404 {object} map[string]string "Équipe non trouvée"
 // @Router /api/team/profile [get]
-func GetTeamProfile(c *gin.Context) {
-	userID, _ := c.Get("user_id")
-	teamID := userID.(uint)
-
-	var team models.Team
-	if err := config.DB.First(&team, teamID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+func (h *Handler) GetTeamProfile(c *gin.Context) {
+	team, err := h.services.Teams.Get(teamIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
@@ -45,101 +41,227 @@ func GetTeamProfile(c *gin.Context) {
 // @Failure 401 {object} map[string]string "Non authentifié"
 // @Failure 404 {object} map[string]string "Équipe non trouvée"
 // @Router /api/team/profile [put]
-func UpdateTeamProfile(c *gin.Context) {
-	userID, _ := c.Get("user_id")
-	teamID := userID.(uint)
-
+func (h *Handler) UpdateTeamProfile(c *gin.Context) {
 	var req models.UpdateTeamProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Check if valid email (has @ and .)
-	if !utils.IsValidEmail(req.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+	team, err := h.services.Teams.UpdateProfile(teamIDFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	var team models.Team
-	if err := config.DB.First(&team, teamID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+	c.JSON(http.StatusOK, team.ToResponse())
+}
+
+func (h *Handler) GetTeamPurchases(c *gin.Context) {
+	q, err := parsePurchaseQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	q.TeamID = strconv.FormatUint(uint64(teamIDFromContext(c)), 10) // A team only sees its own purchases
 
-	// Check if email is already taken by another team
-	var existingTeam models.Team
-	if err := config.DB.Where("email = ? AND id != ?", req.Email, teamID).First(&existingTeam).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already taken"})
+	h.respondPurchasePage(c, q, "Resource")
+}
+
+func (h *Handler) GetTeamVotes(c *gin.Context) {
+	votes, err := h.services.Votes.ForTeam(teamIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	team.Email = req.Email
-	if err := config.DB.Save(&team).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+	c.JSON(http.StatusOK, votes)
+}
+
+// GetAllTeams godoc
+// @Summary Liste de toutes les équipes (Admin)
+// @Description Récupère toutes les équipes de l'événement sélectionné pour le filtre admin
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.TeamResponse "Liste des équipes"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 500 {object} map[string]string "Erreur serveur"
+// @Router /api/admin/teams [get]
+func (h *Handler) GetAllTeams(c *gin.Context) {
+	teams, err := h.services.Teams.List(eventIDFromContext(c))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, team.ToResponse())
+	// Convert to response format
+	teamResponses := make([]models.TeamResponse, len(teams))
+	for i, team := range teams {
+		teamResponses[i] = team.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, teamResponses)
 }
 
-func GetTeamPurchases(c *gin.Context) {
-	userID, _ := c.Get("user_id")
-	teamID := userID.(uint)
+// CreateTeam godoc
+// @Summary Créer une équipe (Admin)
+// @Description Crée une équipe avec un mot de passe généré, renvoyé une seule fois (admin uniquement)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param team body models.CreateTeamRequest true "Détails de l'équipe"
+// @Success 201 {object} models.TeamCredentialsResponse "Équipe créée et mot de passe généré"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Router /api/admin/teams [post]
+func (h *Handler) CreateTeam(c *gin.Context) {
+	var req models.CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	team, password, err := h.services.Teams.Create(actorFromContext(c), req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 
-	query := config.DB.Where("team_id = ?", teamID).Preload("Resource")
+	c.JSON(http.StatusCreated, models.TeamCredentialsResponse{
+		Team:     team.ToResponse(),
+		Password: password,
+	})
+}
 
-	// Optional filter for items that need to be returned
-	needsReturn := c.Query("needs_return")
-	if needsReturn == "true" {
-		query = query.Where("needs_return = ? AND status = ? AND is_returned = ?", true, models.StatusConfirmed, false)
+// UpdateTeam godoc
+// @Summary Modifier une équipe (Admin)
+// @Description Renomme une équipe ou change son email (admin uniquement)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'équipe"
+// @Param team body models.UpdateTeamRequest true "Champs à modifier"
+// @Success 200 {object} models.TeamResponse "Équipe mise à jour"
+// @Failure 400 {object} map[string]string "Requête invalide"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id} [put]
+func (h *Handler) UpdateTeam(c *gin.Context) {
+	var req models.UpdateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	var purchases []models.Purchase
-	if err := query.Order("purchase_date DESC").Find(&purchases).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch purchases"})
+	team, err := h.services.Teams.Update(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, purchases)
+	c.JSON(http.StatusOK, team.ToResponse())
 }
 
-func GetTeamVotes(c *gin.Context) {
-	userID, _ := c.Get("user_id")
-	teamID := userID.(uint)
-
-	var votes []models.Vote
-	if err := config.DB.Where("team_id = ?", teamID).Preload("Poll").Find(&votes).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch votes"})
+// ResetTeamPassword godoc
+// @Summary Régénérer le mot de passe d'une équipe (Admin)
+// @Description Génère un nouveau mot de passe pour l'équipe, renvoyé une seule fois (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'équipe"
+// @Success 200 {object} models.TeamCredentialsResponse "Nouveau mot de passe"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id}/reset-password [post]
+func (h *Handler) ResetTeamPassword(c *gin.Context) {
+	team, password, err := h.services.Teams.ResetPassword(actorFromContext(c), paramID(c, "id"))
+	if err != nil {
+		respondError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, votes)
+	c.JSON(http.StatusOK, models.TeamCredentialsResponse{
+		Team:     team.ToResponse(),
+		Password: password,
+	})
 }
 
-// GetAllTeams godoc
-// @Summary Liste de toutes les équipes (Admin)
-// @Description Récupère toutes les équipes pour le filtre admin
+// AdjustTeamCredit godoc
+// @Summary Modifier le crédit d'une équipe (Admin)
+// @Description Fixe ou ajuste le crédit d'une équipe avec une justification obligatoire, enregistrée dans l'historique des crédits (admin uniquement)
 // @Tags Admin
+// @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {array} models.TeamResponse "Liste des équipes"
+// @Param id path int true "ID de l'équipe"
+// @Param credit body models.AdjustCreditRequest true "Mode, montant et justification"
+// @Success 200 {object} models.TeamResponse "Équipe mise à jour"
+// @Failure 400 {object} map[string]string "Requête invalide ou crédit insuffisant"
 // @Failure 401 {object} map[string]string "Non authentifié"
 // @Failure 403 {object} map[string]string "Accès admin requis"
-// @Failure 500 {object} map[string]string "Erreur serveur"
-// @Router /api/admin/teams [get]
-func GetAllTeams(c *gin.Context) {
-	var teams []models.Team
-	if err := config.DB.Find(&teams).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch teams"})
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id}/credit [post]
+func (h *Handler) AdjustTeamCredit(c *gin.Context) {
+	var req models.AdjustCreditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Convert to response format
-	teamResponses := make([]models.TeamResponse, len(teams))
-	for i, team := range teams {
-		teamResponses[i] = team.ToResponse()
+	team, err := h.services.Teams.AdjustCredit(actorFromContext(c), paramID(c, "id"), req)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, teamResponses)
+	c.JSON(http.StatusOK, team.ToResponse())
+}
+
+// SuspendTeam godoc
+// @Summary Suspendre une équipe (Admin)
+// @Description Empêche l'équipe de se connecter et d'utiliser la boutique (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'équipe"
+// @Success 200 {object} models.TeamResponse "Équipe suspendue"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id}/suspend [post]
+func (h *Handler) SuspendTeam(c *gin.Context) {
+	h.setTeamSuspended(c, true)
+}
+
+// UnsuspendTeam godoc
+// @Summary Réactiver une équipe (Admin)
+// @Description Lève la suspension d'une équipe (admin uniquement)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID de l'équipe"
+// @Success 200 {object} models.TeamResponse "Équipe réactivée"
+// @Failure 401 {object} map[string]string "Non authentifié"
+// @Failure 403 {object} map[string]string "Accès admin requis"
+// @Failure 404 {object} map[string]string "Équipe non trouvée"
+// @Router /api/admin/teams/{id}/unsuspend [post]
+func (h *Handler) UnsuspendTeam(c *gin.Context) {
+	h.setTeamSuspended(c, false)
+}
+
+func (h *Handler) setTeamSuspended(c *gin.Context, suspended bool) {
+	team, err := h.services.Teams.SetSuspended(actorFromContext(c), paramID(c, "id"), suspended)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, team.ToResponse())
 }
@@ -0,0 +1,111 @@
+package emails
+
+import "time"
+
+// Item is a purchased resource listed in an email
+type Item struct {
+	Resource          string
+	Quantity          int
+	RequestedQuantity int        // Only differs from Quantity when an admin approved less
+	ReturnBy          *time.Time // Return deadline of a confirmed rental
+}
+
+// PurchaseData is the data of the emails about purchases
+type PurchaseData struct {
+	Team  string
+	Items []Item
+}
+
+// PurchaseSummaryData is the data of PurchaseProcessed, sent once a batch of
+// purchases has been processed
+type PurchaseSummaryData struct {
+	Team      string
+	Confirmed []Item
+	Adjusted  []Item
+	Cancelled []Item
+}
+
+// PollPayoutData is the data of PollPayout
+type PollPayoutData struct {
+	Team          string
+	Question      string
+	WinningOption string
+	Staked        int
+	Payout        int
+}
+
+// ReturnData is the data of ReturnReminder and ReturnOverdue, about rented items the
+// team has not returned yet
+type ReturnData struct {
+	Team  string
+	Items []Item
+}
+
+// NotificationData is the data of StaffNotification
+type NotificationData struct {
+	Title   string
+	Message string
+}
+
+// PasswordResetData is the data of PasswordReset, sent to teams and admins
+type PasswordResetData struct {
+	Name             string // Name of the team or username of the admin
+	URL              string
+	ExpiresInMinutes int
+}
+
+// sampleReturnBy is the return deadline shown in the previews
+var sampleReturnBy = time.Date(2025, 11, 21, 17, 0, 0, 0, time.Local)
+
+// samples is the data used to preview the templates
+var samples = map[string]interface{}{
+	PurchaseReceived: PurchaseData{
+		Team: "Les <Débogueurs>",
+		Items: []Item{
+			{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2},
+			{Resource: "Crédits cloud", Quantity: 10, RequestedQuantity: 10},
+		},
+	},
+	PurchaseConfirmed: PurchaseData{
+		Team:  "Les <Débogueurs>",
+		Items: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2, ReturnBy: &sampleReturnBy}},
+	},
+	PurchaseRejected: PurchaseData{
+		Team:  "Les <Débogueurs>",
+		Items: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2}},
+	},
+	PurchaseProcessed: PurchaseSummaryData{
+		Team:      "Les <Débogueurs>",
+		Confirmed: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2, ReturnBy: &sampleReturnBy}},
+		Adjusted:  []Item{{Resource: "Crédits cloud", Quantity: 5, RequestedQuantity: 10}},
+		Cancelled: []Item{{Resource: "Écran 27 pouces", Quantity: 1, RequestedQuantity: 1}},
+	},
+	PurchaseReturned: PurchaseData{
+		Team:  "Les <Débogueurs>",
+		Items: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2}},
+	},
+	PollPayout: PollPayoutData{
+		Team:          "Les <Débogueurs>",
+		Question:      "Qui remporte le prix du jury ?",
+		WinningOption: "Équipe Alpha",
+		Staked:        300,
+		Payout:        540,
+	},
+	ReturnReminder: ReturnData{
+		Team:  "Les <Débogueurs>",
+		Items: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2, ReturnBy: &sampleReturnBy}},
+	},
+	ReturnOverdue: ReturnData{
+		Team:  "Les <Débogueurs>",
+		Items: []Item{{Resource: "Raspberry Pi 5", Quantity: 2, RequestedQuantity: 2, ReturnBy: &sampleReturnBy}},
+	},
+	StaffNotification: NotificationData{
+		Title:   "Nouvelle commande en attente",
+		Message: "Les <Débogueurs> ont commandé 2 × Raspberry Pi 5 pour 300 crédits.",
+	},
+	PasswordReset: PasswordResetData{
+		Name:             "Les <Débogueurs>",
+		URL:              "https://hackathon.example.com/reset-password?token=3f9c2a",
+		ExpiresInMinutes: 60,
+	},
+}
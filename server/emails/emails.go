@@ -0,0 +1,197 @@
+// Package emails renders the transactional emails from the templates embedded in
+// the binary. Every email has an HTML body, a plain-text alternative and a subject,
+// written once per locale in templates/<locale>/<name>.html and <name>.txt.
+package emails
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var files embed.FS
+
+// Locales of the templates
+const (
+	LocaleFR = "fr"
+	LocaleEN = "en"
+
+	// DefaultLocale is used for recipients without a known locale
+	DefaultLocale = LocaleFR
+)
+
+// Locales lists the supported locales
+var Locales = []string{LocaleFR, LocaleEN}
+
+// Names of the templates
+const (
+	PurchaseReceived  = "purchase_received"
+	PurchaseConfirmed = "purchase_confirmed"
+	PurchaseRejected  = "purchase_rejected"
+	PurchaseProcessed = "purchase_processed" // Summary of a processed batch
+	PurchaseReturned  = "purchase_returned"
+	ReturnReminder    = "return_reminder" // Sent before the return deadline of rented items
+	ReturnOverdue     = "return_overdue"  // Sent once the return deadline has passed
+	PollPayout        = "poll_payout"
+	StaffNotification = "staff_notification" // Sent to the email channels of the staff
+	PasswordReset     = "password_reset"
+)
+
+// Message is a rendered email
+type Message struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// View is the data given to the templates: the variables shared by every email and
+// the data of the template, e.g. {{.Event}} and {{.Data.Team}}
+type View struct {
+	Event string // Name of the event, e.g. "YLab Hackathon 2025"
+	Data  interface{}
+}
+
+// template is a template in one locale
+type template struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template // Also defines the "subject" template
+}
+
+// Registry holds the parsed templates by name and locale
+type Registry struct {
+	event     string
+	templates map[string]map[string]template
+}
+
+// NewRegistry parses the embedded templates. Every template must exist in every
+// locale, so that no recipient ends up without an email.
+func NewRegistry(event string) (*Registry, error) {
+	r := &Registry{event: event, templates: map[string]map[string]template{}}
+
+	for _, locale := range Locales {
+		entries, err := files.ReadDir("templates/" + locale)
+		if err != nil {
+			return nil, fmt.Errorf("locale %s: %w", locale, err)
+		}
+		for _, entry := range entries {
+			name := strings.TrimSuffix(entry.Name(), ".html")
+			if name == entry.Name() || name == "layout" {
+				continue
+			}
+
+			t, err := parse(locale, name)
+			if err != nil {
+				return nil, fmt.Errorf("template %s/%s: %w", locale, name, err)
+			}
+			if r.templates[name] == nil {
+				r.templates[name] = map[string]template{}
+			}
+			r.templates[name][locale] = t
+		}
+	}
+
+	for name, byLocale := range r.templates {
+		if _, ok := samples[name]; !ok {
+			return nil, fmt.Errorf("template %s has no sample data", name)
+		}
+		for _, locale := range Locales {
+			if _, ok := byLocale[locale]; !ok {
+				return nil, fmt.Errorf("template %s is missing in locale %s", name, locale)
+			}
+		}
+	}
+	return r, nil
+}
+
+// parse reads a template and the layout of its locale
+func parse(locale, name string) (template, error) {
+	dir := "templates/" + locale + "/"
+
+	html, err := htmltemplate.ParseFS(files, dir+"layout.html", dir+name+".html")
+	if err != nil {
+		return template{}, err
+	}
+	text, err := texttemplate.ParseFS(files, dir+"layout.txt", dir+name+".txt")
+	if err != nil {
+		return template{}, err
+	}
+	if text.Lookup("subject") == nil {
+		return template{}, fmt.Errorf("%s.txt does not define a subject", name)
+	}
+	return template{html: html, text: text}, nil
+}
+
+// Names returns the names of the templates, sorted
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has tells whether a template exists
+func (r *Registry) Has(name string) bool {
+	_, ok := r.templates[name]
+	return ok
+}
+
+// IsLocale tells whether a locale is supported
+func IsLocale(locale string) bool {
+	for _, l := range Locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// Render renders a template in the given locale, or in the default one when the
+// locale is not supported
+func (r *Registry) Render(name, locale string, data interface{}) (*Message, error) {
+	return r.RenderFor("", name, locale, data)
+}
+
+// RenderFor is Render for a given event name, the one of the registry when empty
+func (r *Registry) RenderFor(event, name, locale string, data interface{}) (*Message, error) {
+	if event == "" {
+		event = r.event
+	}
+	byLocale, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown email template %q", name)
+	}
+	if !IsLocale(locale) {
+		locale = DefaultLocale
+	}
+	t := byLocale[locale]
+	view := View{Event: event, Data: data}
+
+	var subject, html, text bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return nil, fmt.Errorf("render subject of %s/%s: %w", locale, name, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", view); err != nil {
+		return nil, fmt.Errorf("render %s/%s.html: %w", locale, name, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "layout", view); err != nil {
+		return nil, fmt.Errorf("render %s/%s.txt: %w", locale, name, err)
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    html.String(),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+	}, nil
+}
+
+// Preview renders a template with its sample data
+func (r *Registry) Preview(name, locale string) (*Message, error) {
+	return r.Render(name, locale, samples[name])
+}
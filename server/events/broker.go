@@ -0,0 +1,125 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Audience tells which subscribers receive an event
+type Audience string
+
+const (
+	AudienceTeam   Audience = "team"  // Only the team identified by Event.TeamID
+	AudienceAdmins Audience = "admin" // The admins working on the event identified by Event.EventID
+)
+
+// Event types pushed to the clients
+const (
+	PurchaseCreated   = "purchase.created"
+	PurchaseConfirmed = "purchase.confirmed"
+	PurchaseAdjusted  = "purchase.adjusted"
+	PurchaseCancelled = "purchase.cancelled"
+	PurchaseReturned  = "purchase.returned"
+	CreditUpdated     = "credit.updated"
+	VoteCreated       = "vote.created"
+)
+
+// subscriberBuffer is the number of events kept for a slow client before new ones are dropped
+const subscriberBuffer = 32
+
+type Event struct {
+	ID       uint64      `json:"id"`
+	Type     string      `json:"type"`
+	Audience Audience    `json:"-"`
+	TeamID   uint        `json:"team_id,omitempty"`
+	EventID  uint        `json:"-"` // Hackathon event the change belongs to
+	Data     interface{} `json:"data"`
+	At       time.Time   `json:"at"`
+}
+
+// Subscriber receives the events addressed to one connected user
+type Subscriber struct {
+	UserType string
+	UserID   uint
+	EventID  uint // Event of the team, or selected by the admin
+	Events   chan Event
+}
+
+func (s *Subscriber) accepts(event Event) bool {
+	switch event.Audience {
+	case AudienceAdmins:
+		return s.UserType == "admin" && s.EventID == event.EventID
+	case AudienceTeam:
+		return s.UserType == "team" && s.UserID == event.TeamID
+	}
+	return false
+}
+
+// Broker fans out events published by the controllers to the connected clients.
+// It lives in the server process: events are not persisted and a client only
+// receives what is published while it is connected.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscriber]struct{}
+	lastID      atomic.Uint64
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[*Subscriber]struct{})}
+}
+
+// Subscribe registers a client; Unsubscribe must be called when it disconnects
+func (b *Broker) Subscribe(userType string, userID, eventID uint) *Subscriber {
+	sub := &Subscriber{
+		UserType: userType,
+		UserID:   userID,
+		EventID:  eventID,
+		Events:   make(chan Event, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.Events)
+	}
+	b.mu.Unlock()
+}
+
+// Publish delivers an event to every matching subscriber without blocking:
+// a client whose buffer is full misses the event
+func (b *Broker) Publish(event Event) {
+	event.ID = b.lastID.Add(1)
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.accepts(event) {
+			continue
+		}
+		select {
+		case sub.Events <- event:
+		default:
+		}
+	}
+}
+
+// ToTeam publishes an event to a team
+func (b *Broker) ToTeam(teamID uint, eventType string, data interface{}) {
+	b.Publish(Event{Type: eventType, Audience: AudienceTeam, TeamID: teamID, Data: data})
+}
+
+// ToAdmins publishes an event to the admins working on an event
+func (b *Broker) ToAdmins(eventID uint, eventType string, data interface{}) {
+	b.Publish(Event{Type: eventType, Audience: AudienceAdmins, EventID: eventID, Data: data})
+}
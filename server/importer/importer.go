@@ -0,0 +1,185 @@
+// Package importer creates teams and resources from the CSV lists received before
+// an event. An import is all-or-nothing: every row is validated first, and nothing
+// is written when one of them is invalid.
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+)
+
+var (
+	// ErrInvalidFile is returned when the file cannot be read as a CSV list
+	ErrInvalidFile = errors.New("invalid file")
+	// ErrInvalidRows is returned when at least one row is rejected; the report lists them
+	ErrInvalidRows = errors.New("the file contains invalid rows")
+)
+
+// RowError explains why a row of the file was rejected
+type RowError struct {
+	Row     int    `json:"row"` // Line number in the file, the header being line 1
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e RowError) String() string {
+	if e.Column != "" {
+		return fmt.Sprintf("row %d, %s: %s", e.Row, e.Column, e.Message)
+	}
+	return fmt.Sprintf("row %d: %s", e.Row, e.Message)
+}
+
+// Credential is the generated password of an imported team, only reported once
+type Credential struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Report describes the outcome of an import
+type Report struct {
+	Rows        int          `json:"rows"`
+	Created     int          `json:"created"`
+	Errors      []RowError   `json:"errors"`
+	Credentials []Credential `json:"credentials,omitempty"`
+}
+
+func (r *Report) fail(row int, column, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, RowError{Row: row, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// Options of an import
+type Options struct {
+	Event   models.Event // Event the rows are added to, whose starting credit is the default one
+	AdminID *uint        // Admin who runs the import, recorded in the opening ledger entries
+}
+
+// record is one data row of the file, with its cells indexed by column name
+type record struct {
+	line   int
+	values map[string]string
+}
+
+func (r record) get(column string) string {
+	return strings.TrimSpace(r.values[column])
+}
+
+// readCSV reads a file with a header row. Columns are matched by name, in any order
+// and case. The separator is detected from the header so that files saved by a
+// French spreadsheet (";") are accepted.
+func readCSV(r io.Reader, required, optional []string) ([]record, error) {
+	records, err := parseCSV(r, required, optional)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+	return records, nil
+}
+
+func parseCSV(r io.Reader, required, optional []string) ([]record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\uFEFF")
+
+	firstLine, _, _ := strings.Cut(text, "\n")
+	reader := csv.NewReader(strings.NewReader(text))
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	all := append(append([]string{}, required...), optional...)
+	known := map[string]bool{}
+	for _, column := range all {
+		known[column] = true
+	}
+	columns := make([]string, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("unknown column %q (expected %s)", name, strings.Join(all, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		seen[name] = true
+		columns[i] = name
+	}
+	for _, column := range required {
+		if !seen[column] {
+			return nil, fmt.Errorf("missing column %q", column)
+		}
+	}
+
+	records := []record{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		rec := record{line: line, values: map[string]string{}}
+		empty := true
+		for j, value := range row {
+			if j < len(columns) {
+				rec.values[columns[j]] = value
+			}
+			if strings.TrimSpace(value) != "" {
+				empty = false
+			}
+		}
+		if !empty {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// parseInt reads an optional integer cell, def being used when the cell is empty
+func parseInt(rec record, column string, def int, report *Report) (int, bool) {
+	value := rec.get(column)
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		report.fail(rec.line, column, "%q is not a number", value)
+		return 0, false
+	}
+	return n, true
+}
+
+// parseBool reads an optional yes/no cell, in English or French
+func parseBool(rec record, column string, def bool, report *Report) (bool, bool) {
+	switch strings.ToLower(rec.get(column)) {
+	case "":
+		return def, true
+	case "true", "1", "yes", "y", "oui", "o", "vrai":
+		return true, true
+	case "false", "0", "no", "n", "non", "faux":
+		return false, true
+	}
+	report.fail(rec.line, column, "%q is not a yes/no value", rec.get(column))
+	return false, false
+}
@@ -0,0 +1,118 @@
+package importer
+
+import (
+	"io"
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+// ResourceColumns lists the columns of an inventory file
+var ResourceColumns = struct{ Required, Optional []string }{
+	Required: []string{"name", "type", "cost", "quantity", "max_per_team"},
+	Optional: []string{"description", "image_url", "is_active", "is_non_returnable", "low_stock_threshold", "rental_minutes"},
+}
+
+type resourceRow struct {
+	line     int
+	resource models.Resource
+}
+
+// ImportResources creates the resources listed in a CSV file within tx. When a row
+// is invalid, nothing is written and ErrInvalidRows is returned with the report.
+func ImportResources(tx *gorm.DB, r io.Reader, opts Options) (*Report, error) {
+	records, err := readCSV(r, ResourceColumns.Required, ResourceColumns.Optional)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Rows: len(records), Errors: []RowError{}}
+	rows := make([]resourceRow, 0, len(records))
+	names := map[string]int{}
+
+	for _, rec := range records {
+		row := resourceRow{line: rec.line}
+		res := &row.resource
+		valid := true
+
+		res.Name = rec.get("name")
+		res.Description = rec.get("description")
+		res.ImageURL = rec.get("image_url")
+		res.Type = strings.ToLower(rec.get("type"))
+
+		var ok bool
+		if res.Cost, ok = parseInt(rec, "cost", 0, report); !ok {
+			valid = false
+		}
+		if res.Quantity, ok = parseInt(rec, "quantity", 0, report); !ok {
+			valid = false
+		}
+		if res.MaxPerTeam, ok = parseInt(rec, "max_per_team", 1, report); !ok {
+			valid = false
+		}
+		if res.IsActive, ok = parseBool(rec, "is_active", true, report); !ok {
+			valid = false
+		}
+		if res.IsNonReturnable, ok = parseBool(rec, "is_non_returnable", false, report); !ok {
+			valid = false
+		}
+		if res.LowStockThreshold, ok = parseInt(rec, "low_stock_threshold", 0, report); !ok {
+			valid = false
+		}
+		if res.RentalMinutes, ok = parseInt(rec, "rental_minutes", 0, report); !ok {
+			valid = false
+		}
+
+		if valid {
+			if msg := res.Validate(); msg != "" {
+				report.fail(rec.line, "", "%s", msg)
+				valid = false
+			}
+		}
+
+		key := strings.ToLower(res.Name)
+		if first, listed := names[key]; listed && res.Name != "" {
+			report.fail(rec.line, "name", "resource %q is already listed on row %d", res.Name, first)
+			valid = false
+		} else {
+			names[key] = rec.line
+		}
+
+		if valid {
+			rows = append(rows, row)
+		}
+	}
+
+	// Importing the same inventory twice must not duplicate the catalog
+	for _, row := range rows {
+		var count int64
+		if err := tx.Model(&models.Resource{}).Where("event_id = ? AND name = ?", opts.Event.ID, row.resource.Name).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			report.fail(row.line, "name", "resource %q already exists", row.resource.Name)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidRows
+	}
+
+	for _, row := range rows {
+		resource := row.resource
+		resource.EventID = opts.Event.ID
+		if err := tx.Create(&resource).Error; err != nil {
+			return nil, err
+		}
+		// GORM skips false booleans on create and would keep the column default (true)
+		if !resource.IsActive {
+			if err := tx.Model(&resource).Update("is_active", false).Error; err != nil {
+				return nil, err
+			}
+		}
+		report.Created++
+	}
+
+	return report, nil
+}
@@ -0,0 +1,187 @@
+package importer
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"gorm.io/gorm"
+)
+
+// TeamColumns lists the columns of a team file; password and credit are optional
+var TeamColumns = struct{ Required, Optional []string }{
+	Required: []string{"name", "email"},
+	Optional: []string{"password", "credit"},
+}
+
+// generatedPasswordLength is the length of the passwords generated for teams
+const generatedPasswordLength = 12
+
+type teamRow struct {
+	line      int
+	team      models.Team
+	password  string
+	generated bool
+}
+
+// ImportTeams creates the teams listed in a CSV file within tx. Teams without a
+// password get a generated one, returned in the report. When a row is invalid,
+// nothing is written and ErrInvalidRows is returned with the report.
+func ImportTeams(tx *gorm.DB, r io.Reader, opts Options) (*Report, error) {
+	records, err := readCSV(r, TeamColumns.Required, TeamColumns.Optional)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Rows: len(records), Errors: []RowError{}}
+	rows := make([]teamRow, 0, len(records))
+	names := map[string]int{}
+	emails := map[string]int{}
+
+	for _, rec := range records {
+		row := teamRow{line: rec.line}
+		valid := true
+
+		row.team.Name = rec.get("name")
+		if row.team.Name == "" {
+			report.fail(rec.line, "name", "name is required")
+			valid = false
+		} else if first, ok := names[strings.ToLower(row.team.Name)]; ok {
+			report.fail(rec.line, "name", "team %q is already listed on row %d", row.team.Name, first)
+			valid = false
+		} else {
+			names[strings.ToLower(row.team.Name)] = rec.line
+		}
+
+		row.team.Email = utils.NormalizeEmail(rec.get("email"))
+		if !utils.IsValidEmail(row.team.Email) {
+			report.fail(rec.line, "email", "invalid email %q", rec.get("email"))
+			valid = false
+		} else if first, ok := emails[row.team.Email]; ok {
+			report.fail(rec.line, "email", "email %q is already listed on row %d", row.team.Email, first)
+			valid = false
+		} else {
+			emails[row.team.Email] = rec.line
+		}
+
+		credit, ok := parseInt(rec, "credit", opts.Event.StartingCredit, report)
+		if ok && credit < 0 {
+			report.fail(rec.line, "credit", "credit must be positive")
+			ok = false
+		}
+		valid = valid && ok
+		row.team.EventID = opts.Event.ID
+		row.team.Credit = credit
+
+		row.password = rec.get("password")
+		if row.password == "" {
+			row.generated = true
+		}
+
+		if valid {
+			rows = append(rows, row)
+		}
+	}
+
+	// Names and emails must also be free in the event, deleted teams included
+	for _, row := range rows {
+		var count int64
+		if err := tx.Unscoped().Model(&models.Team{}).Where("event_id = ? AND name = ?", opts.Event.ID, row.team.Name).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			report.fail(row.line, "name", "team %q already exists", row.team.Name)
+		}
+		if err := tx.Unscoped().Model(&models.Team{}).Where("event_id = ? AND email = ?", opts.Event.ID, row.team.Email).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			report.fail(row.line, "email", "email %q is already used", row.team.Email)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return report, ErrInvalidRows
+	}
+
+	if err := hashTeamPasswords(rows); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		team := row.team
+		if err := tx.Create(&team).Error; err != nil {
+			return nil, err
+		}
+		// GORM skips zero values on create and would keep the column default
+		if team.Credit == 0 {
+			if err := tx.Model(&team).Update("credit", 0).Error; err != nil {
+				return nil, err
+			}
+		}
+		opening := models.CreditTransaction{
+			TeamID:       team.ID,
+			Amount:       team.Credit,
+			BalanceAfter: team.Credit,
+			Reason:       models.CreditReasonOpening,
+			AdminID:      opts.AdminID,
+		}
+		if err := tx.Create(&opening).Error; err != nil {
+			return nil, err
+		}
+
+		report.Created++
+		if row.generated {
+			report.Credentials = append(report.Credentials, Credential{Name: team.Name, Email: team.Email, Password: row.password})
+		}
+	}
+
+	return report, nil
+}
+
+// hashTeamPasswords generates the missing passwords and hashes all of them. bcrypt
+// is slow on purpose, so the hashes are computed in parallel.
+func hashTeamPasswords(rows []teamRow) error {
+	for i := range rows {
+		if rows[i].generated {
+			password, err := utils.GeneratePassword(generatedPasswordLength)
+			if err != nil {
+				return err
+			}
+			rows[i].password = password
+		}
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	jobs := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hash, err := utils.HashPassword(rows[i].password)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				rows[i].team.PasswordHash = hash
+			}
+		}()
+	}
+	for i := range rows {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
@@ -0,0 +1,82 @@
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestTeamLogin(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "s3cret-pass", 1000)
+
+	var login struct {
+		Token string              `json:"token"`
+		Team  models.TeamResponse `json:"team"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha", "password": "s3cret-pass"}), http.StatusOK, &login)
+	if login.Token == "" || login.Team.ID != team.ID {
+		t.Fatalf("unexpected login response: %+v", login)
+	}
+
+	// The issued token opens the team routes
+	var profile models.TeamResponse
+	s.expect(s.do(http.MethodGet, "/api/team/profile", login.Token, nil), http.StatusOK, &profile)
+	if profile.Name != "alpha" || profile.Credit != 1000 {
+		t.Errorf("unexpected profile: %+v", profile)
+	}
+
+	// Teams can also log in with their email
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": team.Email, "password": "s3cret-pass"}), http.StatusOK, nil)
+
+	// The email of the profile is matched regardless of case
+	s.expect(s.do(http.MethodPut, "/api/team/profile", login.Token, gin.H{"email": "Alpha.Team@Example.COM"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha.team@example.com", "password": "s3cret-pass"}), http.StatusOK, nil)
+	s.assertCredit(team.ID, 1000)
+
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha", "password": "wrong"}), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "nobody", "password": "s3cret-pass"}), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha"}), http.StatusBadRequest, nil)
+}
+
+func TestSuspendedTeamIsLockedOut(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "s3cret-pass", 1000)
+	token := s.token(team.ID, "team")
+
+	if err := s.db.Model(&team).Update("is_suspended", true).Error; err != nil {
+		t.Fatalf("suspend team: %v", err)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha", "password": "s3cret-pass"}), http.StatusForbidden, nil)
+	// Tokens issued before the suspension stop working too
+	s.expect(s.do(http.MethodGet, "/api/team/profile", token, nil), http.StatusForbidden, nil)
+}
+
+func TestAdminLogin(t *testing.T) {
+	s := newTestServer(t)
+	s.createAdmin("root", "admin-pass")
+	team := s.createTeam("alpha", "s3cret-pass", 1000)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/admin/login", "", gin.H{"username": "root", "password": "admin-pass"}), http.StatusOK, &login)
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", login.Token, nil), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/auth/admin/login", "", gin.H{"username": "root", "password": "wrong"}), http.StatusUnauthorized, nil)
+
+	// Team tokens are refused on admin routes and admin tokens on team routes
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", s.token(team.ID, "team"), nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", login.Token, nil), http.StatusForbidden, nil)
+}
+
+func TestProtectedRoutesRequireAToken(t *testing.T) {
+	s := newTestServer(t)
+
+	s.expect(s.do(http.MethodGet, "/api/team/profile", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", "not-a-jwt", nil), http.StatusUnauthorized, nil)
+}
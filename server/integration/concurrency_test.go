@@ -0,0 +1,176 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// concurrentWorkers is the number of requests sent at once
+const concurrentWorkers = 12
+
+// race sends the requests at the same time and returns their statuses. On SQLite the
+// write transactions still run one after the other (see openDatabase), so the tests
+// of this file only check the outcome of serialized requests. They race for the row
+// locks against Postgres, with TEST_POSTGRES_DSN.
+func (s *testServer) race(requests []*http.Request) []int {
+	statuses := make([]int, len(requests))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		wg.Add(1)
+		go func(i int, req *http.Request) {
+			defer wg.Done()
+			<-start
+			statuses[i] = s.serve(req).Code
+		}(i, req)
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+// countStatus counts the responses with the given status
+func countStatus(statuses []int, status int) int {
+	count := 0
+	for _, s := range statuses {
+		if s == status {
+			count++
+		}
+	}
+	return count
+}
+
+func TestConcurrentPurchasesCannotOverspend(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 100, 100, false)
+
+	// Each request costs 300: only 3 of them fit in the balance
+	requests := make([]*http.Request, concurrentWorkers)
+	for i := range requests {
+		if i%2 == 0 {
+			requests[i] = s.request(http.MethodPost, "/api/team/purchases", token, item(cloud.ID, 3))
+		} else {
+			requests[i] = s.request(http.MethodPost, "/api/team/purchases/batch", token,
+				gin.H{"items": []gin.H{item(cloud.ID, 3)}, "comment": "Needed for the demo of our project"})
+		}
+	}
+	statuses := s.race(requests)
+
+	if created := countStatus(statuses, http.StatusCreated); created != 3 {
+		t.Errorf("expected 3 purchases to go through, got %d (%v)", created, statuses)
+	}
+	s.assertCredit(team.ID, 100)
+	s.assertStock(cloud.ID, 100, 9)
+}
+
+func TestConcurrentVotesAndPurchasesShareTheBalance(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 100, 100, false)
+
+	// Stakes and purchases of 400 each, on separate polls: only 2 fit in the balance
+	requests := make([]*http.Request, concurrentWorkers)
+	for i := range requests {
+		if i%2 == 0 {
+			poll := s.createPoll(fmt.Sprintf("Poll %d", i), "Yes", "No")
+			requests[i] = s.request(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Yes", 400))
+		} else {
+			requests[i] = s.request(http.MethodPost, "/api/team/purchases", token, item(cloud.ID, 4))
+		}
+	}
+	statuses := s.race(requests)
+
+	if created := countStatus(statuses, http.StatusCreated); created != 2 {
+		t.Errorf("expected 2 requests to go through, got %d (%v)", created, statuses)
+	}
+	s.assertCredit(team.ID, 200)
+}
+
+func TestConcurrentVotesOnTheSamePoll(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	poll := s.createPoll("Who wins the jury prize?", "Alpha", "Beta")
+
+	requests := make([]*http.Request, concurrentWorkers)
+	for i := range requests {
+		requests[i] = s.request(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Alpha", 50))
+	}
+	statuses := s.race(requests)
+
+	if created := countStatus(statuses, http.StatusCreated); created != 1 {
+		t.Errorf("expected a single vote, got %d (%v)", created, statuses)
+	}
+	var votes int64
+	s.db.Model(&models.Vote{}).Where("team_id = ? AND poll_id = ?", team.ID, poll.ID).Count(&votes)
+	if votes != 1 {
+		t.Errorf("expected 1 vote recorded, got %d", votes)
+	}
+	s.assertCredit(team.ID, 950)
+}
+
+func TestConcurrentCancellationsRefundOnce(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 5))
+	path := fmt.Sprintf("/api/admin/purchases/%d/action", purchases[0].ID)
+
+	requests := make([]*http.Request, concurrentWorkers)
+	for i := range requests {
+		if i%2 == 0 {
+			requests[i] = s.request(http.MethodPost, path, adminToken, gin.H{"action": "cancel"})
+		} else {
+			requests[i] = s.request(http.MethodPost, "/api/admin/purchases/batch/action", adminToken,
+				gin.H{"items": []gin.H{{"purchase_id": purchases[0].ID, "action": "cancel"}}})
+		}
+	}
+	s.race(requests)
+
+	if p := s.purchase(purchases[0].ID); p.Status != models.StatusCancelled {
+		t.Errorf("expected status %q, got %q", models.StatusCancelled, p.Status)
+	}
+	var refunds int64
+	s.db.Model(&models.CreditTransaction{}).
+		Where("team_id = ? AND reason = ?", team.ID, models.CreditReasonPurchaseRefund).
+		Count(&refunds)
+	if refunds != 1 {
+		t.Errorf("expected a single refund, got %d", refunds)
+	}
+	s.assertCredit(team.ID, 1000)
+	s.assertStock(cloud.ID, 10, 0)
+}
+
+func TestConcurrentPurchasesCannotOversell(t *testing.T) {
+	s := newTestServer(t)
+	board := s.createResource("Raspberry Pi", 50, 2, 5, true)
+
+	requests := make([]*http.Request, concurrentWorkers)
+	teams := make([]models.Team, concurrentWorkers)
+	for i := range requests {
+		teams[i] = s.createTeam(fmt.Sprintf("team-%d", i), "pass", 1000)
+		requests[i] = s.request(http.MethodPost, "/api/team/purchases", s.token(teams[i].ID, "team"), item(board.ID, 1))
+	}
+	statuses := s.race(requests)
+
+	if created := countStatus(statuses, http.StatusCreated); created != 2 {
+		t.Errorf("expected the 2 units to be sold once, got %d purchases (%v)", created, statuses)
+	}
+	s.assertStock(board.ID, 2, 2)
+	for _, team := range teams {
+		var purchases int64
+		s.db.Model(&models.Purchase{}).Where("team_id = ?", team.ID).Count(&purchases)
+		s.assertCredit(team.ID, 1000-50*int(purchases))
+	}
+}
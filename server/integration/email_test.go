@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/gin-gonic/gin"
+)
+
+func TestEmailsUseTheTeamLocale(t *testing.T) {
+	s := newTestServer(t)
+	alpha := s.createTeam("alpha", "pass", 1000)
+	beta := s.createTeam("beta", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	// Team names are written by people and must not end up as markup
+	if err := s.db.Model(&alpha).Update("name", "<b>Alpha</b>").Error; err != nil {
+		t.Fatalf("rename team: %v", err)
+	}
+	alphaToken := s.token(alpha.ID, "team")
+	s.expect(s.do(http.MethodPut, "/api/team/profile", alphaToken, gin.H{"email": alpha.Email, "locale": "en"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/team/profile", alphaToken, gin.H{"email": alpha.Email, "locale": "de"}), http.StatusBadRequest, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", alphaToken, item(cloud.ID, 1)), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(beta.ID, "team"), item(cloud.ID, 1)), http.StatusCreated, nil)
+
+	english := s.mailer.waitForEmail(t, alpha.Email)
+	if english.Subject != "Purchase request received - "+testEventName {
+		t.Errorf("unexpected subject %q", english.Subject)
+	}
+	if !strings.Contains(english.HTML, "Hello &lt;b&gt;Alpha&lt;/b&gt;") || strings.Contains(english.HTML, "<b>Alpha</b>") {
+		t.Errorf("expected the team name to be escaped in:\n%s", english.HTML)
+	}
+	if !strings.Contains(english.Text, "Hello <b>Alpha</b>") || !strings.Contains(english.Text, "Cloud credits (quantity: 1)") {
+		t.Errorf("unexpected plain-text body:\n%s", english.Text)
+	}
+
+	french := s.mailer.waitForEmail(t, beta.Email)
+	if french.Subject != "Demande d'achat reçue - "+testEventName {
+		t.Errorf("unexpected subject %q", french.Subject)
+	}
+	if !strings.Contains(french.Text, "Merci de votre participation au "+testEventName) {
+		t.Errorf("expected the event name in:\n%s", french.Text)
+	}
+}
+
+func TestEmailTemplatePreview(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	token := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+
+	var list struct {
+		Templates []string `json:"templates"`
+		Locales   []string `json:"locales"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/emails/templates", token, nil), http.StatusOK, &list)
+	if len(list.Templates) == 0 || len(list.Locales) != 2 {
+		t.Fatalf("unexpected template list: %+v", list)
+	}
+
+	for _, name := range list.Templates {
+		for _, locale := range list.Locales {
+			var preview struct {
+				Template string `json:"template"`
+				Locale   string `json:"locale"`
+				emails.Message
+			}
+			s.expect(s.do(http.MethodGet, "/api/admin/emails/templates/"+name+"/preview?locale="+locale, token, nil), http.StatusOK, &preview)
+			if preview.Subject == "" || preview.HTML == "" || preview.Text == "" {
+				t.Errorf("%s/%s: incomplete preview %+v", locale, name, preview)
+			}
+			if !strings.Contains(preview.Subject, testEventName) {
+				t.Errorf("%s/%s: expected the event name in the subject %q", locale, name, preview.Subject)
+			}
+		}
+	}
+
+	w := s.do(http.MethodGet, "/api/admin/emails/templates/"+emails.PollPayout+"/preview?locale=en&format=html", token, nil)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expected an HTML page, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), `<html lang="en">`) {
+		t.Errorf("expected the English template:\n%s", w.Body.String())
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/admin/emails/templates/unknown/preview", token, nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/emails/templates/"+emails.PollPayout+"/preview?locale=de", token, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/emails/templates", s.token(team.ID, "team"), nil), http.StatusForbidden, nil)
+}
@@ -0,0 +1,196 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"github.com/gin-gonic/gin"
+)
+
+func TestEventsKeepTheirOwnData(t *testing.T) {
+	s := newTestServer(t)
+	token := s.token(s.createAdmin("root", "pass").ID, "admin")
+	past := s.createEvent("Hackathon 2024")
+
+	// Team names are only unique within an event
+	alpha := s.createTeam("alpha", "pass", 1000)
+	oldAlpha := s.createEventTeam(past.ID, "alpha", "old-pass", 300)
+	oldBoard := models.Resource{EventID: past.ID, Name: "Old board", Cost: 10, Quantity: 5, MaxPerTeam: 5, Type: "matériel", IsActive: true}
+	if err := s.db.Create(&oldBoard).Error; err != nil {
+		t.Fatalf("create resource: %v", err)
+	}
+	s.createResource("Cloud credits", 100, 10, 5, false)
+
+	var teams []models.TeamResponse
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", token, nil), http.StatusOK, &teams)
+	if len(teams) != 1 || teams[0].ID != alpha.ID {
+		t.Fatalf("expected the teams of the current event, got %+v", teams)
+	}
+	var resources []models.Resource
+	s.expect(s.do(http.MethodGet, "/api/resources", "", nil), http.StatusOK, &resources)
+	if len(resources) != 1 || resources[0].Name != "Cloud credits" {
+		t.Fatalf("expected the catalog of the current event, got %+v", resources)
+	}
+
+	// Past editions stay readable from an admin session that switches to them
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/switch", past.ID), token, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", token, nil), http.StatusOK, &teams)
+	if len(teams) != 1 || teams[0].ID != oldAlpha.ID || teams[0].Credit != 300 {
+		t.Fatalf("expected the teams of the selected event, got %+v", teams)
+	}
+	var verify struct {
+		Event models.Event `json:"event"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/auth/verify", token, nil), http.StatusOK, &verify)
+	if verify.Event.ID != past.ID {
+		t.Errorf("expected the selected event in verify, got %+v", verify.Event)
+	}
+
+	// Other sessions and the public pages stay on the current event
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", s.token(s.createAdmin("other", "pass").ID, "admin"), nil), http.StatusOK, &teams)
+	if len(teams) != 1 || teams[0].ID != alpha.ID {
+		t.Errorf("expected the other session on the current event, got %+v", teams)
+	}
+	s.expect(s.do(http.MethodGet, "/api/resources", "", nil), http.StatusOK, &resources)
+	if len(resources) != 1 || resources[0].Name != "Cloud credits" {
+		t.Errorf("expected the public catalog on the current event, got %+v", resources)
+	}
+
+	// Only the teams of the current event log in, and only buy from its catalog
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha", "password": "old-pass"}), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": "alpha", "password": "pass"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(alpha.ID, "team"), item(oldBoard.ID, 1)), http.StatusNotFound, nil)
+	s.assertCredit(alpha.ID, 1000)
+}
+
+func TestNewEventBecomesCurrent(t *testing.T) {
+	s := newTestServer(t)
+	token := s.token(s.createAdmin("root", "pass").ID, "admin")
+	viewer := s.token(s.createAdminWithRole("viewer", models.RoleViewer).ID, "admin")
+	alpha := s.createTeam("alpha", "pass", 1000)
+
+	edition := gin.H{
+		"name":            "Hackathon 2026",
+		"starts_at":       time.Now().Add(30 * 24 * time.Hour),
+		"ends_at":         time.Now().Add(31 * 24 * time.Hour),
+		"starting_credit": 500,
+		"primary_color":   "#0057b8",
+	}
+	s.expect(s.do(http.MethodPost, "/api/admin/events", viewer, edition), http.StatusForbidden, nil)
+
+	var next models.Event
+	s.expect(s.do(http.MethodPost, "/api/admin/events", token, edition), http.StatusCreated, &next)
+	if next.StartingCredit != 500 || next.IsCurrent {
+		t.Fatalf("unexpected event: %+v", next)
+	}
+	s.expect(s.do(http.MethodPost, "/api/admin/events", token, edition), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/events/%d", next.ID), token, gin.H{"ends_at": time.Now()}), http.StatusBadRequest, nil)
+
+	// The teams and resources created once switched belong to the new event
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/switch", next.ID), token, nil), http.StatusOK, nil)
+	var created models.TeamCredentialsResponse
+	s.expect(s.do(http.MethodPost, "/api/admin/teams", token, gin.H{"name": "alpha", "email": "alpha@example.com"}), http.StatusCreated, &created)
+	if created.Team.Credit != 500 {
+		t.Errorf("expected the starting credit of the event, got %+v", created.Team)
+	}
+	var cloud models.Resource
+	s.expect(s.do(http.MethodPost, "/api/admin/resources", token, gin.H{"name": "Cloud credits", "cost": 100, "quantity": 10, "max_per_team": 5, "type": "service"}), http.StatusCreated, &cloud)
+	if cloud.EventID != next.ID {
+		t.Errorf("expected the resource in the new event, got %+v", cloud)
+	}
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/activate", next.ID), viewer, nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/activate", next.ID), token, nil), http.StatusOK, nil)
+
+	var current models.Event
+	s.expect(s.do(http.MethodGet, "/api/event", "", nil), http.StatusOK, &current)
+	if current.ID != next.ID || current.PrimaryColor != "#0057b8" {
+		t.Fatalf("expected the new current event, got %+v", current)
+	}
+
+	// The teams of the previous edition are archived
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": alpha.Email, "password": "pass"}), http.StatusUnauthorized, nil)
+
+	// The emails are signed with the event of the team
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(created.Team.ID, "team"), item(cloud.ID, 1)), http.StatusCreated, nil)
+	email := s.mailer.waitForEmail(t, "alpha@example.com")
+	if email.Subject != "Demande d'achat reçue - Hackathon 2026" {
+		t.Errorf("unexpected subject %q", email.Subject)
+	}
+}
+
+func TestAdminsOnlyChangeTheirEvent(t *testing.T) {
+	s := newTestServer(t)
+	token := s.token(s.createAdmin("root", "pass").ID, "admin")
+	past := s.createEvent("Hackathon 2024")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, true)
+	purchase := s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 1))[0]
+	poll := s.createPoll("Meilleur projet ?", "A", "B")
+	rule := models.QuotaRule{EventID: s.event.ID, Name: "Cloud", Scope: models.QuotaScopeResource, ResourceID: &cloud.ID, MaxQuantity: 2}
+	if err := s.db.Create(&rule).Error; err != nil {
+		t.Fatalf("create quota rule: %v", err)
+	}
+	channel := s.createChannel(token, gin.H{
+		"name": "Discord", "type": "webhook", "target": "https://example.com/hook", "events": []string{notify.EventPurchasePending},
+	})
+
+	// Once switched to another event, the entities of the current event are not found by ID
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/switch", past.ID), token, nil), http.StatusOK, nil)
+	requests := []struct {
+		method, path string
+		body         interface{}
+	}{
+		{http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", cloud.ID), gin.H{"cost": 1}},
+		{http.MethodPost, fmt.Sprintf("/api/admin/resources/%d/deactivate", cloud.ID), nil},
+		{http.MethodDelete, fmt.Sprintf("/api/admin/resources/%d", cloud.ID), nil},
+		{http.MethodPut, fmt.Sprintf("/api/admin/teams/%d", team.ID), gin.H{"name": "beta"}},
+		{http.MethodPost, fmt.Sprintf("/api/admin/teams/%d/reset-password", team.ID), nil},
+		{http.MethodPost, fmt.Sprintf("/api/admin/teams/%d/credit", team.ID), gin.H{"mode": "set", "amount": 0, "justification": "Remise à zéro"}},
+		{http.MethodPost, fmt.Sprintf("/api/admin/teams/%d/suspend", team.ID), nil},
+		{http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", purchase.ID), gin.H{"action": "confirm"}},
+		{http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/mark-returned", purchase.ID), nil},
+		{http.MethodPut, fmt.Sprintf("/api/admin/quotas/%d", rule.ID), gin.H{"max_quantity": 0}},
+		{http.MethodDelete, fmt.Sprintf("/api/admin/quotas/%d", rule.ID), nil},
+		{http.MethodPut, fmt.Sprintf("/api/admin/polls/%d", poll.ID), gin.H{"question": "Pire projet ?"}},
+		{http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/close", poll.ID), nil},
+		{http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/cancel", poll.ID), nil},
+		{http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/resolve", poll.ID), gin.H{"winning_option": "A"}},
+		{http.MethodPut, fmt.Sprintf("/api/admin/notifications/channels/%d", channel.Channel.ID), gin.H{"is_active": false}},
+		{http.MethodDelete, fmt.Sprintf("/api/admin/notifications/channels/%d", channel.Channel.ID), nil},
+	}
+	for _, r := range requests {
+		if w := s.do(r.method, r.path, token, r.body); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d: %s", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+
+	var channels []models.NotificationChannel
+	s.expect(s.do(http.MethodGet, "/api/admin/notifications/channels", token, nil), http.StatusOK, &channels)
+	if len(channels) != 0 {
+		t.Errorf("expected no channel in the selected event, got %+v", channels)
+	}
+
+	// Nothing changed, and the staff of the other event is not notified of the purchases
+	s.assertCredit(team.ID, 900)
+	if got := s.purchase(purchase.ID); got.Status != models.StatusPending {
+		t.Errorf("expected the purchase to stay pending, got %+v", got)
+	}
+	if got := s.team(team.ID); got.Name != "alpha" || got.IsSuspended {
+		t.Errorf("expected the team to be unchanged, got %+v", got)
+	}
+	oldChannel := s.createChannel(token, gin.H{
+		"name": "Old Discord", "type": "webhook", "target": "https://example.com/old", "events": []string{notify.EventPurchasePending},
+	})
+	s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 1))
+	if deliveries := s.deliveries(); len(deliveries) != 1 || deliveries[0].ChannelID != channel.Channel.ID {
+		t.Errorf("expected the purchase notified to the channel of its event only, got %+v", deliveries)
+	}
+	if oldChannel.Channel.EventID != past.ID {
+		t.Errorf("expected the channel in the selected event, got %+v", oldChannel.Channel)
+	}
+}
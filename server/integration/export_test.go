@@ -0,0 +1,68 @@
+package integration
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportRows downloads a CSV export and returns its rows, header included
+func (s *testServer) exportRows(token, path string) [][]string {
+	s.t.Helper()
+
+	w := s.do(http.MethodGet, path, token, nil)
+	s.expect(w, http.StatusOK, nil)
+	rows, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\uFEFF"))).ReadAll()
+	if err != nil {
+		s.t.Fatalf("read export: %v", err)
+	}
+	return rows
+}
+
+func TestExportDoesNotRunFormulas(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	team := s.createTeam("-2+3", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	comment := `=HYPERLINK("http://evil.example","Details")`
+	s.expect(s.do(http.MethodPost, "/api/team/purchases/batch", s.token(team.ID, "team"), gin.H{"items": []gin.H{item(cloud.ID, 1)}, "comment": comment}), http.StatusCreated, nil)
+
+	rows := s.exportRows(adminToken, "/api/admin/export/purchases")
+	if len(rows) != 2 {
+		t.Fatalf("expected one purchase, got %q", rows)
+	}
+
+	// Text typed by the teams is escaped, numbers are kept
+	row := rows[1]
+	if row[2] != "'-2+3" || row[14] != "'"+comment {
+		t.Errorf("expected the team name and comment to be escaped, got %q", row)
+	}
+	if row[7] != "100" {
+		t.Errorf("expected the cost as a number, got %q", row[7])
+	}
+}
+
+func TestExportsShowThePricePaid(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+	s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 2))
+
+	// The price changes after the purchase
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", cloud.ID), adminToken, gin.H{"cost": 400}), http.StatusOK, nil)
+
+	rows := s.exportRows(adminToken, "/api/admin/export/purchases")
+	if len(rows) != 2 || rows[1][7] != "100" || rows[1][8] != "200" {
+		t.Errorf("expected the costs paid in the purchase export, got %q", rows)
+	}
+	rows = s.exportRows(adminToken, "/api/admin/export/teams")
+	if len(rows) != 2 || rows[1][3] != "800" || rows[1][4] != "200" {
+		t.Errorf("expected the credit spent in the team export, got %q", rows)
+	}
+}
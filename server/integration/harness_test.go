@@ -0,0 +1,528 @@
+// Package integration tests the API over HTTP: the router of routes.SetupRoutes is
+// served against a disposable database created for each test, in SQLite or, with
+// TEST_POSTGRES_DSN, in Postgres.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/config"
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/migrations"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/routes"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/ericp/ylab-hackathon/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testJWTSecret = "integration-test-secret"
+
+const testEventName = "Integration Hackathon"
+
+const testPublicURL = "https://hackathon.test"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testServer is the API served against its own database
+type testServer struct {
+	t         *testing.T
+	db        *gorm.DB
+	event     models.Event // Current event, that the created teams, resources and polls belong to
+	router    *gin.Engine
+	mailer    *fakeMailer // Only set by newTestServer
+	outbox    *services.OutboxWorker
+	reminders *services.ReturnReminderWorker // Not started, run with ProcessDue
+	sessions  services.SessionService
+	broker    *events.Broker // Live updates of the API, subscribed to directly
+}
+
+// testOutboxOptions make the background worker send the emails right away
+var testOutboxOptions = services.OutboxOptions{
+	Interval:    10 * time.Millisecond,
+	BatchSize:   20,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    100 * time.Millisecond,
+	MaxAttempts: 3,
+	Lease:       time.Second,
+}
+
+// newTestServer builds a test server whose emails are recorded by a fake mailer,
+// sent from the outbox in the background
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	mailer := &fakeMailer{}
+	s := newServer(t, mailer, testOutboxOptions)
+	s.mailer = mailer
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.outbox.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return s
+}
+
+// openDatabase creates the database of a test. With TEST_POSTGRES_DSN, it is an empty
+// Postgres schema built by the SQL migrations, as in production. Otherwise it is a
+// SQLite file built by AutoMigrate, since the migrations are written for Postgres.
+// SQLite has no row locks: transactions take the write lock when they begin
+// (_txlock=immediate), so concurrent requests run one after the other and the FOR
+// UPDATE locks of the services are only exercised on Postgres.
+func openDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	if os.Getenv("TEST_POSTGRES_DSN") != "" {
+		db := newPostgresSchema(t)
+		applyMigrations(t, db)
+		// The migrations create a first event, replaced by the one of the test
+		if err := db.Exec("DELETE FROM events").Error; err != nil {
+			t.Fatalf("delete events: %v", err)
+		}
+		return db
+	}
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") +
+		"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models.All()...); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// newPostgresSchema connects to the Postgres database of TEST_POSTGRES_DSN, in an
+// empty schema dropped at the end of the test. The test is skipped without it.
+func newPostgresSchema(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	gormConfig := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), gormConfig)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	schema := fmt.Sprintf("integration_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	// Every connection of the pool works in the schema
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), gormConfig)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// withSearchPath sets the search path of a Postgres DSN, given as a URL or as
+// key=value pairs
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+// applyMigrations runs the embedded SQL migrations and checks that none is missing
+func applyMigrations(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Fatalf("check migrations: %v", err)
+	}
+}
+
+// newServer builds the router on a new database (see openDatabase). The outbox and
+// reminder workers are not started.
+func newServer(t *testing.T, mailer services.Mailer, outboxOptions services.OutboxOptions) *testServer {
+	t.Helper()
+
+	db := openDatabase(t)
+
+	event := models.Event{
+		Name:      testEventName,
+		StartsAt:  time.Now().Add(-time.Hour),
+		EndsAt:    time.Now().Add(24 * time.Hour),
+		IsCurrent: true,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		t.Fatalf("create event: %v", err)
+	}
+
+	// The tokens are signed with the secret of the global configuration
+	config.AppConfig = &config.Config{JWTSecret: testJWTSecret}
+
+	templates, err := emails.NewRegistry(testEventName)
+	if err != nil {
+		t.Fatalf("load email templates: %v", err)
+	}
+
+	broker := events.NewBroker()
+	deps := services.Deps{DB: db, Mailer: mailer, Templates: templates, Events: broker, PublicURL: testPublicURL}
+	router := gin.New()
+	routes.SetupRoutes(router, deps)
+
+	return &testServer{
+		t:         t,
+		db:        db,
+		event:     event,
+		router:    router,
+		outbox:    services.NewOutboxWorker(deps, outboxOptions),
+		reminders: services.NewReturnReminderWorker(deps, services.DefaultReturnReminderOptions),
+		sessions:  services.NewSessionService(deps),
+		broker:    broker,
+	}
+}
+
+// request builds a JSON request, authenticated when token is not empty
+func (s *testServer) request(method, path, token string, body interface{}) *http.Request {
+	s.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatalf("encode request: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+// serve runs a request through the router. Unlike the other helpers it can be
+// called from several goroutines.
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// do sends a JSON request, authenticated when token is not empty
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	return s.serve(s.request(method, path, token, body))
+}
+
+// expect checks the status of a response and decodes its body into out, if given
+func (s *testServer) expect(w *httptest.ResponseRecorder, status int, out interface{}) {
+	s.t.Helper()
+
+	if w.Code != status {
+		s.t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("decode response: %v: %s", err, w.Body.String())
+		}
+	}
+}
+
+// token opens a session and mints its JWT the way the login handlers do, with the
+// role of the admin
+func (s *testServer) token(userID uint, userType string) string {
+	s.t.Helper()
+
+	var admin models.Admin
+	if userType == "admin" {
+		if err := s.db.First(&admin, userID).Error; err != nil {
+			s.t.Fatalf("load admin: %v", err)
+		}
+	}
+	session, _, err := s.sessions.Start(userType, userID, services.Client{})
+	if err != nil {
+		s.t.Fatalf("open session: %v", err)
+	}
+	token, err := utils.GenerateToken(userID, userType, string(admin.Role), session.ID, testJWTSecret)
+	if err != nil {
+		s.t.Fatalf("generate token: %v", err)
+	}
+	return token
+}
+
+// hashPassword uses the lowest bcrypt cost to keep the tests fast
+func (s *testServer) hashPassword(password string) string {
+	s.t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		s.t.Fatalf("hash password: %v", err)
+	}
+	return string(hash)
+}
+
+// createTeam creates a team of the current event with its opening ledger entry
+func (s *testServer) createTeam(name, password string, credit int) models.Team {
+	s.t.Helper()
+	return s.createEventTeam(s.event.ID, name, password, credit)
+}
+
+// createEventTeam creates a team of the given event with its opening ledger entry
+func (s *testServer) createEventTeam(eventID uint, name, password string, credit int) models.Team {
+	s.t.Helper()
+
+	team := models.Team{
+		EventID:      eventID,
+		Name:         name,
+		Email:        name + "@example.com",
+		PasswordHash: s.hashPassword(password),
+		Credit:       credit,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&team).Update("credit", credit).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.CreditTransaction{
+			TeamID:       team.ID,
+			Amount:       credit,
+			BalanceAfter: credit,
+			Reason:       models.CreditReasonOpening,
+		}).Error
+	})
+	if err != nil {
+		s.t.Fatalf("create team: %v", err)
+	}
+	return team
+}
+
+func (s *testServer) createAdmin(username, password string) models.Admin {
+	s.t.Helper()
+
+	admin := models.Admin{
+		Username:     username,
+		Email:        username + "@example.com",
+		PasswordHash: s.hashPassword(password),
+	}
+	if err := s.db.Create(&admin).Error; err != nil {
+		s.t.Fatalf("create admin: %v", err)
+	}
+	return admin
+}
+
+// createEvent creates an event that is not the current one
+func (s *testServer) createEvent(name string) models.Event {
+	s.t.Helper()
+
+	event := models.Event{
+		Name:     name,
+		StartsAt: time.Now().Add(-365 * 24 * time.Hour),
+		EndsAt:   time.Now().Add(-364 * 24 * time.Hour),
+	}
+	if err := s.db.Create(&event).Error; err != nil {
+		s.t.Fatalf("create event: %v", err)
+	}
+	return event
+}
+
+// createResource creates an active resource of the current event. Returnable resources are of the
+// "matériel" type.
+func (s *testServer) createResource(name string, cost, quantity, maxPerTeam int, returnable bool) models.Resource {
+	s.t.Helper()
+
+	resource := models.Resource{
+		EventID:         s.event.ID,
+		Name:            name,
+		Cost:            cost,
+		Quantity:        quantity,
+		MaxPerTeam:      maxPerTeam,
+		Type:            "service",
+		IsActive:        true,
+		IsNonReturnable: !returnable,
+	}
+	if returnable {
+		resource.Type = "matériel"
+	}
+	if err := s.db.Create(&resource).Error; err != nil {
+		s.t.Fatalf("create resource: %v", err)
+	}
+	return resource
+}
+
+// createPoll creates a poll of the current event, open since an hour and for
+// another hour
+func (s *testServer) createPoll(question string, options ...string) models.Poll {
+	s.t.Helper()
+
+	poll := models.Poll{
+		EventID:   s.event.ID,
+		Question:  question,
+		Options:   options,
+		StartDate: time.Now().Add(-time.Hour),
+		EndDate:   time.Now().Add(time.Hour),
+		Status:    models.PollStatusOpen,
+	}
+	if err := s.db.Create(&poll).Error; err != nil {
+		s.t.Fatalf("create poll: %v", err)
+	}
+	return poll
+}
+
+func (s *testServer) team(id uint) models.Team {
+	s.t.Helper()
+
+	var team models.Team
+	if err := s.db.First(&team, id).Error; err != nil {
+		s.t.Fatalf("load team %d: %v", id, err)
+	}
+	return team
+}
+
+func (s *testServer) resource(id uint) models.Resource {
+	s.t.Helper()
+
+	var resource models.Resource
+	if err := s.db.First(&resource, id).Error; err != nil {
+		s.t.Fatalf("load resource %d: %v", id, err)
+	}
+	return resource
+}
+
+func (s *testServer) purchase(id uint) models.Purchase {
+	s.t.Helper()
+
+	var purchase models.Purchase
+	if err := s.db.First(&purchase, id).Error; err != nil {
+		s.t.Fatalf("load purchase %d: %v", id, err)
+	}
+	return purchase
+}
+
+// assertCredit checks the balance of a team and that it matches its ledger
+func (s *testServer) assertCredit(teamID uint, want int) {
+	s.t.Helper()
+
+	team := s.team(teamID)
+	if team.Credit != want {
+		s.t.Errorf("team %d: expected credit %d, got %d", teamID, want, team.Credit)
+	}
+
+	var ledger int
+	if err := s.db.Model(&models.CreditTransaction{}).
+		Where("team_id = ?", teamID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&ledger).Error; err != nil {
+		s.t.Fatalf("sum ledger: %v", err)
+	}
+	if ledger != team.Credit {
+		s.t.Errorf("team %d: ledger sums to %d but credit is %d", teamID, ledger, team.Credit)
+	}
+}
+
+// assertStock checks the units in stock and reserved of a resource
+func (s *testServer) assertStock(resourceID uint, quantity, reserved int) {
+	s.t.Helper()
+
+	resource := s.resource(resourceID)
+	if resource.Quantity != quantity || resource.Reserved != reserved {
+		s.t.Errorf("resource %d: expected %d in stock and %d reserved, got %d and %d",
+			resourceID, quantity, reserved, resource.Quantity, resource.Reserved)
+	}
+}
+
+// sentEmail is an email recorded by fakeMailer
+type sentEmail struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// fakeMailer records the emails instead of sending them. The services send emails
+// in the background, hence the lock.
+type fakeMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+func (m *fakeMailer) Send(to string, msg emails.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, sentEmail{To: to, Subject: msg.Subject, HTML: msg.HTML, Text: msg.Text})
+	return nil
+}
+
+// waitForEmail waits for an email to be sent to the given address
+func (m *fakeMailer) waitForEmail(t *testing.T, to string) sentEmail {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		m.mu.Lock()
+		for _, email := range m.sent {
+			if email.To == to {
+				m.mu.Unlock()
+				return email
+			}
+		}
+		m.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no email sent to %s", to)
+	return sentEmail{}
+}
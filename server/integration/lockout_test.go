@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// loginFrom sends a team login from a client address
+func (s *testServer) loginFrom(ip, name, password string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	req := s.request(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": name, "password": password})
+	req.RemoteAddr = ip + ":40000"
+	return s.serve(req)
+}
+
+// skipLoginDelay moves the last attempts back so that the next one is not delayed
+func (s *testServer) skipLoginDelay() {
+	s.t.Helper()
+
+	if err := s.db.Model(&models.LoginThrottle{}).Where("1 = 1").
+		Update("last_attempt_at", time.Now().Add(-2*time.Minute)).Error; err != nil {
+		s.t.Fatalf("update login throttles: %v", err)
+	}
+}
+
+func TestRepeatedFailedLoginsLockTheAccount(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "admin-pass")
+	adminToken := s.token(admin.ID, "admin")
+	s.createTeam("alpha", "s3cret-pass", 1000)
+	s.createTeam("beta", "s3cret-pass", 1000)
+
+	// The first failures are answered right away, the next ones must wait
+	for i := 0; i < 3; i++ {
+		s.expect(s.loginFrom("203.0.113.1", "alpha", "guess"), http.StatusUnauthorized, nil)
+	}
+	w := s.loginFrom("203.0.113.2", "alpha", "s3cret-pass")
+	s.expect(w, http.StatusTooManyRequests, nil)
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected to retry after a second, got %q", w.Header().Get("Retry-After"))
+	}
+	s.expect(s.loginFrom("203.0.113.1", "beta", "s3cret-pass"), http.StatusOK, nil)
+
+	// The tenth failure locks the account, even for the right password
+	for i := 3; i < 10; i++ {
+		s.skipLoginDelay()
+		s.expect(s.loginFrom("203.0.113.1", "alpha", "guess"), http.StatusUnauthorized, nil)
+	}
+	s.skipLoginDelay()
+	var refused struct {
+		RetryAfter int `json:"retry_after"`
+	}
+	s.expect(s.loginFrom("203.0.113.3", "alpha", "s3cret-pass"), http.StatusTooManyRequests, &refused)
+	if refused.RetryAfter < 14*60 || refused.RetryAfter > 15*60 {
+		t.Errorf("expected the account to be locked for 15 minutes, got %d seconds", refused.RetryAfter)
+	}
+
+	// Admins see the lockout and lift it
+	var lockouts []models.LoginLockout
+	s.expect(s.do(http.MethodGet, "/api/admin/login-lockouts?active=true", adminToken, nil), http.StatusOK, &lockouts)
+	if len(lockouts) != 1 || lockouts[0].Scope != models.LoginScopeAccount || lockouts[0].UserType != "team" ||
+		lockouts[0].Identifier != "alpha" || lockouts[0].IPAddress != "203.0.113.1" || lockouts[0].Failures != 10 {
+		t.Fatalf("expected the lockout of the team, got %+v", lockouts)
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/login-lockouts?scope=everything", adminToken, nil), http.StatusBadRequest, nil)
+
+	unlock := fmt.Sprintf("/api/admin/login-lockouts/%d/unlock", lockouts[0].ID)
+	s.expect(s.do(http.MethodPost, unlock, adminToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, unlock, adminToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.loginFrom("203.0.113.1", "alpha", "s3cret-pass"), http.StatusOK, nil)
+
+	var entries []models.AuditEntry
+	s.db.Where("action = ?", models.AuditLockoutUnlock).Find(&entries)
+	if len(entries) != 1 || entries[0].EntityID != lockouts[0].ID {
+		t.Errorf("expected the unlock to be audited, got %+v", entries)
+	}
+
+	// A successful login forgets the failures
+	var throttles int64
+	s.db.Model(&models.LoginThrottle{}).Where("scope = ?", models.LoginScopeAccount).Count(&throttles)
+	if throttles != 0 {
+		t.Errorf("expected the failures of the accounts to be forgotten, got %d", throttles)
+	}
+}
+
+func TestFailedLoginsFromOneAddressLockIt(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "admin-pass")
+	s.createTeam("alpha", "s3cret-pass", 1000)
+
+	// Guessing across names, known or not, is counted per address
+	for i := 0; i < 50; i++ {
+		s.expect(s.loginFrom("198.51.100.9", fmt.Sprintf("team-%d", i), "guess"), http.StatusUnauthorized, nil)
+	}
+	s.expect(s.loginFrom("198.51.100.9", "alpha", "s3cret-pass"), http.StatusTooManyRequests, nil)
+	s.expect(s.loginFrom("198.51.100.10", "alpha", "s3cret-pass"), http.StatusOK, nil)
+
+	var lockouts []models.LoginLockout
+	s.expect(s.do(http.MethodGet, "/api/admin/login-lockouts?scope=ip", s.token(admin.ID, "admin"), nil), http.StatusOK, &lockouts)
+	if len(lockouts) != 1 || lockouts[0].Key != "198.51.100.9" || lockouts[0].UserID != nil || lockouts[0].Identifier != "team-49" {
+		t.Fatalf("expected the lockout of the address, got %+v", lockouts)
+	}
+}
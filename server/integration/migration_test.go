@@ -0,0 +1,106 @@
+package integration
+
+import (
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+// firstReleaseSchema is the part of the schema created by AutoMigrate in the first
+// release that differs from the current models
+const firstReleaseSchema = `
+CREATE TABLE teams (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    email text NOT NULL,
+    password_hash text NOT NULL,
+    credit bigint NOT NULL DEFAULT 1000,
+    last_activity timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT uni_teams_name UNIQUE (name),
+    CONSTRAINT uni_teams_email UNIQUE (email)
+);
+CREATE TABLE resources (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text,
+    cost bigint NOT NULL,
+    quantity bigint NOT NULL,
+    max_per_team bigint NOT NULL,
+    type text NOT NULL,
+    image_url text,
+    is_active boolean DEFAULT true,
+    is_non_returnable boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE TABLE polls (
+    id bigserial PRIMARY KEY,
+    question text NOT NULL,
+    options jsonb,
+    start_date timestamptz,
+    end_date timestamptz,
+    status text DEFAULT 'ouvert',
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE TABLE votes (
+    id bigserial PRIMARY KEY,
+    team_id bigint NOT NULL REFERENCES teams (id),
+    poll_id bigint NOT NULL REFERENCES polls (id),
+    chosen_option text NOT NULL,
+    credit_staked bigint NOT NULL,
+    vote_date timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+INSERT INTO teams (name, email, password_hash) VALUES ('alpha', 'alpha@example.com', 'hash');
+`
+
+// migrate applies every migration and checks that the schema has the tables and
+// columns of the models
+func migrate(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	applyMigrations(t, db)
+
+	for _, model := range models.All() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse model: %v", err)
+		}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			t.Errorf("missing table %s", stmt.Schema.Table)
+			continue
+		}
+		for _, column := range stmt.Schema.DBNames {
+			if !db.Migrator().HasColumn(model, column) {
+				t.Errorf("missing column %s.%s", stmt.Schema.Table, column)
+			}
+		}
+	}
+}
+
+func TestMigrationsCreateTheSchema(t *testing.T) {
+	migrate(t, newPostgresSchema(t))
+}
+
+func TestMigrationsAdoptFirstReleaseDatabase(t *testing.T) {
+	db := newPostgresSchema(t)
+	if err := db.Exec(firstReleaseSchema).Error; err != nil {
+		t.Fatalf("create first release schema: %v", err)
+	}
+
+	migrate(t, db)
+
+	var team models.Team
+	if err := db.Where("name = ?", "alpha").First(&team).Error; err != nil || team.IsSuspended || team.EventID == 0 {
+		t.Errorf("expected the team to be kept, got %+v, %v", team, err)
+	}
+}
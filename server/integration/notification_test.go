@@ -0,0 +1,259 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// webhookRequest is a request received by fakeWebhook
+type webhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+// fakeWebhook is an HTTP endpoint recording the notifications it receives
+type fakeWebhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []webhookRequest
+	failures int // Next requests answered with a 500
+}
+
+func newFakeWebhook(t *testing.T) *fakeWebhook {
+	t.Helper()
+
+	w := &fakeWebhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.failures > 0 {
+			w.failures--
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.requests = append(w.requests, webhookRequest{Header: r.Header.Clone(), Body: body})
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *fakeWebhook) failNext(n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.failures = n
+}
+
+func (w *fakeWebhook) received() []webhookRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]webhookRequest(nil), w.requests...)
+}
+
+// deliveries returns the notification deliveries, oldest first
+func (s *testServer) deliveries() []models.NotificationDelivery {
+	s.t.Helper()
+
+	var deliveries []models.NotificationDelivery
+	if err := s.db.Order("id").Find(&deliveries).Error; err != nil {
+		s.t.Fatalf("load deliveries: %v", err)
+	}
+	return deliveries
+}
+
+// makeDeliveryDue moves a delivery forward in time so that its next attempt is due
+func (s *testServer) makeDeliveryDue(id uint) {
+	s.t.Helper()
+
+	if err := s.db.Model(&models.NotificationDelivery{}).Where("id = ?", id).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		s.t.Fatalf("update delivery: %v", err)
+	}
+}
+
+// createChannel adds a notification channel through the API and returns it with its secret
+func (s *testServer) createChannel(token string, req gin.H) models.CreateNotificationChannelResponse {
+	s.t.Helper()
+
+	var created models.CreateNotificationChannelResponse
+	s.expect(s.do(http.MethodPost, "/api/admin/notifications/channels", token, req), http.StatusCreated, &created)
+	return created
+}
+
+func TestNotificationsAreRoutedToChannels(t *testing.T) {
+	mailer := &fakeMailer{}
+	s := newServer(t, mailer, smtpOutboxOptions)
+	webhook := newFakeWebhook(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	teamToken := s.token(team.ID, "team")
+
+	// Channels are checked
+	s.expect(s.do(http.MethodPost, "/api/admin/notifications/channels", adminToken, gin.H{
+		"name": "Discord", "type": "webhook", "target": "ftp://example.com", "events": []string{notify.EventLowStock},
+	}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/notifications/channels", adminToken, gin.H{
+		"name": "Discord", "type": "webhook", "target": webhook.URL, "events": []string{"purchase.everything"},
+	}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/notifications/channels", adminToken, gin.H{
+		"name": "Staff", "type": "email", "target": "not an address", "events": []string{notify.EventPollClosed},
+	}), http.StatusBadRequest, nil)
+
+	hook := s.createChannel(adminToken, gin.H{
+		"name": "Discord", "type": "webhook", "target": webhook.URL,
+		"events": []string{notify.EventPurchasePending, notify.EventLowStock},
+	})
+	if len(hook.Secret) != 64 {
+		t.Fatalf("expected a generated secret, got %q", hook.Secret)
+	}
+	staff := s.createChannel(adminToken, gin.H{
+		"name": "Staff", "type": "email", "target": "staff@example.com", "events": []string{notify.EventPollClosed},
+	})
+	disabled := s.createChannel(adminToken, gin.H{
+		"name": "Slack", "type": "webhook", "target": webhook.URL, "secret": "shared",
+		"events": []string{notify.EventPurchasePending}, "is_active": false,
+	})
+	if disabled.Secret != "shared" || disabled.Channel.IsActive {
+		t.Fatalf("expected an inactive channel with the given secret, got %+v", disabled)
+	}
+
+	// The secret is never shown again, nor recorded in the audit log
+	w := s.do(http.MethodGet, "/api/admin/notifications/channels", adminToken, nil)
+	var channels []models.NotificationChannel
+	s.expect(w, http.StatusOK, &channels)
+	if len(channels) != 3 || strings.Contains(w.Body.String(), hook.Secret) {
+		t.Fatalf("unexpected channel list: %s", w.Body.String())
+	}
+	var entries []models.AuditEntry
+	s.db.Where("action = ?", models.AuditNotificationCreate).Find(&entries)
+	if len(entries) != 3 || strings.Contains(string(entries[0].After), hook.Secret) {
+		t.Fatalf("expected the channels to be audited without their secret, got %d entries", len(entries))
+	}
+
+	// Taking the available units down to the threshold notifies the staff, once
+	board := s.createResource("Raspberry Pi", 100, 3, 3, true)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", board.ID), adminToken, gin.H{"low_stock_threshold": 1}), http.StatusOK, nil)
+	s.buyBatch(teamToken, item(board.ID, 2))
+
+	deliveries := s.deliveries()
+	if len(deliveries) != 2 || deliveries[0].Event != notify.EventLowStock || deliveries[1].Event != notify.EventPurchasePending {
+		t.Fatalf("expected a low stock and a pending purchase notification, got %+v", deliveries)
+	}
+	for _, d := range deliveries {
+		if d.ChannelID != hook.Channel.ID || d.Status != models.DeliveryStatusPending {
+			t.Errorf("expected a pending delivery to the webhook, got %+v", d)
+		}
+	}
+
+	s.processOutbox(3) // The email to the team and the two notifications
+	received := webhook.received()
+	if len(received) != 2 {
+		t.Fatalf("expected 2 webhook requests, got %d", len(received))
+	}
+	for _, r := range received {
+		timestamp := r.Header.Get(notify.HeaderTimestamp)
+		if r.Header.Get(notify.HeaderSignature) != notify.Sign(hook.Secret, timestamp, r.Body) {
+			t.Errorf("invalid signature for %s", r.Body)
+		}
+	}
+	var payload struct {
+		Event   string                 `json:"event"`
+		Title   string                 `json:"title"`
+		Content string                 `json:"content"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(received[0].Body, &payload); err != nil {
+		t.Fatalf("decode webhook body: %v", err)
+	}
+	if payload.Event != notify.EventLowStock || received[0].Header.Get(notify.HeaderEvent) != notify.EventLowStock ||
+		payload.Data["available_quantity"] != float64(1) || !strings.Contains(payload.Content, "Raspberry Pi") {
+		t.Errorf("unexpected low stock notification: %s", received[0].Body)
+	}
+	if delivered := s.deliveries()[0]; delivered.Status != models.DeliveryStatusDelivered || delivered.DeliveredAt == nil {
+		t.Errorf("expected the delivery to be recorded, got %+v", delivered)
+	}
+
+	// Below the threshold already: only the purchase is notified
+	s.buyBatch(teamToken, item(board.ID, 1))
+	if deliveries := s.deliveries(); len(deliveries) != 3 || deliveries[2].Event != notify.EventPurchasePending {
+		t.Fatalf("expected a single new notification, got %+v", deliveries[2:])
+	}
+	s.processOutbox(2)
+
+	// Closing a poll is sent to the email channel
+	poll := s.createPoll("Meilleur projet ?", "A", "B")
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/close", poll.ID), adminToken, nil), http.StatusOK, nil)
+	s.processOutbox(1)
+	email := mailer.waitForEmail(t, staff.Channel.Target)
+	if email.Subject != "["+testEventName+"] Sondage fermé" || !strings.Contains(email.Text, "Meilleur projet ?") {
+		t.Errorf("unexpected staff email: %+v", email)
+	}
+}
+
+func TestFailingWebhookIsRetriedThenResent(t *testing.T) {
+	s := newServer(t, nil, smtpOutboxOptions) // Without a mailer, only notifications are sent
+	webhook := newFakeWebhook(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	s.createChannel(adminToken, gin.H{
+		"name": "Discord", "type": "webhook", "target": webhook.URL, "events": []string{notify.EventPurchasePending},
+	})
+	webhook.failNext(smtpOutboxOptions.MaxAttempts)
+	s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 1))
+	id := s.deliveries()[0].ID
+
+	for attempt := 1; attempt < smtpOutboxOptions.MaxAttempts; attempt++ {
+		s.processOutbox(1)
+
+		delivery := s.deliveries()[0]
+		if delivery.Status != models.DeliveryStatusPending || delivery.Attempts != attempt || !strings.Contains(delivery.LastError, "500") {
+			t.Fatalf("attempt %d: expected a pending delivery with its error, got %+v", attempt, delivery)
+		}
+		s.processOutbox(0)
+		s.makeDeliveryDue(id)
+	}
+
+	// The last attempt puts the delivery aside for the admins
+	s.processOutbox(1)
+	var failed []models.NotificationDelivery
+	w := s.do(http.MethodGet, "/api/admin/notifications/deliveries?status="+url.QueryEscape(string(models.DeliveryStatusFailed)), adminToken, nil)
+	s.expect(w, http.StatusOK, &failed)
+	if len(failed) != 1 || failed[0].ID != id || failed[0].Channel == nil || w.Header().Get("X-Total-Count") != "1" {
+		t.Fatalf("expected the delivery to be listed as failed, got %+v", failed)
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/notifications/deliveries?event=unknown", adminToken, nil), http.StatusBadRequest, nil)
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/notifications/deliveries/%d/resend", id), adminToken, nil), http.StatusOK, nil)
+	s.processOutbox(1)
+	if len(webhook.received()) != 1 || s.deliveries()[0].Status != models.DeliveryStatusDelivered {
+		t.Fatalf("expected the notification to be delivered after the resend")
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/notifications/deliveries/%d/resend", id), adminToken, nil), http.StatusBadRequest, nil)
+
+	// Notifications for a deleted channel are given up
+	s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 1))
+	channelID := s.deliveries()[1].ChannelID
+	s.expect(s.do(http.MethodDelete, fmt.Sprintf("/api/admin/notifications/channels/%d", channelID), adminToken, nil), http.StatusOK, nil)
+	s.processOutbox(1)
+	if delivery := s.deliveries()[1]; delivery.Status != models.DeliveryStatusFailed || len(webhook.received()) != 1 {
+		t.Errorf("expected the delivery to fail without being sent, got %+v", delivery)
+	}
+}
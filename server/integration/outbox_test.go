@@ -0,0 +1,195 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// smtpOutboxOptions have delays long enough to be measured: the tests move the
+// emails forward in time instead of waiting
+var smtpOutboxOptions = testOutboxOptions
+
+func init() {
+	smtpOutboxOptions.BaseDelay = time.Minute
+	smtpOutboxOptions.MaxDelay = 3 * time.Minute
+	smtpOutboxOptions.MaxAttempts = 4
+}
+
+// newSMTPTestServer builds a test server sending its emails to a fake SMTP server.
+// The outbox worker does not run: the tests call ProcessDue themselves.
+func newSMTPTestServer(t *testing.T) (*testServer, *fakeSMTP) {
+	t.Helper()
+
+	smtpServer := newFakeSMTP(t)
+	host, port := smtpServer.hostPort()
+	mailer := utils.NewEmailService(host, port, "shop", "secret", "shop@example.com")
+	return newServer(t, mailer, smtpOutboxOptions), smtpServer
+}
+
+// outboxEmails returns the emails of the outbox, oldest first
+func (s *testServer) outboxEmails() []models.EmailOutbox {
+	s.t.Helper()
+
+	var outbox []models.EmailOutbox
+	if err := s.db.Order("id").Find(&outbox).Error; err != nil {
+		s.t.Fatalf("load outbox: %v", err)
+	}
+	return outbox
+}
+
+// processOutbox runs the outbox worker once and checks how many emails and
+// notifications it attempted
+func (s *testServer) processOutbox(want int) {
+	s.t.Helper()
+
+	attempted, err := s.outbox.ProcessDue()
+	if err != nil {
+		s.t.Fatalf("process outbox: %v", err)
+	}
+	if attempted != want {
+		s.t.Fatalf("expected %d attempts, got %d", want, attempted)
+	}
+}
+
+// makeDue moves an email forward in time so that its next attempt is due
+func (s *testServer) makeDue(id uint) {
+	s.t.Helper()
+
+	if err := s.db.Model(&models.EmailOutbox{}).Where("id = ?", id).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error; err != nil {
+		s.t.Fatalf("update outbox: %v", err)
+	}
+}
+
+func TestEmailIsSentThroughTheOutbox(t *testing.T) {
+	s, smtpServer := newSMTPTestServer(t)
+	team := s.createTeam("alpha", "pass", 150)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	// A rejected purchase is rolled back with its email
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", token, item(cloud.ID, 2)), http.StatusBadRequest, nil)
+	if outbox := s.outboxEmails(); len(outbox) != 0 {
+		t.Fatalf("expected an empty outbox, got %+v", outbox)
+	}
+
+	// The email is written with the purchase, and only sent by the worker
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", token, item(cloud.ID, 1)), http.StatusCreated, nil)
+	outbox := s.outboxEmails()
+	if len(outbox) != 1 || outbox[0].Status != models.EmailStatusPending || *outbox[0].TeamID != team.ID {
+		t.Fatalf("expected a pending email for the team, got %+v", outbox)
+	}
+	if len(smtpServer.received()) != 0 {
+		t.Fatalf("no email should be sent before the worker runs")
+	}
+
+	s.processOutbox(1)
+
+	received := smtpServer.received()
+	if len(received) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(received))
+	}
+	msg := received[0]
+	if msg.From != "shop@example.com" || len(msg.To) != 1 || msg.To[0] != team.Email {
+		t.Errorf("unexpected envelope: from %q to %v", msg.From, msg.To)
+	}
+	if msg.Subject != "Demande d'achat reçue - "+testEventName {
+		t.Errorf("unexpected subject %q", msg.Subject)
+	}
+	if !strings.Contains(msg.Parts["text/plain"], "Cloud credits (quantité : 1)") {
+		t.Errorf("unexpected plain-text part:\n%s", msg.Parts["text/plain"])
+	}
+	if !strings.Contains(msg.Parts["text/html"], "<b>Cloud credits</b>") {
+		t.Errorf("unexpected HTML part:\n%s", msg.Parts["text/html"])
+	}
+
+	email := s.outboxEmails()[0]
+	if email.Status != models.EmailStatusSent || email.Attempts != 1 || email.SentAt == nil {
+		t.Errorf("expected the email to be sent at the first attempt, got %+v", email)
+	}
+
+	// Sent emails are not sent again
+	s.makeDue(email.ID)
+	s.processOutbox(0)
+}
+
+func TestFailingEmailIsRetriedThenResent(t *testing.T) {
+	s, smtpServer := newSMTPTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 1))
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", purchases[0].ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	s.processOutbox(2)
+	smtpServer.failNext(smtpOutboxOptions.MaxAttempts)
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/mark-returned", purchases[0].ID), adminToken, nil), http.StatusOK, nil)
+	id := s.outboxEmails()[2].ID
+
+	// Each failure doubles the delay before the next attempt, up to MaxDelay
+	for attempt, delay := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		s.processOutbox(1)
+
+		var email models.EmailOutbox
+		s.db.First(&email, id)
+		if email.Status != models.EmailStatusPending || email.Attempts != attempt+1 || email.LastError == "" {
+			t.Fatalf("attempt %d: expected a pending email with its error, got %+v", attempt+1, email)
+		}
+		if wait := time.Until(email.NextAttemptAt); wait < delay-5*time.Second || wait > delay+5*time.Second {
+			t.Errorf("attempt %d: expected a retry in %s, got %s", attempt+1, delay, wait)
+		}
+
+		// Not due yet
+		s.processOutbox(0)
+		s.makeDue(id)
+	}
+
+	// The last attempt puts the email aside for the admins
+	s.processOutbox(1)
+	var failed []models.EmailOutbox
+	w := s.do(http.MethodGet, "/api/admin/emails?status="+url.QueryEscape(string(models.EmailStatusFailed)), adminToken, nil)
+	s.expect(w, http.StatusOK, &failed)
+	if len(failed) != 1 || failed[0].ID != id || failed[0].Attempts != smtpOutboxOptions.MaxAttempts || w.Header().Get("X-Total-Count") != "1" {
+		t.Fatalf("expected the email to be listed as failed, got %+v", failed)
+	}
+	if failed[0].HTML != "" || failed[0].Text != "" {
+		t.Errorf("the list should not include the bodies")
+	}
+	s.makeDue(id)
+	s.processOutbox(0)
+
+	var detail models.EmailOutbox
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/admin/emails/%d", id), adminToken, nil), http.StatusOK, &detail)
+	if detail.Template != "purchase_returned" || !strings.Contains(detail.LastError, "451") || detail.HTML == "" {
+		t.Errorf("unexpected email detail: %+v", detail)
+	}
+
+	// Resending starts a new series of attempts, recorded in the audit log
+	var resent models.EmailOutbox
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/emails/%d/resend", id), adminToken, nil), http.StatusOK, &resent)
+	if resent.Status != models.EmailStatusPending || resent.Attempts != 0 {
+		t.Errorf("expected the email to be pending again, got %+v", resent)
+	}
+	var audited int64
+	s.db.Model(&models.AuditEntry{}).Where("action = ? AND entity_id = ?", models.AuditEmailResend, id).Count(&audited)
+	if audited != 1 {
+		t.Errorf("expected the resend to be audited")
+	}
+
+	s.processOutbox(1)
+	if received := smtpServer.received(); len(received) != 3 || received[2].Subject != "Retour de ressource traité - "+testEventName {
+		t.Fatalf("expected the return email to be delivered, got %d emails", len(received))
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/emails/%d/resend", id), adminToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/emails/9999/resend", adminToken, nil), http.StatusNotFound, nil)
+}
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+var resetLink = regexp.MustCompile(regexp.QuoteMeta(testPublicURL) + `/reset-password\?token=([0-9a-f]+)`)
+
+// resetTokens returns the tokens of the reset links emailed to an address, oldest first
+func (s *testServer) resetTokens(to string) []string {
+	s.t.Helper()
+
+	var outbox []models.EmailOutbox
+	s.db.Where("recipient = ? AND template = ?", to, emails.PasswordReset).Order("id").Find(&outbox)
+	tokens := make([]string, 0, len(outbox))
+	for _, email := range outbox {
+		match := resetLink.FindStringSubmatch(email.Text)
+		if match == nil {
+			s.t.Fatalf("no reset link in email:\n%s", email.Text)
+		}
+		tokens = append(tokens, match[1])
+	}
+	return tokens
+}
+
+// login checks whether a team can log in with a password
+func (s *testServer) login(name, password string) int {
+	return s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": name, "password": password}).Code
+}
+
+func TestForgottenPasswordIsResetWithASingleUseLink(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "old-pass-1", 1000)
+	admin := s.createAdmin("root", "admin-pass-1")
+
+	// The answer does not tell whether the account exists
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "team", "identifier": "nobody"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "robot", "identifier": "alpha"}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "team", "identifier": "alpha"}), http.StatusOK, nil)
+
+	email := s.mailer.waitForEmail(t, team.Email)
+	if email.Subject != "Réinitialisation du mot de passe - "+testEventName || !strings.Contains(email.HTML, testPublicURL+"/reset-password?token=") {
+		t.Fatalf("unexpected reset email: %+v", email)
+	}
+
+	// A second request right away sends nothing more
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "team", "identifier": team.Email}), http.StatusOK, nil)
+	tokens := s.resetTokens(team.Email)
+	if len(tokens) != 1 {
+		t.Fatalf("expected a single reset email, got %d", len(tokens))
+	}
+
+	// The password policy applies, and a rejected password leaves the link usable
+	for _, weak := range []string{"short1", "onlyletters", "1234567890", "alpha-is-1st"} {
+		s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": tokens[0], "password": weak}), http.StatusBadRequest, nil)
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": tokens[0], "password": "n3w-passphrase"}), http.StatusOK, nil)
+	if s.login("alpha", "n3w-passphrase") != http.StatusOK || s.login("alpha", "old-pass-1") != http.StatusUnauthorized {
+		t.Fatalf("expected only the new password to work")
+	}
+
+	// The link works once
+	s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": tokens[0], "password": "an0ther-passphrase"}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": "unknown", "password": "an0ther-passphrase"}), http.StatusBadRequest, nil)
+
+	// Links expire
+	s.db.Model(&models.PasswordResetToken{}).Where("1 = 1").Update("created_at", time.Now().Add(-time.Hour))
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "team", "identifier": "alpha"}), http.StatusOK, nil)
+	tokens = s.resetTokens(team.Email)
+	if len(tokens) != 2 {
+		t.Fatalf("expected a second reset email, got %d", len(tokens))
+	}
+	s.db.Model(&models.PasswordResetToken{}).Where("used_at IS NULL").Update("expires_at", time.Now().Add(-time.Second))
+	s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": tokens[1], "password": "an0ther-passphrase"}), http.StatusBadRequest, nil)
+
+	// Admins reset theirs the same way, and changing the password voids the links
+	s.expect(s.do(http.MethodPost, "/api/auth/forgot-password", "", gin.H{"user_type": "admin", "identifier": "root"}), http.StatusOK, nil)
+	adminTokens := s.resetTokens(admin.Email)
+	if len(adminTokens) != 1 {
+		t.Fatalf("expected a reset email for the admin, got %d", len(adminTokens))
+	}
+	adminToken := s.token(admin.ID, "admin")
+	s.expect(s.do(http.MethodPut, "/api/auth/password", adminToken, gin.H{"current_password": "admin-pass-1", "new_password": "admin-pass-2"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/auth/reset-password", "", gin.H{"token": adminTokens[0], "password": "admin-pass-3"}), http.StatusBadRequest, nil)
+
+	// The outbox does not show the links to the other admins
+	var outbox []models.EmailOutbox
+	s.expect(s.do(http.MethodGet, "/api/admin/emails?template="+emails.PasswordReset, adminToken, nil), http.StatusOK, &outbox)
+	if len(outbox) != 3 {
+		t.Fatalf("expected 3 reset emails in the outbox, got %d", len(outbox))
+	}
+	var detail models.EmailOutbox
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/admin/emails/%d", outbox[0].ID), adminToken, nil), http.StatusOK, &detail)
+	if detail.HTML != "" || detail.Text != "" || detail.Subject == "" {
+		t.Errorf("expected the body of the reset email to be hidden, got %+v", detail)
+	}
+
+	// Nor when they resend a failed one
+	s.db.Model(&models.EmailOutbox{}).Where("id = ?", outbox[0].ID).Update("status", models.EmailStatusFailed)
+	var resent models.EmailOutbox
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/emails/%d/resend", outbox[0].ID), adminToken, nil), http.StatusOK, &resent)
+	if resent.HTML != "" || resent.Text != "" || resent.Status != models.EmailStatusPending {
+		t.Errorf("expected the body of the resent email to be hidden, got %+v", resent)
+	}
+}
+
+func TestPasswordIsChangedWithTheCurrentOne(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "old-pass-1", 1000)
+	token := s.token(team.ID, "team")
+
+	change := func(current, next string) int {
+		return s.do(http.MethodPut, "/api/auth/password", token, gin.H{"current_password": current, "new_password": next}).Code
+	}
+	if code := change("wrong-pass-1", "n3w-passphrase"); code != http.StatusBadRequest {
+		t.Errorf("a wrong current password should be rejected, got %d", code)
+	}
+	if code := change("old-pass-1", "old-pass-1"); code != http.StatusBadRequest {
+		t.Errorf("the same password should be rejected, got %d", code)
+	}
+	if code := change("old-pass-1", "Alpha-2025!"); code != http.StatusBadRequest {
+		t.Errorf("a password containing the team name should be rejected, got %d", code)
+	}
+	s.expect(s.do(http.MethodPut, "/api/auth/password", "", gin.H{"current_password": "old-pass-1", "new_password": "n3w-passphrase"}), http.StatusUnauthorized, nil)
+
+	// The other sessions are ended, not the one used to change the password
+	other := s.token(team.ID, "team")
+	if code := change("old-pass-1", "n3w-passphrase"); code != http.StatusOK {
+		t.Fatalf("expected the password to be changed, got %d", code)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/profile", token, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", other, nil), http.StatusUnauthorized, nil)
+	if s.login("alpha", "n3w-passphrase") != http.StatusOK || s.login("alpha", "old-pass-1") != http.StatusUnauthorized {
+		t.Errorf("expected only the new password to work")
+	}
+}
@@ -0,0 +1,273 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// batchResponse is the body of the batch approval endpoint
+type batchResponse struct {
+	SuccessCount int `json:"success_count"`
+	FailureCount int `json:"failure_count"`
+	Results      []struct {
+		PurchaseID uint   `json:"purchase_id"`
+		Success    bool   `json:"success"`
+		Error      string `json:"error"`
+		Status     string `json:"status"`
+		Quantity   int    `json:"quantity"`
+	} `json:"results"`
+}
+
+// buyBatch orders resources as a team and returns the pending purchases
+func (s *testServer) buyBatch(token string, items ...gin.H) []models.Purchase {
+	s.t.Helper()
+
+	var purchases []models.Purchase
+	body := gin.H{"items": items, "comment": "Needed for the demo of our project"}
+	s.expect(s.do(http.MethodPost, "/api/team/purchases/batch", token, body), http.StatusCreated, &purchases)
+	return purchases
+}
+
+func item(resourceID uint, quantity int) gin.H {
+	return gin.H{"resource_id": resourceID, "quantity": quantity}
+}
+
+func TestBatchPurchase(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+
+	purchases := s.buyBatch(token, item(cloud.ID, 2), item(board.ID, 3))
+
+	if len(purchases) != 2 {
+		t.Fatalf("expected 2 purchases, got %d", len(purchases))
+	}
+	for _, p := range purchases {
+		if p.Status != models.StatusPending {
+			t.Errorf("purchase %d: expected status %q, got %q", p.ID, models.StatusPending, p.Status)
+		}
+		if p.BatchID == nil || *p.BatchID != *purchases[0].BatchID {
+			t.Errorf("purchase %d: expected the batch ID of the order", p.ID)
+		}
+	}
+	if purchases[0].NeedsReturn || !purchases[1].NeedsReturn {
+		t.Errorf("only the returnable resource should need a return")
+	}
+
+	// The team is debited and the units are held until the order is processed
+	s.assertCredit(team.ID, 1000-2*100-3*50)
+	s.assertStock(cloud.ID, 10, 2)
+	s.assertStock(board.ID, 5, 3)
+
+	s.mailer.waitForEmail(t, team.Email)
+
+	// The team sees its own purchases
+	var listed []models.Purchase
+	w := s.do(http.MethodGet, "/api/team/purchases", token, nil)
+	s.expect(w, http.StatusOK, &listed)
+	if len(listed) != 2 || w.Header().Get("X-Total-Count") != "2" {
+		t.Errorf("expected 2 listed purchases, got %d (total %q)", len(listed), w.Header().Get("X-Total-Count"))
+	}
+}
+
+func TestBatchPurchaseIsAllOrNothing(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 300)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+	board := s.createResource("Raspberry Pi", 50, 1, 5, true)
+
+	body := gin.H{"comment": "Needed for the demo of our project"}
+
+	// Not enough credit for the whole order
+	body["items"] = []gin.H{item(cloud.ID, 2), item(board.ID, 1), item(cloud.ID, 1)}
+	s.expect(s.do(http.MethodPost, "/api/team/purchases/batch", token, body), http.StatusBadRequest, nil)
+
+	// Not enough stock for one of the items
+	body["items"] = []gin.H{item(cloud.ID, 1), item(board.ID, 2)}
+	s.expect(s.do(http.MethodPost, "/api/team/purchases/batch", token, body), http.StatusBadRequest, nil)
+
+	// Short comments are refused
+	s.expect(s.do(http.MethodPost, "/api/team/purchases/batch", token, gin.H{"items": []gin.H{item(cloud.ID, 1)}, "comment": "short"}), http.StatusBadRequest, nil)
+
+	var count int64
+	s.db.Model(&models.Purchase{}).Count(&count)
+	if count != 0 {
+		t.Errorf("expected no purchase, got %d", count)
+	}
+	s.assertCredit(team.ID, 300)
+	s.assertStock(cloud.ID, 10, 0)
+	s.assertStock(board.ID, 1, 0)
+}
+
+func TestPartialApproval(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 4), item(board.ID, 2))
+	s.assertCredit(team.ID, 1000-400-100)
+
+	one, zero, five := 1, 0, 5
+	var result batchResponse
+	s.expect(s.do(http.MethodPost, "/api/admin/purchases/batch/action", adminToken, gin.H{"items": []gin.H{
+		{"purchase_id": purchases[0].ID, "action": "confirm", "approved_quantity": one},
+		{"purchase_id": purchases[1].ID, "action": "confirm", "approved_quantity": five},
+	}}), http.StatusOK, &result)
+
+	// Approving more than requested fails without touching the purchase
+	if result.SuccessCount != 1 || result.FailureCount != 1 {
+		t.Fatalf("expected 1 success and 1 failure, got %+v", result)
+	}
+	if !result.Results[0].Success || result.Results[0].Quantity != 1 {
+		t.Errorf("unexpected result for the partial approval: %+v", result.Results[0])
+	}
+	if result.Results[1].Success || result.Results[1].Error == "" {
+		t.Errorf("expected an error for the over-approval: %+v", result.Results[1])
+	}
+
+	adjusted := s.purchase(purchases[0].ID)
+	if adjusted.Status != models.StatusConfirmed || adjusted.Quantity != 1 || adjusted.RequestedQuantity != 4 {
+		t.Errorf("unexpected adjusted purchase: status %q, quantity %d of %d", adjusted.Status, adjusted.Quantity, adjusted.RequestedQuantity)
+	}
+	if pending := s.purchase(purchases[1].ID); pending.Status != models.StatusPending {
+		t.Errorf("expected the failed item to stay pending, got %q", pending.Status)
+	}
+
+	// The 3 units that were not approved are refunded and released
+	s.assertCredit(team.ID, 1000-100-100)
+	s.assertStock(cloud.ID, 9, 0)
+	s.assertStock(board.ID, 5, 2)
+
+	// A zero quantity is not a valid approval
+	s.expect(s.do(http.MethodPost, "/api/admin/purchases/batch/action", adminToken, gin.H{"items": []gin.H{
+		{"purchase_id": purchases[1].ID, "action": "confirm", "approved_quantity": zero},
+	}}), http.StatusOK, &result)
+	if result.SuccessCount != 0 {
+		t.Errorf("expected the zero approval to fail, got %+v", result)
+	}
+}
+
+func TestCancellationRefundsCredit(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	var purchase models.Purchase
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", token, item(cloud.ID, 3)), http.StatusCreated, &purchase)
+	s.assertCredit(team.ID, 700)
+	s.assertStock(cloud.ID, 10, 3)
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", purchase.ID), adminToken, gin.H{"action": "cancel"}), http.StatusOK, &purchase)
+	if purchase.Status != models.StatusCancelled {
+		t.Errorf("expected status %q, got %q", models.StatusCancelled, purchase.Status)
+	}
+	s.assertCredit(team.ID, 1000)
+	s.assertStock(cloud.ID, 10, 0)
+
+	// A processed purchase cannot be cancelled, nor refunded, twice
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", purchase.ID), adminToken, gin.H{"action": "cancel"}), http.StatusBadRequest, nil)
+	s.assertCredit(team.ID, 1000)
+
+	// Batch cancellations refund the whole purchase
+	purchases := s.buyBatch(token, item(cloud.ID, 2))
+	var result batchResponse
+	s.expect(s.do(http.MethodPost, "/api/admin/purchases/batch/action", adminToken, gin.H{"items": []gin.H{
+		{"purchase_id": purchases[0].ID, "action": "cancel"},
+	}}), http.StatusOK, &result)
+	if result.SuccessCount != 1 || result.Results[0].Status != string(models.StatusCancelled) {
+		t.Errorf("unexpected batch result: %+v", result)
+	}
+	s.assertCredit(team.ID, 1000)
+	s.assertStock(cloud.ID, 10, 0)
+
+	var ledger models.LedgerResponse
+	s.expect(s.do(http.MethodGet, "/api/team/ledger", token, nil), http.StatusOK, &ledger)
+	if !ledger.Consistent || len(ledger.Transactions) != 5 {
+		t.Errorf("expected a consistent ledger of 5 entries, got %d (consistent %v)", len(ledger.Transactions), ledger.Consistent)
+	}
+}
+
+func TestRefundsUseThePricePaid(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(cloud.ID, 2))
+	var single models.Purchase
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(team.ID, "team"), item(cloud.ID, 3)), http.StatusCreated, &single)
+	if single.UnitCost != 100 {
+		t.Fatalf("expected the unit cost to be recorded, got %+v", single)
+	}
+	s.assertCredit(team.ID, 500)
+
+	// The price changes while the purchases are pending
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", cloud.ID), adminToken, gin.H{"cost": 400}), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", purchases[0].ID), adminToken, gin.H{"action": "cancel"}), http.StatusOK, nil)
+	s.assertCredit(team.ID, 700)
+
+	one := 1
+	s.expect(s.do(http.MethodPost, "/api/admin/purchases/batch/action", adminToken, gin.H{"items": []gin.H{
+		{"purchase_id": single.ID, "action": "confirm", "approved_quantity": one},
+	}}), http.StatusOK, nil)
+	s.assertCredit(team.ID, 900)
+}
+
+func TestReturns(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	other := s.createTeam("beta", "pass", 1000)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	purchases := s.buyBatch(token, item(board.ID, 2), item(cloud.ID, 1))
+	for _, p := range purchases {
+		s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", p.ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	}
+	s.assertStock(board.ID, 3, 0)
+	s.assertCredit(team.ID, 1000-100-100)
+
+	boardPath := fmt.Sprintf("/api/team/purchases/%d/return", purchases[0].ID)
+
+	// Only the owner can return a purchase
+	s.expect(s.do(http.MethodPost, boardPath, s.token(other.ID, "team"), nil), http.StatusForbidden, nil)
+
+	// Returned units are back in stock, without refund
+	s.expect(s.do(http.MethodPost, boardPath, token, nil), http.StatusOK, nil)
+	if !s.purchase(purchases[0].ID).IsReturned {
+		t.Errorf("expected the purchase to be returned")
+	}
+	s.assertStock(board.ID, 5, 0)
+	s.assertCredit(team.ID, 800)
+
+	s.expect(s.do(http.MethodPost, boardPath, token, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/team/purchases/%d/return", purchases[1].ID), token, nil), http.StatusBadRequest, nil)
+
+	// The admins can undo a return recorded by mistake, and record it again
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/unmark-returned", purchases[0].ID), adminToken, nil), http.StatusOK, nil)
+	s.assertStock(board.ID, 3, 0)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/mark-returned", purchases[0].ID), adminToken, nil), http.StatusOK, nil)
+	s.assertStock(board.ID, 5, 0)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/mark-returned", purchases[0].ID), adminToken, nil), http.StatusBadRequest, nil)
+
+	// Pending purchases cannot be returned
+	pending := s.buyBatch(token, item(board.ID, 1))
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/team/purchases/%d/return", pending[0].ID), token, nil), http.StatusBadRequest, nil)
+}
@@ -0,0 +1,184 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// processReminders runs the return reminder worker once and checks how many rentals
+// it reminded
+func (s *testServer) processReminders(want int) {
+	s.t.Helper()
+
+	reminded, err := s.reminders.ProcessDue()
+	if err != nil {
+		s.t.Fatalf("process reminders: %v", err)
+	}
+	if reminded != want {
+		s.t.Fatalf("expected %d rentals reminded, got %d", want, reminded)
+	}
+}
+
+// setReturnDue moves the return deadline of a rental
+func (s *testServer) setReturnDue(id uint, due time.Time) {
+	s.t.Helper()
+
+	if err := s.db.Model(&models.Purchase{}).Where("id = ?", id).Update("return_due_at", due).Error; err != nil {
+		s.t.Fatalf("update purchase: %v", err)
+	}
+}
+
+// queuedEmails returns the emails of the outbox rendered from a template
+func (s *testServer) queuedEmails(template string) []models.EmailOutbox {
+	s.t.Helper()
+
+	var outbox []models.EmailOutbox
+	if err := s.db.Where("template = ?", template).Order("id").Find(&outbox).Error; err != nil {
+		s.t.Fatalf("load outbox: %v", err)
+	}
+	return outbox
+}
+
+func TestRentalsGetAReturnDeadline(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+	cable := s.createResource("HDMI cable", 10, 5, 5, true)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", board.ID), adminToken, gin.H{"rental_minutes": 120}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", cable.ID), adminToken, gin.H{"rental_minutes": -1}), http.StatusBadRequest, nil)
+
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(board.ID, 1), item(cable.ID, 1), item(cloud.ID, 1))
+	confirmedAt := time.Now()
+	for _, p := range purchases {
+		s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", p.ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	}
+
+	// The rental duration of the resource, or the end of the event without one
+	due := s.purchase(purchases[0].ID).ReturnDueAt
+	if due == nil || due.Sub(confirmedAt) < 119*time.Minute || due.Sub(confirmedAt) > 121*time.Minute {
+		t.Errorf("expected the board to be due in 2 hours, got %v", due)
+	}
+	if due := s.purchase(purchases[1].ID).ReturnDueAt; due == nil || !due.Equal(s.event.EndsAt) {
+		t.Errorf("expected the cable to be due at the end of the event, got %v", due)
+	}
+	if due := s.purchase(purchases[2].ID).ReturnDueAt; due != nil {
+		t.Errorf("expected no deadline for a non-returnable resource, got %v", due)
+	}
+
+	// A rental never ends after the event
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/resources/%d", board.ID), adminToken, gin.H{"rental_minutes": 7 * 24 * 60}), http.StatusOK, nil)
+	late := s.buyBatch(s.token(team.ID, "team"), item(board.ID, 1))
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", late[0].ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	if due := s.purchase(late[0].ID).ReturnDueAt; due == nil || !due.Equal(s.event.EndsAt) {
+		t.Errorf("expected the rental to end with the event, got %v", due)
+	}
+
+	confirmation := s.queuedEmails(emails.PurchaseConfirmed)
+	if len(confirmation) == 0 || !strings.Contains(confirmation[0].Text, "À rendre avant le") {
+		t.Errorf("expected the deadline in the confirmation, got %+v", confirmation)
+	}
+}
+
+func TestOverdueRentalsAreReminded(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	s.createChannel(adminToken, gin.H{
+		"name": "Logistics", "type": "email", "target": "logistics@example.com", "events": []string{notify.EventReturnOverdue},
+	})
+	alpha := s.createTeam("alpha", "pass", 1000)
+	alphaToken := s.token(alpha.ID, "team")
+	beta := s.createTeam("beta", "pass", 1000)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+	cable := s.createResource("HDMI cable", 10, 5, 5, true)
+
+	rentals := s.buyBatch(alphaToken, item(board.ID, 1), item(cable.ID, 2))
+	rentals = append(rentals, s.buyBatch(s.token(beta.ID, "team"), item(board.ID, 1))...)
+	for _, p := range rentals {
+		s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", p.ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	}
+
+	// Nothing is due before the reminder window
+	s.processReminders(0)
+
+	// The team is reminded once before the deadline, with its rentals in one email
+	s.setReturnDue(rentals[0].ID, time.Now().Add(30*time.Minute))
+	s.setReturnDue(rentals[1].ID, time.Now().Add(45*time.Minute))
+	s.processReminders(2)
+	s.processReminders(0)
+	reminders := s.queuedEmails(emails.ReturnReminder)
+	if len(reminders) != 1 || reminders[0].Recipient != alpha.Email || reminders[0].Subject != "Rappel : matériel à rendre - "+testEventName {
+		t.Fatalf("expected one reminder for alpha, got %+v", reminders)
+	}
+	if !strings.Contains(reminders[0].Text, "Raspberry Pi (quantité : 1)") || !strings.Contains(reminders[0].Text, "HDMI cable (quantité : 2)") {
+		t.Errorf("expected both rentals in:\n%s", reminders[0].Text)
+	}
+
+	var overdue []models.Purchase
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases?overdue=true", adminToken, nil), http.StatusOK, &overdue)
+	if len(overdue) != 0 {
+		t.Fatalf("expected no overdue rental yet, got %+v", overdue)
+	}
+
+	// Once late, the team and the staff are told, once
+	s.setReturnDue(rentals[0].ID, time.Now().Add(-time.Minute))
+	s.processReminders(1)
+	s.processReminders(0)
+	late := s.queuedEmails(emails.ReturnOverdue)
+	if len(late) != 1 || late[0].Recipient != alpha.Email || !strings.Contains(late[0].Text, "Raspberry Pi") || strings.Contains(late[0].Text, "HDMI cable") {
+		t.Fatalf("expected one overdue email for the board, got %+v", late)
+	}
+	deliveries := s.deliveries()
+	if len(deliveries) != 1 || deliveries[0].Event != notify.EventReturnOverdue || !strings.Contains(string(deliveries[0].Payload), "Raspberry Pi") {
+		t.Fatalf("expected the staff to be notified, got %+v", deliveries)
+	}
+	s.mailer.waitForEmail(t, "logistics@example.com")
+
+	// Admins and the team find the late rentals
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases?overdue=true&sort=due", adminToken, nil), http.StatusOK, &overdue)
+	if len(overdue) != 1 || overdue[0].ID != rentals[0].ID {
+		t.Fatalf("expected the board of alpha to be overdue, got %+v", overdue)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/purchases?overdue=true", alphaToken, nil), http.StatusOK, &overdue)
+	if len(overdue) != 1 || overdue[0].ID != rentals[0].ID {
+		t.Fatalf("expected the team to see its overdue rental, got %+v", overdue)
+	}
+
+	// A returned rental is no longer overdue
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/mark-returned", rentals[0].ID), adminToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases?overdue=true", adminToken, nil), http.StatusOK, &overdue)
+	if len(overdue) != 0 {
+		t.Errorf("expected no overdue rental after the return, got %+v", overdue)
+	}
+}
+
+func TestSingleRentalBecomesOverdue(t *testing.T) {
+	s := newTestServer(t)
+	adminToken := s.token(s.createAdmin("root", "pass").ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	board := s.createResource("Raspberry Pi", 50, 5, 5, true)
+
+	var rental models.Purchase
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(team.ID, "team"), item(board.ID, 1)), http.StatusCreated, &rental)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/purchases/%d/action", rental.ID), adminToken, gin.H{"action": "confirm"}), http.StatusOK, nil)
+	if due := s.purchase(rental.ID).ReturnDueAt; due == nil || !due.Equal(s.event.EndsAt) {
+		t.Fatalf("expected the rental to be due at the end of the event, got %v", due)
+	}
+
+	s.setReturnDue(rental.ID, time.Now().Add(-time.Minute))
+	var overdue []models.Purchase
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases?overdue=true", adminToken, nil), http.StatusOK, &overdue)
+	if len(overdue) != 1 || overdue[0].ID != rental.ID {
+		t.Errorf("expected the rental to be overdue, got %+v", overdue)
+	}
+}
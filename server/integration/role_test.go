@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// createAdminWithRole creates an admin and gives it a role
+func (s *testServer) createAdminWithRole(username string, role models.AdminRole) models.Admin {
+	s.t.Helper()
+
+	admin := s.createAdmin(username, "admin-pass")
+	if err := s.db.Model(&admin).Update("role", role).Error; err != nil {
+		s.t.Fatalf("set role: %v", err)
+	}
+	return admin
+}
+
+func TestAdminRolesLimitTheRoutes(t *testing.T) {
+	s := newTestServer(t)
+	cashier := s.token(s.createAdminWithRole("cashier", models.RoleCashier).ID, "admin")
+	logistics := s.token(s.createAdminWithRole("logistics", models.RoleLogistics).ID, "admin")
+	viewer := s.token(s.createAdminWithRole("viewer", models.RoleViewer).ID, "admin")
+	team := s.createTeam("alpha", "pass", 1000)
+	board := s.createResource("Raspberry Pi", 100, 5, 5, true)
+	purchases := s.buyBatch(s.token(team.ID, "team"), item(board.ID, 1))
+	action := fmt.Sprintf("/api/admin/purchases/%d/action", purchases[0].ID)
+	markReturned := fmt.Sprintf("/api/admin/purchases/%d/mark-returned", purchases[0].ID)
+
+	// Every role can read
+	for _, token := range []string{cashier, logistics, viewer} {
+		s.expect(s.do(http.MethodGet, "/api/admin/purchases", token, nil), http.StatusOK, nil)
+	}
+
+	var refused struct {
+		Error              string            `json:"error"`
+		RequiredPermission models.Permission `json:"required_permission"`
+	}
+	s.expect(s.do(http.MethodPost, action, viewer, gin.H{"action": "confirm"}), http.StatusForbidden, &refused)
+	if refused.RequiredPermission != models.PermissionPurchases {
+		t.Errorf("expected the missing permission in the error, got %+v", refused)
+	}
+	s.expect(s.do(http.MethodPost, action, logistics, gin.H{"action": "confirm"}), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, action, cashier, gin.H{"action": "confirm"}), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, markReturned, cashier, nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, markReturned, logistics, nil), http.StatusOK, nil)
+
+	// Only super-admins manage the catalog, the teams and the admins
+	for _, token := range []string{cashier, logistics, viewer} {
+		s.expect(s.do(http.MethodPost, "/api/admin/resources", token, gin.H{"name": "Arduino", "cost": 10, "quantity": 1, "max_per_team": 1, "type": "matériel"}), http.StatusForbidden, nil)
+		s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/teams/%d/suspend", team.ID), token, nil), http.StatusForbidden, nil)
+		s.expect(s.do(http.MethodGet, "/api/admin/admins", token, nil), http.StatusForbidden, nil)
+	}
+}
+
+func TestSuperAdminChangesRoles(t *testing.T) {
+	s := newTestServer(t)
+	root := s.createAdmin("root", "admin-pass")
+	rootToken := s.token(root.ID, "admin")
+	helper := s.createAdminWithRole("helper", models.RoleViewer)
+	helperToken := s.token(helper.ID, "admin")
+
+	var admins []models.Admin
+	s.expect(s.do(http.MethodGet, "/api/admin/admins", rootToken, nil), http.StatusOK, &admins)
+	if len(admins) != 2 || admins[1].Username != "root" || admins[1].Role != models.RoleSuperAdmin {
+		t.Fatalf("unexpected admins: %+v", admins)
+	}
+
+	path := fmt.Sprintf("/api/admin/admins/%d/role", helper.ID)
+	s.expect(s.do(http.MethodPut, path, rootToken, gin.H{"role": "janitor"}), http.StatusBadRequest, nil)
+	var updated models.Admin
+	s.expect(s.do(http.MethodPut, path, rootToken, gin.H{"role": "cashier"}), http.StatusOK, &updated)
+	if updated.Role != models.RoleCashier {
+		t.Fatalf("expected the helper to be a cashier, got %+v", updated)
+	}
+
+	// The sessions of the helper end, its next login carries the new role
+	s.expect(s.do(http.MethodGet, "/api/admin/purchases", helperToken, nil), http.StatusUnauthorized, nil)
+	var login struct {
+		Token string `json:"token"`
+		Admin struct {
+			Role        models.AdminRole    `json:"role"`
+			Permissions []models.Permission `json:"permissions"`
+		} `json:"admin"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/admin/login", "", gin.H{"username": "helper", "password": "admin-pass"}), http.StatusOK, &login)
+	if login.Admin.Role != models.RoleCashier || len(login.Admin.Permissions) != 2 {
+		t.Errorf("unexpected admin profile: %+v", login.Admin)
+	}
+	s.expect(s.do(http.MethodPost, "/api/admin/purchases/batch/action", login.Token, gin.H{"items": []gin.H{}}), http.StatusBadRequest, nil)
+
+	var entries []models.AuditEntry
+	s.db.Where("action = ?", models.AuditAdminUpdateRole).Find(&entries)
+	if len(entries) != 1 || entries[0].AdminID != root.ID || entries[0].EntityID != helper.ID {
+		t.Errorf("expected the role change to be audited, got %+v", entries)
+	}
+
+	// Someone must keep the super-admin role
+	s.expect(s.do(http.MethodPut, fmt.Sprintf("/api/admin/admins/%d/role", root.ID), rootToken, gin.H{"role": "viewer"}), http.StatusConflict, nil)
+}
@@ -0,0 +1,123 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+// loginTeam logs a team in and returns its tokens
+func (s *testServer) loginTeam(name, password string) models.TokenResponse {
+	s.t.Helper()
+
+	var login struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/team/login", "", gin.H{"name": name, "password": password}), http.StatusOK, &login)
+	return models.TokenResponse{AccessToken: login.Token, RefreshToken: login.RefreshToken, TokenType: login.TokenType, ExpiresIn: login.ExpiresIn}
+}
+
+// refresh exchanges a refresh token and returns the status and the new tokens
+func (s *testServer) refresh(refreshToken string) (int, models.TokenResponse) {
+	s.t.Helper()
+
+	var tokens models.TokenResponse
+	w := s.do(http.MethodPost, "/api/auth/refresh", "", gin.H{"refresh_token": refreshToken})
+	if w.Code == http.StatusOK {
+		s.expect(w, http.StatusOK, &tokens)
+	}
+	return w.Code, tokens
+}
+
+func TestRefreshTokensAreRotated(t *testing.T) {
+	s := newTestServer(t)
+	s.createTeam("alpha", "s3cret-pass", 1000)
+
+	login := s.loginTeam("alpha", "s3cret-pass")
+	if login.RefreshToken == "" || login.TokenType != "Bearer" || login.ExpiresIn != 900 {
+		t.Fatalf("unexpected login tokens: %+v", login)
+	}
+
+	code, refreshed := s.refresh(login.RefreshToken)
+	if code != http.StatusOK || refreshed.AccessToken == login.AccessToken || refreshed.RefreshToken == login.RefreshToken {
+		t.Fatalf("expected new tokens, got %d %+v", code, refreshed)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/profile", refreshed.AccessToken, nil), http.StatusOK, nil)
+	if code, _ := s.refresh("unknown"); code != http.StatusUnauthorized {
+		t.Errorf("expected an unknown refresh token to be refused, got %d", code)
+	}
+
+	// Presenting a replaced refresh token again ends the session for everyone
+	if code, _ := s.refresh(login.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("expected the replaced refresh token to be refused, got %d", code)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/profile", refreshed.AccessToken, nil), http.StatusUnauthorized, nil)
+	if code, _ := s.refresh(refreshed.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("expected the session to be revoked, got %d", code)
+	}
+
+	// Suspended teams cannot refresh their session
+	login = s.loginTeam("alpha", "s3cret-pass")
+	s.db.Model(&models.Team{}).Where("name = ?", "alpha").Update("is_suspended", true)
+	if code, _ := s.refresh(login.RefreshToken); code != http.StatusForbidden {
+		t.Errorf("expected a suspended team to be refused, got %d", code)
+	}
+}
+
+func TestSessionsAreRevoked(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "s3cret-pass", 1000)
+	admin := s.createAdmin("root", "admin-pass")
+	adminToken := s.token(admin.ID, "admin")
+
+	laptop := s.loginTeam("alpha", "s3cret-pass")
+	phone := s.loginTeam("alpha", "s3cret-pass")
+	tablet := s.loginTeam("alpha", "s3cret-pass")
+
+	// Logging out only ends the session of the token
+	s.expect(s.do(http.MethodPost, "/api/auth/logout", laptop.AccessToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", laptop.AccessToken, nil), http.StatusUnauthorized, nil)
+	if code, _ := s.refresh(laptop.RefreshToken); code != http.StatusUnauthorized {
+		t.Errorf("expected the refresh token of a closed session to be refused, got %d", code)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/profile", phone.AccessToken, nil), http.StatusOK, nil)
+
+	// An organizer kills a compromised session
+	var sessions []models.Session
+	w := s.do(http.MethodGet, fmt.Sprintf("/api/admin/sessions?user_type=team&user_id=%d", team.ID), adminToken, nil)
+	s.expect(w, http.StatusOK, &sessions)
+	if len(sessions) != 2 || w.Header().Get("X-Total-Count") != "2" {
+		t.Fatalf("expected the 2 open sessions of the team, got %+v", sessions)
+	}
+	s.expect(s.do(http.MethodGet, "/api/admin/sessions?user_type=robot", adminToken, nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/sessions", phone.AccessToken, nil), http.StatusForbidden, nil)
+
+	tabletSession := sessions[0].ID // Most recently used first
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/sessions/%d/revoke", tabletSession), adminToken, nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", tablet.AccessToken, nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/sessions/%d/revoke", tabletSession), adminToken, nil), http.StatusBadRequest, nil)
+	var entries []models.AuditEntry
+	s.db.Where("action = ?", models.AuditSessionRevoke).Find(&entries)
+	if len(entries) != 1 || entries[0].EntityID != tabletSession {
+		t.Errorf("expected the revocation to be audited, got %+v", entries)
+	}
+
+	// Logging out everywhere ends the remaining sessions
+	other := s.loginTeam("alpha", "s3cret-pass")
+	var result struct {
+		Revoked int `json:"revoked"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/auth/logout-all", phone.AccessToken, nil), http.StatusOK, &result)
+	if result.Revoked != 2 {
+		t.Errorf("expected 2 sessions to be revoked, got %d", result.Revoked)
+	}
+	s.expect(s.do(http.MethodGet, "/api/team/profile", phone.AccessToken, nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/team/profile", other.AccessToken, nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/admin/teams", adminToken, nil), http.StatusOK, nil)
+}
@@ -0,0 +1,179 @@
+package integration
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpMessage is an email received by fakeSMTP
+type smtpMessage struct {
+	From    string
+	To      []string
+	Subject string
+	Parts   map[string]string // Decoded body by content type
+}
+
+// fakeSMTP is a local SMTP server that records the messages it receives. It can be
+// told to reject the next messages, as a server having trouble would.
+type fakeSMTP struct {
+	t        *testing.T
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []smtpMessage
+	failures int
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("start fake SMTP server: %v", err)
+	}
+	s := &fakeSMTP{t: t, listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// hostPort returns the address of the server
+func (s *fakeSMTP) hostPort() (string, string) {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host, port
+}
+
+// failNext rejects the next n messages with a temporary error
+func (s *fakeSMTP) failNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// received returns the messages received so far
+func (s *fakeSMTP) received() []smtpMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpMessage(nil), s.messages...)
+}
+
+// serve speaks just enough SMTP for net/smtp.SendMail with PLAIN authentication
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+
+	var from string
+	var to []string
+	tp.PrintfLine("220 localhost fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "HELO", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "AUTH":
+			tp.PrintfLine("235 Authentication successful")
+		case "MAIL":
+			s.mu.Lock()
+			failing := s.failures > 0
+			if failing {
+				s.failures--
+			}
+			s.mu.Unlock()
+			if failing {
+				tp.PrintfLine("451 Temporary failure, try again later")
+				continue
+			}
+			from, to = line[len("MAIL FROM:"):], nil
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			to = append(to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg, err := parseMessage(string(data))
+			if err != nil {
+				s.t.Errorf("fake SMTP server: invalid message: %v", err)
+				tp.PrintfLine("554 Invalid message")
+				continue
+			}
+			msg.From, msg.To = strings.Trim(from, "<>"), to
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			tp.PrintfLine("250 OK")
+		case "RSET":
+			from, to = "", nil
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+// parseMessage decodes the subject and the parts of a multipart/alternative email
+func parseMessage(data string) (smtpMessage, error) {
+	raw, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(data)))
+	if err != nil {
+		return smtpMessage{}, err
+	}
+
+	msg := smtpMessage{Parts: map[string]string{}}
+	if msg.Subject, err = new(mime.WordDecoder).DecodeHeader(raw.Header.Get("Subject")); err != nil {
+		return smtpMessage{}, err
+	}
+
+	_, params, err := mime.ParseMediaType(raw.Header.Get("Content-Type"))
+	if err != nil {
+		return smtpMessage{}, err
+	}
+	parts := multipart.NewReader(raw.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return smtpMessage{}, err
+		}
+		// Quoted-printable parts are decoded by the reader
+		body, err := io.ReadAll(part)
+		if err != nil {
+			return smtpMessage{}, err
+		}
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		msg.Parts[mediaType] = string(body)
+	}
+	return msg, nil
+}
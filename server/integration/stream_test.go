@@ -0,0 +1,109 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/middleware"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/gin-gonic/gin"
+)
+
+// lastSession returns the latest session opened by an account
+func (s *testServer) lastSession(userType string, userID uint) models.Session {
+	s.t.Helper()
+
+	var session models.Session
+	if err := s.db.Where("user_type = ? AND user_id = ?", userType, userID).Last(&session).Error; err != nil {
+		s.t.Fatalf("load session: %v", err)
+	}
+	return session
+}
+
+// received drains the events waiting for a subscriber
+func received(sub *events.Subscriber) []events.Event {
+	var got []events.Event
+	for {
+		select {
+		case event := <-sub.Events:
+			got = append(got, event)
+		default:
+			return got
+		}
+	}
+}
+
+func TestAdminsOnlyReceiveTheirEvent(t *testing.T) {
+	s := newTestServer(t)
+	past := s.createEvent("Hackathon 2024")
+	admin := s.createAdmin("root", "pass")
+	team := s.createTeam("alpha", "pass", 1000)
+	cloud := s.createResource("Cloud credits", 100, 10, 5, false)
+
+	current := s.broker.Subscribe("admin", admin.ID, s.event.ID)
+	defer s.broker.Unsubscribe(current)
+	other := s.broker.Subscribe("admin", admin.ID, past.ID)
+	defer s.broker.Unsubscribe(other)
+
+	s.expect(s.do(http.MethodPost, "/api/team/purchases", s.token(team.ID, "team"), item(cloud.ID, 1)), http.StatusCreated, nil)
+
+	if got := received(current); len(got) != 1 || got[0].Type != events.PurchaseCreated {
+		t.Errorf("expected the purchase on the current event, got %+v", got)
+	}
+	if got := received(other); len(got) != 0 {
+		t.Errorf("expected nothing on another event, got %+v", got)
+	}
+}
+
+func TestStreamSessionIsChecked(t *testing.T) {
+	s := newTestServer(t)
+	past := s.createEvent("Hackathon 2024")
+	team := s.createTeam("alpha", "pass", 1000)
+	admin := s.createAdmin("root", "pass")
+
+	// An admin stream ends once the admin selects another event
+	adminToken := s.token(admin.ID, "admin")
+	adminSession := s.lastSession("admin", admin.ID)
+	if eventID, err := services.SessionEvent(s.db, "admin", admin.ID, adminSession.ID); err != nil || eventID != s.event.ID {
+		t.Fatalf("expected the current event, got %d, %v", eventID, err)
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/events/%d/switch", past.ID), adminToken, nil), http.StatusOK, nil)
+	if eventID, _ := services.SessionEvent(s.db, "admin", admin.ID, adminSession.ID); eventID != past.ID {
+		t.Errorf("expected the selected event, got %d", eventID)
+	}
+
+	// A team stream ends with the suspension of the team or the end of its session
+	teamToken := s.token(team.ID, "team")
+	teamSession := s.lastSession("team", team.ID)
+	s.db.Model(&team).Update("is_suspended", true)
+	if _, err := services.SessionEvent(s.db, "team", team.ID, teamSession.ID); err == nil {
+		t.Error("expected a suspended team to be refused")
+	}
+	s.db.Model(&team).Update("is_suspended", false)
+	s.expect(s.do(http.MethodPost, "/api/auth/logout", teamToken, nil), http.StatusOK, nil)
+	if _, err := services.SessionEvent(s.db, "team", team.ID, teamSession.ID); err == nil {
+		t.Error("expected a closed session to be refused")
+	}
+}
+
+func TestLoggerHidesStreamTokens(t *testing.T) {
+	var logs bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	defer func() { gin.DefaultWriter = writer }()
+
+	router := gin.New()
+	router.Use(middleware.Logger())
+	router.GET("/api/events", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/events?token=s3cret&since=4", nil))
+
+	if strings.Contains(logs.String(), "s3cret") || !strings.Contains(logs.String(), "since=4") {
+		t.Errorf("expected the token to be redacted, got %q", logs.String())
+	}
+}
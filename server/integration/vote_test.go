@@ -0,0 +1,136 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/gin-gonic/gin"
+)
+
+func vote(pollID uint, option string, stake int) gin.H {
+	return gin.H{"poll_id": pollID, "chosen_option": option, "credit_staked": stake}
+}
+
+func TestVote(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 1000)
+	token := s.token(team.ID, "team")
+	poll := s.createPoll("Who wins the jury prize?", "Alpha", "Beta")
+
+	var cast models.Vote
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Alpha", 200)), http.StatusCreated, &cast)
+	if cast.ChosenOption != "Alpha" || cast.CreditStaked != 200 {
+		t.Errorf("unexpected vote: %+v", cast)
+	}
+	s.assertCredit(team.ID, 800)
+
+	var fetched models.Vote
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/team/votes/poll/%d", poll.ID), token, nil), http.StatusOK, &fetched)
+	if fetched.ID != cast.ID {
+		t.Errorf("expected vote %d, got %d", cast.ID, fetched.ID)
+	}
+
+	var results struct {
+		Results map[string]struct {
+			Count        int `json:"count"`
+			TotalCredits int `json:"total_credits"`
+		} `json:"results"`
+	}
+	s.expect(s.do(http.MethodGet, fmt.Sprintf("/api/polls/%d/results", poll.ID), "", nil), http.StatusOK, &results)
+	if r := results.Results["Alpha"]; r.Count != 1 || r.TotalCredits != 200 {
+		t.Errorf("unexpected results: %+v", results.Results)
+	}
+
+	// One vote per team and poll
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Beta", 100)), http.StatusBadRequest, nil)
+	s.assertCredit(team.ID, 800)
+}
+
+func TestVoteIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	team := s.createTeam("alpha", "pass", 100)
+	token := s.token(team.ID, "team")
+	poll := s.createPoll("Who wins the jury prize?", "Alpha", "Beta")
+	closed := s.createPoll("Best pitch?", "Alpha", "Beta")
+	if err := s.db.Model(&closed).Update("status", models.PollStatusClosed).Error; err != nil {
+		t.Fatalf("close poll: %v", err)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Gamma", 10)), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(poll.ID, "Alpha", 101)), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(closed.ID, "Alpha", 10)), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", token, vote(9999, "Alpha", 10)), http.StatusNotFound, nil)
+
+	s.assertCredit(team.ID, 100)
+}
+
+func TestPollResolutionPaysWinners(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	alpha := s.createTeam("alpha", "pass", 1000)
+	beta := s.createTeam("beta", "pass", 1000)
+	gamma := s.createTeam("gamma", "pass", 1000)
+	poll := s.createPoll("Who wins the jury prize?", "Alpha", "Beta")
+
+	s.expect(s.do(http.MethodPost, "/api/team/votes", s.token(alpha.ID, "team"), vote(poll.ID, "Alpha", 300)), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", s.token(beta.ID, "team"), vote(poll.ID, "Alpha", 100)), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", s.token(gamma.ID, "team"), vote(poll.ID, "Beta", 400)), http.StatusCreated, nil)
+
+	path := fmt.Sprintf("/api/admin/polls/%d/resolve", poll.ID)
+	var resolution struct {
+		Pool      int `json:"pool"`
+		HouseTake int `json:"house_take"`
+	}
+	s.expect(s.do(http.MethodPost, path, adminToken, gin.H{"winning_option": "Alpha", "house_cut_percent": 10}), http.StatusOK, &resolution)
+	if resolution.Pool != 800 || resolution.HouseTake != 80 {
+		t.Errorf("expected a pool of 800 and a house take of 80, got %+v", resolution)
+	}
+
+	// The 720 left are split 3 to 1 between the winners
+	s.assertCredit(alpha.ID, 1000-300+540)
+	s.assertCredit(beta.ID, 1000-100+180)
+	s.assertCredit(gamma.ID, 1000-400)
+
+	// Resolving again with the same option pays nothing more
+	var again struct {
+		AlreadyResolved bool `json:"already_resolved"`
+	}
+	s.expect(s.do(http.MethodPost, path, adminToken, gin.H{"winning_option": "Alpha"}), http.StatusOK, &again)
+	if !again.AlreadyResolved {
+		t.Errorf("expected the poll to be already resolved")
+	}
+	s.expect(s.do(http.MethodPost, path, adminToken, gin.H{"winning_option": "Beta"}), http.StatusConflict, nil)
+	s.assertCredit(alpha.ID, 1240)
+}
+
+func TestPollCancellationRefundsStakes(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.createAdmin("root", "pass")
+	adminToken := s.token(admin.ID, "admin")
+	alpha := s.createTeam("alpha", "pass", 1000)
+	beta := s.createTeam("beta", "pass", 1000)
+	poll := s.createPoll("Who wins the jury prize?", "Alpha", "Beta")
+
+	s.expect(s.do(http.MethodPost, "/api/team/votes", s.token(alpha.ID, "team"), vote(poll.ID, "Alpha", 300)), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/team/votes", s.token(beta.ID, "team"), vote(poll.ID, "Beta", 150)), http.StatusCreated, nil)
+
+	var cancellation struct {
+		RefundedVotes   int `json:"refunded_votes"`
+		RefundedCredits int `json:"refunded_credits"`
+	}
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/cancel", poll.ID), adminToken, nil), http.StatusOK, &cancellation)
+	if cancellation.RefundedVotes != 2 || cancellation.RefundedCredits != 450 {
+		t.Errorf("unexpected cancellation: %+v", cancellation)
+	}
+	s.assertCredit(alpha.ID, 1000)
+	s.assertCredit(beta.ID, 1000)
+
+	// A cancelled poll can neither be resolved nor refunded again
+	s.expect(s.do(http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/resolve", poll.ID), adminToken, gin.H{"winning_option": "Alpha"}), http.StatusBadRequest, nil)
+	s.do(http.MethodPost, fmt.Sprintf("/api/admin/polls/%d/cancel", poll.ID), adminToken, nil)
+	s.assertCredit(alpha.ID, 1000)
+	s.assertCredit(beta.ID, 1000)
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"log"
 
 	"github.com/ericp/ylab-hackathon/config"
-	"github.com/ericp/ylab-hackathon/controllers"
 	_ "github.com/ericp/ylab-hackathon/docs"
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
 	"github.com/ericp/ylab-hackathon/middleware"
-	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/migrations"
 	"github.com/ericp/ylab-hackathon/routes"
+	"github.com/ericp/ylab-hackathon/services"
+	"github.com/ericp/ylab-hackathon/utils"
 	"github.com/gin-gonic/gin"
 )
 
@@ -31,33 +35,63 @@ func main() {
 	// Connect to database
 	config.ConnectDatabase()
 
-	// Run migrations
-	err := config.DB.AutoMigrate(
-		&models.Team{},
-		&models.Admin{},
-		&models.Resource{},
-		&models.Purchase{},
-		&models.Poll{},
-		&models.Vote{},
-		&models.TeamComposition{},
-	)
+	// The schema is managed by `migrate up`, refuse to start on any other version
+	if err := migrations.CheckSchema(config.DB); err != nil {
+		log.Fatalf("Database schema check failed: %v (run `migrate up` with this version first)", err)
+	}
+
+	// Make sure every team balance can be derived from the credit ledger
+	if err := services.EnsureOpeningBalances(config.DB); err != nil {
+		log.Fatal("Failed to initialize credit ledger:", err)
+	}
+	if err := services.SyncReservedStock(config.DB); err != nil {
+		log.Fatal("Failed to sync reserved stock:", err)
+	}
+
+	templates, err := emails.NewRegistry(config.AppConfig.EventName)
 	if err != nil {
-		log.Fatal("Failed to run migrations:", err)
+		log.Fatal("Failed to load email templates: ", err)
 	}
 
-	log.Println("Database migrations completed successfully")
+	// Shared dependencies of the services
+	deps := services.Deps{
+		DB: config.DB,
+		Mailer: utils.NewEmailService(
+			config.AppConfig.SMTPHost,
+			config.AppConfig.SMTPPort,
+			config.AppConfig.SMTPUser,
+			config.AppConfig.SMTPPass,
+			config.AppConfig.SMTPFrom,
+		),
+		Templates: templates,
+		Events:    events.NewBroker(),
+		PublicURL: config.AppConfig.PublicURL,
+	}
+
+	// Send the emails of the outbox and the staff notifications in the background.
+	// Emails wait in the outbox while SMTP is not configured.
+	workerDeps := deps
+	if config.AppConfig.SMTPUser == "" || config.AppConfig.SMTPPass == "" {
+		log.Println("SMTP is not configured, emails are kept in the outbox")
+		workerDeps.Mailer = nil
+	}
+	go services.NewOutboxWorker(workerDeps, services.DefaultOutboxOptions).Run(context.Background())
 
-	// Initialize email service
-	controllers.InitEmailService()
+	// Remind the teams of their rentals before and after the return deadline
+	go services.NewReturnReminderWorker(deps, services.DefaultReturnReminderOptions).Run(context.Background())
 
 	// Setup Gin router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
+	if err := router.SetTrustedProxies(config.AppConfig.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	// Add middleware
 	router.Use(middleware.CorsMiddleware())
 
 	// Setup routes
-	routes.SetupRoutes(router)
+	routes.SetupRoutes(router, deps)
 
 	// Start server
 	port := ":" + config.AppConfig.ServerPort
@@ -1,15 +1,21 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/ericp/ylab-hackathon/config"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
 	"github.com/ericp/ylab-hackathon/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(requiredType string) gin.HandlerFunc {
+// AuthMiddleware authenticates the bearer token against the sessions of db and, unless
+// requiredType is empty, checks the user type
+func AuthMiddleware(db *gorm.DB, requiredType string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,21 +31,82 @@ func AuthMiddleware(requiredType string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ValidateToken(tokenString, config.AppConfig.JWTSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		if !authenticate(c, db, tokenString, requiredType) {
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		if requiredType != "" && claims.UserType != requiredType {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+// StreamAuthMiddleware authenticates the event stream. Browsers cannot set headers on
+// an EventSource, so the token may also be passed in the "token" query parameter.
+func StreamAuthMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString = c.Query("token")
+		}
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("user_type", claims.UserType)
+		if !authenticate(c, db, tokenString, "") {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// authenticate validates the token, checks its session and the user type and stores
+// the user in the context. It writes the error response and returns false when access
+// is denied.
+func authenticate(c *gin.Context, db *gorm.DB, tokenString, requiredType string) bool {
+	claims, err := utils.ValidateToken(tokenString, config.AppConfig.JWTSecret)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return false
+	}
+
+	// Logging out, revoking the session or suspending the team rejects its tokens
+	// before they expire
+	eventID, err := services.SessionEvent(db, claims.UserType, claims.UserID, claims.SessionID)
+	if err != nil {
+		status := http.StatusUnauthorized
+		var svcErr *services.Error
+		if errors.As(err, &svcErr) && svcErr.Kind == services.KindForbidden {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return false
+	}
+
+	if requiredType != "" && claims.UserType != requiredType {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+		return false
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("user_type", claims.UserType)
+	c.Set("session_id", claims.SessionID)
+	c.Set("event_id", eventID)
+	c.Set("admin_role", models.AdminRole(claims.Role))
+	return true
+}
+
+// RequirePermission lets through the admins whose role grants the permission. It runs
+// after AuthMiddleware(db, "admin").
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("admin_role")
+		if r, _ := role.(models.AdminRole); !r.Can(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions", "required_permission": permission})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
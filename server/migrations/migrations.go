@@ -0,0 +1,331 @@
+// Package migrations applies the versioned SQL scripts embedded in the binary. Each
+// script sql/NNNN_name.up.sql has a matching NNNN_name.down.sql that reverts it, and
+// the applied versions are recorded in the schema_migrations table.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql/*.sql
+var scripts embed.FS
+
+var (
+	// ErrPendingMigrations means the database is behind the code
+	ErrPendingMigrations = errors.New("database schema is not up to date")
+	// ErrUnknownVersion means the database was migrated by a newer build
+	ErrUnknownVersion = errors.New("database schema has a version unknown to this build")
+)
+
+// scriptName matches the embedded files: version, name and direction
+var scriptName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is a change of the database schema and the script that reverts it
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status tells whether a migration is applied. Unknown migrations were applied by a
+// newer build and have no script here.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+// appliedMigration is a row of the schema_migrations table
+type appliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// Migrator runs the embedded migrations against a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration // Ordered by version
+}
+
+// New loads the embedded migrations
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load reads and pairs the embedded scripts
+func load() ([]Migration, error) {
+	files, err := fs.ReadDir(scripts, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		match := scriptName.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", file.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := scripts.ReadFile(path.Join("sql", file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down script", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest is the version of the schema expected by this build
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error
+}
+
+// lock serializes the migrators running at the same time, until tx ends
+func lock(tx *gorm.DB) error {
+	return tx.Exec("LOCK TABLE schema_migrations IN SHARE ROW EXCLUSIVE MODE").Error
+}
+
+// applied returns the applied migrations by version. A database without the
+// schema_migrations table has none.
+func (m *Migrator) applied(db *gorm.DB) (map[int]appliedMigration, error) {
+	result := map[int]appliedMigration{}
+	if !db.Migrator().HasTable("schema_migrations") {
+		return result, nil
+	}
+
+	var rows []appliedMigration
+	if err := db.Table("schema_migrations").Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		result[row.Version] = row
+	}
+	return result, nil
+}
+
+// checkKnown refuses databases that have migrations this build does not know
+func (m *Migrator) checkKnown(applied map[int]appliedMigration) error {
+	for version, row := range applied {
+		if _, ok := m.find(version); !ok {
+			return fmt.Errorf("%w: %04d_%s is applied, this build knows up to %04d", ErrUnknownVersion, version, row.Name, m.Latest())
+		}
+	}
+	return nil
+}
+
+// Up applies the pending migrations in order, each in its own transaction, and
+// returns the ones it applied
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.ensureTable(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		ran := false
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			// Another migrator may have applied it while we were waiting for the lock
+			var count int64
+			if err := tx.Table("schema_migrations").Where("version = ?", migration.Version).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := tx.Exec(migration.up).Error; err != nil {
+				return err
+			}
+			ran = true
+			return tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", migration.Version, migration.Name).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %s failed: %w", migration, err)
+		}
+		if ran {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, most recent first, and returns the
+// ones it reverted
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := lock(tx); err != nil {
+				return err
+			}
+			result := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return nil // Already reverted by another migrator
+			}
+			return tx.Exec(migration.down).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %s failed: %w", migration, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Status lists the migrations of this build and whether they are applied, followed
+// by the unknown applied ones
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	var unknown []Status
+	for version, row := range applied {
+		if _, ok := m.find(version); !ok {
+			appliedAt := row.AppliedAt
+			unknown = append(unknown, Status{
+				Migration: Migration{Version: version, Name: row.Name},
+				AppliedAt: &appliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+
+	return append(statuses, unknown...), nil
+}
+
+// Check makes sure the database schema is the one this build expects: every
+// migration is applied and none is unknown
+func (m *Migrator) Check() error {
+	applied, err := m.applied(m.db)
+	if err != nil {
+		return err
+	}
+	if err := m.checkKnown(applied); err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migration(s), latest is %04d", ErrPendingMigrations, pending, m.Latest())
+	}
+	return nil
+}
+
+// CheckSchema is the startup check of the commands that use the database
+func CheckSchema(db *gorm.DB) error {
+	m, err := New(db)
+	if err != nil {
+		return err
+	}
+	return m.Check()
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS quota_rules;
+DROP TABLE IF EXISTS audit_entries;
+DROP TABLE IF EXISTS credit_transactions;
+DROP TABLE IF EXISTS team_compositions;
+DROP TABLE IF EXISTS votes;
+DROP TABLE IF EXISTS polls;
+DROP TABLE IF EXISTS purchases;
+DROP TABLE IF EXISTS resources;
+DROP TABLE IF EXISTS admins;
+DROP TABLE IF EXISTS teams;
@@ -0,0 +1,187 @@
+-- Schema of the application before versioned migrations. Every statement is
+-- idempotent so that databases created by AutoMigrate are adopted as they are: the
+-- tables they already have are kept, and the columns added to them since the first
+-- release are added at the end of this script.
+
+CREATE TABLE IF NOT EXISTS teams (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    email text NOT NULL,
+    password_hash text NOT NULL,
+    credit bigint NOT NULL DEFAULT 1000,
+    is_suspended boolean DEFAULT false,
+    last_activity timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT uni_teams_name UNIQUE (name),
+    CONSTRAINT uni_teams_email UNIQUE (email)
+);
+CREATE INDEX IF NOT EXISTS idx_teams_deleted_at ON teams (deleted_at);
+
+CREATE TABLE IF NOT EXISTS admins (
+    id bigserial PRIMARY KEY,
+    username text NOT NULL,
+    email text NOT NULL,
+    password_hash text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT uni_admins_email UNIQUE (email),
+    CONSTRAINT uni_admins_username UNIQUE (username)
+);
+CREATE INDEX IF NOT EXISTS idx_admins_deleted_at ON admins (deleted_at);
+
+CREATE TABLE IF NOT EXISTS resources (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    description text,
+    cost bigint NOT NULL,
+    quantity bigint NOT NULL,
+    reserved bigint NOT NULL DEFAULT 0,
+    max_per_team bigint NOT NULL,
+    type text NOT NULL,
+    image_url text,
+    is_active boolean DEFAULT true,
+    is_non_returnable boolean DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_resources_deleted_at ON resources (deleted_at);
+
+CREATE TABLE IF NOT EXISTS purchases (
+    id bigserial PRIMARY KEY,
+    batch_id text,
+    team_id bigint NOT NULL,
+    resource_id bigint NOT NULL,
+    quantity bigint NOT NULL,
+    requested_quantity bigint NOT NULL,
+    comment text,
+    purchase_date timestamptz,
+    is_returned boolean DEFAULT false,
+    needs_return boolean DEFAULT false,
+    status text DEFAULT 'en attente',
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT fk_resources_purchases FOREIGN KEY (resource_id) REFERENCES resources (id),
+    CONSTRAINT fk_teams_purchases FOREIGN KEY (team_id) REFERENCES teams (id)
+);
+CREATE INDEX IF NOT EXISTS idx_purchases_deleted_at ON purchases (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_purchases_resource_id ON purchases (resource_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_team_id ON purchases (team_id);
+CREATE INDEX IF NOT EXISTS idx_purchases_batch_id ON purchases (batch_id);
+
+CREATE TABLE IF NOT EXISTS polls (
+    id bigserial PRIMARY KEY,
+    question text NOT NULL,
+    options jsonb,
+    start_date timestamptz,
+    end_date timestamptz,
+    status text DEFAULT 'ouvert',
+    winning_option text,
+    house_cut_percent bigint NOT NULL DEFAULT 0,
+    resolved_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_polls_deleted_at ON polls (deleted_at);
+
+CREATE TABLE IF NOT EXISTS votes (
+    id bigserial PRIMARY KEY,
+    team_id bigint NOT NULL,
+    poll_id bigint NOT NULL,
+    chosen_option text NOT NULL,
+    credit_staked bigint NOT NULL,
+    vote_date timestamptz,
+    payout bigint,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT fk_polls_votes FOREIGN KEY (poll_id) REFERENCES polls (id),
+    CONSTRAINT fk_teams_votes FOREIGN KEY (team_id) REFERENCES teams (id)
+);
+CREATE INDEX IF NOT EXISTS idx_votes_deleted_at ON votes (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_votes_poll_id ON votes (poll_id);
+CREATE INDEX IF NOT EXISTS idx_votes_team_id ON votes (team_id);
+
+CREATE TABLE IF NOT EXISTS team_compositions (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    dev_total bigint NOT NULL DEFAULT 0,
+    infra_total bigint NOT NULL DEFAULT 0,
+    data_total bigint NOT NULL DEFAULT 0,
+    io_t_total bigint NOT NULL DEFAULT 0,
+    sysemb_total bigint NOT NULL DEFAULT 0,
+    dev_filled bigint NOT NULL DEFAULT 0,
+    infra_filled bigint NOT NULL DEFAULT 0,
+    data_filled bigint NOT NULL DEFAULT 0,
+    io_t_filled bigint NOT NULL DEFAULT 0,
+    sysemb_filled bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT uni_team_compositions_name UNIQUE (name)
+);
+CREATE INDEX IF NOT EXISTS idx_team_compositions_deleted_at ON team_compositions (deleted_at);
+
+CREATE TABLE IF NOT EXISTS credit_transactions (
+    id bigserial PRIMARY KEY,
+    team_id bigint NOT NULL,
+    amount bigint NOT NULL,
+    balance_after bigint NOT NULL,
+    reason text NOT NULL,
+    purchase_id bigint,
+    vote_id bigint,
+    admin_id bigint,
+    note text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_admin_id ON credit_transactions (admin_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_vote_id ON credit_transactions (vote_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_purchase_id ON credit_transactions (purchase_id);
+CREATE INDEX IF NOT EXISTS idx_credit_transactions_team_id ON credit_transactions (team_id);
+
+CREATE TABLE IF NOT EXISTS audit_entries (
+    id bigserial PRIMARY KEY,
+    admin_id bigint NOT NULL,
+    action text NOT NULL,
+    entity_type text NOT NULL,
+    entity_id bigint,
+    before jsonb,
+    after jsonb,
+    created_at timestamptz,
+    CONSTRAINT fk_audit_entries_admin FOREIGN KEY (admin_id) REFERENCES admins (id)
+);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_created_at ON audit_entries (created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_entity ON audit_entries (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_action ON audit_entries (action);
+CREATE INDEX IF NOT EXISTS idx_audit_entries_admin_id ON audit_entries (admin_id);
+
+CREATE TABLE IF NOT EXISTS quota_rules (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    scope text NOT NULL,
+    resource_id bigint,
+    resource_type text,
+    max_quantity bigint NOT NULL,
+    window_minutes bigint NOT NULL DEFAULT 0,
+    is_active boolean DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    CONSTRAINT fk_quota_rules_resource FOREIGN KEY (resource_id) REFERENCES resources (id)
+);
+CREATE INDEX IF NOT EXISTS idx_quota_rules_deleted_at ON quota_rules (deleted_at);
+CREATE INDEX IF NOT EXISTS idx_quota_rules_resource_id ON quota_rules (resource_id);
+
+-- Columns added before versioned migrations. CREATE TABLE IF NOT EXISTS skips the
+-- tables of a database created by AutoMigrate from the first release, which lack them.
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS is_suspended boolean DEFAULT false;
+ALTER TABLE resources ADD COLUMN IF NOT EXISTS reserved bigint NOT NULL DEFAULT 0;
+ALTER TABLE polls ADD COLUMN IF NOT EXISTS winning_option text;
+ALTER TABLE polls ADD COLUMN IF NOT EXISTS house_cut_percent bigint NOT NULL DEFAULT 0;
+ALTER TABLE polls ADD COLUMN IF NOT EXISTS resolved_at timestamptz;
+ALTER TABLE votes ADD COLUMN IF NOT EXISTS payout bigint;
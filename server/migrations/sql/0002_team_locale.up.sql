@@ -0,0 +1,2 @@
+-- Language of the emails sent to each team
+ALTER TABLE teams ADD COLUMN locale text NOT NULL DEFAULT 'fr';
@@ -0,0 +1,20 @@
+-- Emails written with the change they notify and sent by the outbox worker
+CREATE TABLE email_outboxes (
+    id bigserial PRIMARY KEY,
+    team_id bigint,
+    recipient text NOT NULL,
+    template text NOT NULL,
+    subject text NOT NULL,
+    html text NOT NULL,
+    text text NOT NULL,
+    status text NOT NULL DEFAULT 'en attente',
+    attempts bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error text,
+    sent_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_email_outboxes_created_at ON email_outboxes (created_at);
+CREATE INDEX idx_email_outbox_due ON email_outboxes (status, next_attempt_at);
+CREATE INDEX idx_email_outboxes_team_id ON email_outboxes (team_id);
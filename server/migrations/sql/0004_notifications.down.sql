@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notification_deliveries;
+DROP TABLE IF EXISTS notification_channels;
+ALTER TABLE resources DROP COLUMN IF EXISTS low_stock_threshold;
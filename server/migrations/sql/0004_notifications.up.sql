@@ -0,0 +1,37 @@
+-- Available units at or below which the staff is notified
+ALTER TABLE resources ADD COLUMN low_stock_threshold bigint NOT NULL DEFAULT 0;
+
+-- Email addresses and webhooks of the staff, with the events routed to them
+CREATE TABLE notification_channels (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    type text NOT NULL,
+    target text NOT NULL,
+    secret text,
+    events jsonb,
+    is_active boolean DEFAULT true,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX idx_notification_channels_deleted_at ON notification_channels (deleted_at);
+
+-- Notifications written with the change they notify and delivered by the outbox worker
+CREATE TABLE notification_deliveries (
+    id bigserial PRIMARY KEY,
+    channel_id bigint NOT NULL,
+    event text NOT NULL,
+    payload jsonb NOT NULL,
+    status text NOT NULL DEFAULT 'en attente',
+    attempts bigint NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL,
+    last_error text,
+    delivered_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_notification_deliveries_channel FOREIGN KEY (channel_id) REFERENCES notification_channels (id)
+);
+CREATE INDEX idx_notification_deliveries_channel_id ON notification_deliveries (channel_id);
+CREATE INDEX idx_notification_deliveries_created_at ON notification_deliveries (created_at);
+CREATE INDEX idx_notification_deliveries_event ON notification_deliveries (event);
+CREATE INDEX idx_notification_delivery_due ON notification_deliveries (status, next_attempt_at);
@@ -0,0 +1,12 @@
+-- Single-use tokens emailed to reset a forgotten password, stored as SHA-256
+CREATE TABLE password_reset_tokens (
+    id bigserial PRIMARY KEY,
+    user_type text NOT NULL,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX idx_password_reset_user ON password_reset_tokens (user_type, user_id);
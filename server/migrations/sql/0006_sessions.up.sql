@@ -0,0 +1,17 @@
+-- Logins of teams and admins, with their rotating refresh token stored as SHA-256
+CREATE TABLE sessions (
+    id bigserial PRIMARY KEY,
+    user_type text NOT NULL,
+    user_id bigint NOT NULL,
+    refresh_token_hash text NOT NULL,
+    previous_token_hash text,
+    user_agent text,
+    ip_address text,
+    expires_at timestamptz NOT NULL,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_sessions_refresh_token_hash ON sessions (refresh_token_hash);
+CREATE INDEX idx_sessions_previous_token_hash ON sessions (previous_token_hash);
+CREATE INDEX idx_session_user ON sessions (user_type, user_id);
@@ -0,0 +1,30 @@
+-- Recent failed logins per account and per client address
+CREATE TABLE login_throttles (
+    id bigserial PRIMARY KEY,
+    scope text NOT NULL,
+    key text NOT NULL,
+    failures bigint NOT NULL DEFAULT 0,
+    last_attempt_at timestamptz,
+    locked_until timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_login_throttles_key ON login_throttles (key);
+
+-- Accounts and addresses locked after too many failed logins
+CREATE TABLE login_lockouts (
+    id bigserial PRIMARY KEY,
+    scope text NOT NULL,
+    key text NOT NULL,
+    user_type text,
+    user_id bigint,
+    ip_address text,
+    identifier text,
+    failures bigint,
+    locked_until timestamptz NOT NULL,
+    unlocked_at timestamptz,
+    unlocked_by bigint,
+    created_at timestamptz
+);
+CREATE INDEX idx_login_lockouts_scope ON login_lockouts (scope);
+CREATE INDEX idx_login_lockouts_key ON login_lockouts (key);
+CREATE INDEX idx_login_lockouts_created_at ON login_lockouts (created_at);
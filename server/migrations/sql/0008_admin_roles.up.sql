@@ -0,0 +1,2 @@
+-- Roles of the admins; the existing ones keep every permission
+ALTER TABLE admins ADD COLUMN role text NOT NULL DEFAULT 'super-admin';
@@ -0,0 +1,19 @@
+ALTER TABLE sessions DROP COLUMN IF EXISTS event_id;
+
+DROP INDEX IF EXISTS idx_team_composition_event_name;
+DROP INDEX IF EXISTS idx_team_event_email;
+DROP INDEX IF EXISTS idx_team_event_name;
+
+-- Fails when several events reuse a team or composition name
+ALTER TABLE team_compositions ADD CONSTRAINT uni_team_compositions_name UNIQUE (name);
+ALTER TABLE teams ADD CONSTRAINT uni_teams_email UNIQUE (email);
+ALTER TABLE teams ADD CONSTRAINT uni_teams_name UNIQUE (name);
+
+ALTER TABLE quota_rules DROP COLUMN IF EXISTS event_id;
+ALTER TABLE team_compositions DROP COLUMN IF EXISTS event_id;
+ALTER TABLE polls DROP COLUMN IF EXISTS event_id;
+ALTER TABLE purchases DROP COLUMN IF EXISTS event_id;
+ALTER TABLE resources DROP COLUMN IF EXISTS event_id;
+ALTER TABLE teams DROP COLUMN IF EXISTS event_id;
+
+DROP TABLE IF EXISTS events;
@@ -0,0 +1,56 @@
+-- Editions of the hackathon, each owning its teams, resources, polls, purchases,
+-- team compositions and quota rules
+CREATE TABLE events (
+    id bigserial PRIMARY KEY,
+    name text NOT NULL,
+    starts_at timestamptz,
+    ends_at timestamptz,
+    starting_credit bigint NOT NULL DEFAULT 1000,
+    logo_url text,
+    primary_color text,
+    is_current boolean NOT NULL DEFAULT false,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT uni_events_name UNIQUE (name)
+);
+
+-- The existing data becomes the current event; rename it and fix its dates from the admin
+INSERT INTO events (name, starts_at, ends_at, starting_credit, is_current, created_at, updated_at)
+VALUES ('YLab Hackathon 2025', '2025-11-20 09:00:00+01', '2025-11-21 18:00:00+01', 1000, true, now(), now());
+
+ALTER TABLE teams ADD COLUMN event_id bigint;
+ALTER TABLE resources ADD COLUMN event_id bigint;
+ALTER TABLE purchases ADD COLUMN event_id bigint;
+ALTER TABLE polls ADD COLUMN event_id bigint;
+ALTER TABLE team_compositions ADD COLUMN event_id bigint;
+ALTER TABLE quota_rules ADD COLUMN event_id bigint;
+
+UPDATE teams SET event_id = (SELECT id FROM events WHERE is_current);
+UPDATE resources SET event_id = (SELECT id FROM events WHERE is_current);
+UPDATE purchases SET event_id = (SELECT id FROM events WHERE is_current);
+UPDATE polls SET event_id = (SELECT id FROM events WHERE is_current);
+UPDATE team_compositions SET event_id = (SELECT id FROM events WHERE is_current);
+UPDATE quota_rules SET event_id = (SELECT id FROM events WHERE is_current);
+
+ALTER TABLE teams ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE resources ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE purchases ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE polls ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE team_compositions ALTER COLUMN event_id SET NOT NULL;
+ALTER TABLE quota_rules ALTER COLUMN event_id SET NOT NULL;
+
+CREATE INDEX idx_resources_event_id ON resources (event_id);
+CREATE INDEX idx_purchases_event_id ON purchases (event_id);
+CREATE INDEX idx_polls_event_id ON polls (event_id);
+CREATE INDEX idx_quota_rules_event_id ON quota_rules (event_id);
+
+-- Names and emails are now unique within an event
+ALTER TABLE teams DROP CONSTRAINT uni_teams_name;
+ALTER TABLE teams DROP CONSTRAINT uni_teams_email;
+ALTER TABLE team_compositions DROP CONSTRAINT uni_team_compositions_name;
+CREATE UNIQUE INDEX idx_team_event_name ON teams (event_id, name);
+CREATE UNIQUE INDEX idx_team_event_email ON teams (event_id, email);
+CREATE UNIQUE INDEX idx_team_composition_event_name ON team_compositions (event_id, name);
+
+-- Event selected by an admin session, the current event when null
+ALTER TABLE sessions ADD COLUMN event_id bigint;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_purchases_return_due_at;
+ALTER TABLE purchases DROP COLUMN IF EXISTS overdue_sent_at;
+ALTER TABLE purchases DROP COLUMN IF EXISTS reminder_sent_at;
+ALTER TABLE purchases DROP COLUMN IF EXISTS return_due_at;
+ALTER TABLE resources DROP COLUMN IF EXISTS rental_minutes;
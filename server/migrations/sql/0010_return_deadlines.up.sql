@@ -0,0 +1,9 @@
+-- Rental duration of the resources, until the end of the event when 0
+ALTER TABLE resources ADD COLUMN rental_minutes bigint NOT NULL DEFAULT 0;
+
+-- Return deadline of the confirmed rentals and the reminders sent about it. Rentals
+-- confirmed before this version have no deadline and are never reminded.
+ALTER TABLE purchases ADD COLUMN return_due_at timestamptz;
+ALTER TABLE purchases ADD COLUMN reminder_sent_at timestamptz;
+ALTER TABLE purchases ADD COLUMN overdue_sent_at timestamptz;
+CREATE INDEX idx_purchases_return_due_at ON purchases (return_due_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_notification_channels_event_id;
+ALTER TABLE notification_channels DROP COLUMN IF EXISTS event_id;
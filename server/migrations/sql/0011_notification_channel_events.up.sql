@@ -0,0 +1,6 @@
+-- Notification channels receive the notifications of a single event. The existing
+-- channels belong to the current event.
+ALTER TABLE notification_channels ADD COLUMN event_id bigint;
+UPDATE notification_channels SET event_id = (SELECT id FROM events WHERE is_current);
+ALTER TABLE notification_channels ALTER COLUMN event_id SET NOT NULL;
+CREATE INDEX idx_notification_channels_event_id ON notification_channels (event_id);
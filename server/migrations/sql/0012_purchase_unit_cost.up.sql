@@ -0,0 +1,12 @@
+-- Cost of a unit when the purchase was made, so that the refunds and exports do not
+-- follow later price changes. Existing purchases take it from their debit in the
+-- ledger, or from the current cost of the resource without one.
+ALTER TABLE purchases ADD COLUMN unit_cost bigint NOT NULL DEFAULT 0;
+
+UPDATE purchases SET unit_cost = COALESCE(
+    (SELECT -SUM(amount) / NULLIF(purchases.requested_quantity, 0)
+     FROM credit_transactions
+     WHERE credit_transactions.purchase_id = purchases.id AND credit_transactions.reason = 'achat'),
+    (SELECT cost FROM resources WHERE resources.id = purchases.resource_id),
+    0
+);
@@ -6,11 +6,69 @@ import (
 	"gorm.io/gorm"
 )
 
+// AdminRole decides which admin routes an admin can use
+type AdminRole string
+
+const (
+	RoleSuperAdmin AdminRole = "super-admin" // Everything, including the roles of the other admins
+	RoleCashier    AdminRole = "cashier"     // Approves and cancels purchases
+	RoleLogistics  AdminRole = "logistics"   // Collects rented equipment and fills team composition slots
+	RoleViewer     AdminRole = "viewer"      // Read-only
+)
+
+// Permission is what an admin route requires
+type Permission string
+
+const (
+	PermissionView          Permission = "view"                 // Listings, exports, audit log and email previews
+	PermissionPurchases     Permission = "purchases.approve"    // Confirm, partially confirm or cancel purchases
+	PermissionReturns       Permission = "purchases.return"     // Mark rented equipment as returned or not
+	PermissionCompositions  Permission = "compositions.toggle"  // Fill and empty team composition slots
+	PermissionTeams         Permission = "teams.manage"         // Create, edit, credit and suspend teams
+	PermissionCatalog       Permission = "catalog.manage"       // Resources and quotas
+	PermissionPolls         Permission = "polls.manage"         // Create, close and resolve polls
+	PermissionNotifications Permission = "notifications.manage" // Resend emails, manage notification channels
+	PermissionSecurity      Permission = "security.manage"      // Sessions, login lockouts and admin roles
+	PermissionEvents        Permission = "events.manage"        // Create and edit events, choose the current one
+)
+
+// rolePermissions lists the permissions granted by each role
+var rolePermissions = map[AdminRole][]Permission{
+	RoleSuperAdmin: {
+		PermissionView, PermissionPurchases, PermissionReturns, PermissionCompositions, PermissionTeams,
+		PermissionCatalog, PermissionPolls, PermissionNotifications, PermissionSecurity, PermissionEvents,
+	},
+	RoleCashier:   {PermissionView, PermissionPurchases},
+	RoleLogistics: {PermissionView, PermissionReturns, PermissionCompositions},
+	RoleViewer:    {PermissionView},
+}
+
+func IsValidAdminRole(role string) bool {
+	_, ok := rolePermissions[AdminRole(role)]
+	return ok
+}
+
+// Permissions returns the permissions granted by the role, none for an unknown role
+func (r AdminRole) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// Can reports whether the role grants a permission
+func (r AdminRole) Can(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 type Admin struct {
 	ID           uint           `gorm:"primaryKey" json:"id"`
 	Username     string         `gorm:"unique;not null" json:"username"`
 	Email        string         `gorm:"unique;not null" json:"email"`
 	PasswordHash string         `gorm:"not null" json:"-"`
+	Role         AdminRole      `gorm:"not null;default:super-admin" json:"role"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
@@ -20,3 +78,7 @@ type AdminLoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+type UpdateAdminRoleRequest struct {
+	Role AdminRole `json:"role" binding:"required"`
+}
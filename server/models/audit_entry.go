@@ -0,0 +1,121 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Audited admin actions
+const (
+	AuditPurchaseConfirm        = "purchase.confirm"
+	AuditPurchaseCancel         = "purchase.cancel"
+	AuditPurchaseMarkReturned   = "purchase.mark_returned"
+	AuditPurchaseUnmarkReturned = "purchase.unmark_returned"
+	AuditCompositionToggleSlot  = "team_composition.toggle_slot"
+	AuditResourceCreate         = "resource.create"
+	AuditResourceUpdate         = "resource.update"
+	AuditResourceActivate       = "resource.activate"
+	AuditResourceDeactivate     = "resource.deactivate"
+	AuditResourceDelete         = "resource.delete"
+	AuditResourceRestore        = "resource.restore"
+	AuditResourceImport         = "resource.import"
+	AuditPollCreate             = "poll.create"
+	AuditPollUpdate             = "poll.update"
+	AuditPollClose              = "poll.close"
+	AuditPollCancel             = "poll.cancel"
+	AuditPollResolve            = "poll.resolve"
+	AuditTeamCreate             = "team.create"
+	AuditTeamUpdate             = "team.update"
+	AuditTeamResetPassword      = "team.reset_password"
+	AuditTeamAdjustCredit       = "team.adjust_credit"
+	AuditTeamSuspend            = "team.suspend"
+	AuditTeamUnsuspend          = "team.unsuspend"
+	AuditTeamImport             = "team.import"
+	AuditQuotaCreate            = "quota.create"
+	AuditQuotaUpdate            = "quota.update"
+	AuditQuotaDelete            = "quota.delete"
+	AuditEmailResend            = "email.resend"
+	AuditNotificationCreate     = "notification_channel.create"
+	AuditNotificationUpdate     = "notification_channel.update"
+	AuditNotificationDelete     = "notification_channel.delete"
+	AuditDeliveryResend         = "notification_delivery.resend"
+	AuditSessionRevoke          = "session.revoke"
+	AuditLockoutUnlock          = "login_lockout.unlock"
+	AuditAdminUpdateRole        = "admin.update_role"
+	AuditEventCreate            = "event.create"
+	AuditEventUpdate            = "event.update"
+	AuditEventActivate          = "event.activate"
+)
+
+// Audited entity types
+const (
+	AuditEntityPurchase        = "purchase"
+	AuditEntityTeamComposition = "team_composition"
+	AuditEntityResource        = "resource"
+	AuditEntityPoll            = "poll"
+	AuditEntityTeam            = "team"
+	AuditEntityQuotaRule       = "quota_rule"
+	AuditEntityEmail           = "email"
+	AuditEntityNotification    = "notification_channel"
+	AuditEntityDelivery        = "notification_delivery"
+	AuditEntitySession         = "session"
+	AuditEntityLockout         = "login_lockout"
+	AuditEntityAdmin           = "admin"
+	AuditEntityEvent           = "event"
+)
+
+// JSONData is a raw JSON document stored in a jsonb column
+type JSONData []byte
+
+// Scan implements the sql.Scanner interface
+func (j *JSONData) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append(JSONData(nil), v...)
+	case string:
+		*j = JSONData(v)
+	default:
+		return errors.New("failed to scan JSONData")
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (j JSONData) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+func (j JSONData) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+func (j *JSONData) UnmarshalJSON(data []byte) error {
+	if !json.Valid(data) {
+		return errors.New("invalid JSON")
+	}
+	*j = append(JSONData(nil), data...)
+	return nil
+}
+
+// AuditEntry records an admin action with the state of the entity before and after it
+type AuditEntry struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	AdminID    uint      `gorm:"not null;index" json:"admin_id"`
+	Action     string    `gorm:"not null;index" json:"action"`
+	EntityType string    `gorm:"not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID   uint      `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Before     JSONData  `gorm:"type:jsonb" json:"before"`
+	After      JSONData  `gorm:"type:jsonb" json:"after"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+	Admin      Admin     `gorm:"foreignKey:AdminID" json:"admin,omitempty"`
+}
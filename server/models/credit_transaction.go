@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CreditReason string
+
+const (
+	CreditReasonOpening        CreditReason = "solde initial"
+	CreditReasonPurchase       CreditReason = "achat"
+	CreditReasonPurchaseRefund CreditReason = "remboursement achat"
+	CreditReasonVoteStake      CreditReason = "mise"
+	CreditReasonVoteRefund     CreditReason = "remboursement mise"
+	CreditReasonVotePayout     CreditReason = "gain"
+	CreditReasonAdjustment     CreditReason = "ajustement"
+)
+
+var ErrCreditTransactionImmutable = errors.New("credit transactions are append-only")
+
+// CreditTransaction is an append-only ledger entry. The sum of the amounts of a team
+// is expected to match Team.Credit.
+type CreditTransaction struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	TeamID       uint         `gorm:"not null;index" json:"team_id"`
+	Amount       int          `gorm:"not null" json:"amount"` // Positive for credits, negative for debits
+	BalanceAfter int          `gorm:"not null" json:"balance_after"`
+	Reason       CreditReason `gorm:"not null" json:"reason"`
+	PurchaseID   *uint        `gorm:"index" json:"purchase_id,omitempty"`
+	VoteID       *uint        `gorm:"index" json:"vote_id,omitempty"`
+	AdminID      *uint        `gorm:"index" json:"admin_id,omitempty"` // Admin who triggered the change, if any
+	Note         string       `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+func (t *CreditTransaction) BeforeUpdate(tx *gorm.DB) error {
+	return ErrCreditTransactionImmutable
+}
+
+func (t *CreditTransaction) BeforeDelete(tx *gorm.DB) error {
+	return ErrCreditTransactionImmutable
+}
+
+type LedgerResponse struct {
+	TeamID        uint                `json:"team_id"`
+	Credit        int                 `json:"credit"`         // Current Team.Credit
+	LedgerBalance int                 `json:"ledger_balance"` // Sum of all ledger entries
+	Consistent    bool                `json:"consistent"`
+	Transactions  []CreditTransaction `json:"transactions"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type EmailStatus string
+
+const (
+	EmailStatusPending EmailStatus = "en attente" // Waiting for its first or next attempt
+	EmailStatusSent    EmailStatus = "envoyé"
+	EmailStatusFailed  EmailStatus = "échoué" // Gave up after too many attempts, until an admin resends it
+)
+
+// EmailOutbox is an email waiting to be sent. It is written in the same transaction
+// as the change it notifies, then sent by the outbox worker, so that no email is lost
+// when the SMTP server is unreachable.
+type EmailOutbox struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	TeamID        *uint       `gorm:"index" json:"team_id,omitempty"`
+	Recipient     string      `gorm:"not null" json:"recipient"`
+	Template      string      `gorm:"not null" json:"template"`
+	Subject       string      `gorm:"not null" json:"subject"`
+	HTML          string      `gorm:"not null" json:"html,omitempty"`
+	Text          string      `gorm:"not null" json:"text,omitempty"`
+	Status        EmailStatus `gorm:"default:'en attente';not null;index:idx_email_outbox_due" json:"status"`
+	Attempts      int         `gorm:"default:0;not null" json:"attempts"`
+	NextAttemptAt time.Time   `gorm:"not null;index:idx_email_outbox_due" json:"next_attempt_at"`
+	LastError     string      `json:"last_error,omitempty"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"`
+	CreatedAt     time.Time   `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
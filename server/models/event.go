@@ -0,0 +1,59 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Event is an edition of the hackathon. It owns its teams, resources, polls,
+// purchases, team compositions and quota rules, so that past editions stay in the
+// database next to the current one.
+type Event struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Name           string    `gorm:"unique;not null" json:"name"` // Shown in the interface and the emails
+	StartsAt       time.Time `json:"starts_at"`
+	EndsAt         time.Time `json:"ends_at"`
+	StartingCredit int       `gorm:"default:1000;not null" json:"starting_credit"` // Credit of the new teams when none is given
+	LogoURL        string    `json:"logo_url"`
+	PrimaryColor   string    `json:"primary_color"`                            // e.g. "#0057b8"
+	IsCurrent      bool      `gorm:"default:false;not null" json:"is_current"` // The event of the public pages and team logins
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Validate checks the business rules shared by event creation, update and seeding.
+// It returns an error message, or an empty string when the event is valid.
+func (e *Event) Validate() string {
+	if strings.TrimSpace(e.Name) == "" {
+		return "Name is required"
+	}
+	if e.StartsAt.IsZero() || e.EndsAt.IsZero() {
+		return "Start and end dates are required"
+	}
+	if !e.EndsAt.After(e.StartsAt) {
+		return "End date must be after start date"
+	}
+	if e.StartingCredit < 0 {
+		return "Starting credit must be positive"
+	}
+	return ""
+}
+
+type CreateEventRequest struct {
+	Name           string    `json:"name" binding:"required"`
+	StartsAt       time.Time `json:"starts_at" binding:"required"`
+	EndsAt         time.Time `json:"ends_at" binding:"required"`
+	StartingCredit *int      `json:"starting_credit,omitempty" binding:"omitempty,min=0"` // Defaults to DefaultTeamCredit
+	LogoURL        string    `json:"logo_url"`
+	PrimaryColor   string    `json:"primary_color"`
+}
+
+// UpdateEventRequest only updates the fields that are provided
+type UpdateEventRequest struct {
+	Name           *string    `json:"name,omitempty"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	StartingCredit *int       `json:"starting_credit,omitempty" binding:"omitempty,min=0"`
+	LogoURL        *string    `json:"logo_url,omitempty"`
+	PrimaryColor   *string    `json:"primary_color,omitempty"`
+}
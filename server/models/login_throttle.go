@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Scopes of the login throttling
+const (
+	LoginScopeAccount = "account" // Failed logins to a team or an admin, from anywhere
+	LoginScopeIP      = "ip"      // Failed logins from an address, to any account
+)
+
+// LoginThrottle counts the recent failed logins to an account or from an address.
+// Key is "team:<id>" or "admin:<id>" for accounts and the address for IPs.
+type LoginThrottle struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Scope         string     `gorm:"not null" json:"scope"`
+	Key           string     `gorm:"not null;uniqueIndex" json:"key"`
+	Failures      int        `gorm:"not null;default:0" json:"failures"`
+	LastAttemptAt time.Time  `json:"last_attempt_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// LoginLockout records an account or an address locked after too many failed logins
+type LoginLockout struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Scope       string     `gorm:"not null;index" json:"scope"`
+	Key         string     `gorm:"not null;index" json:"key"`
+	UserType    string     `json:"user_type,omitempty"` // Locked account, empty for addresses
+	UserID      *uint      `json:"user_id,omitempty"`   // Locked account, empty for addresses
+	IPAddress   string     `json:"ip_address"`          // Address of the failure that locked it
+	Identifier  string     `json:"identifier"`          // Name, username or email of that failure
+	Failures    int        `json:"failures"`            // Failures that led to the lockout
+	LockedUntil time.Time  `gorm:"not null" json:"locked_until"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"` // Set when an admin lifts it early
+	UnlockedBy  *uint      `json:"unlocked_by,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
+
+// IsActive reports whether the lockout still refuses logins
+func (l LoginLockout) IsActive() bool {
+	return l.UnlockedAt == nil && l.LockedUntil.After(time.Now())
+}
@@ -0,0 +1,25 @@
+package models
+
+// All lists every model managed by the database migrations
+func All() []interface{} {
+	return []interface{}{
+		&Event{},
+		&Team{},
+		&Admin{},
+		&Resource{},
+		&Purchase{},
+		&Poll{},
+		&Vote{},
+		&TeamComposition{},
+		&CreditTransaction{},
+		&AuditEntry{},
+		&QuotaRule{},
+		&EmailOutbox{},
+		&NotificationChannel{},
+		&NotificationDelivery{},
+		&PasswordResetToken{},
+		&Session{},
+		&LoginThrottle{},
+		&LoginLockout{},
+	}
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationChannel is where the staff of a hackathon event receives the
+// notifications it is routed to: an email address or a webhook URL (Discord, Slack
+// or any endpoint checking the signature).
+type NotificationChannel struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	EventID   uint           `gorm:"not null;index" json:"event_id"` // Hackathon event whose notifications the channel receives
+	Name      string         `gorm:"not null" json:"name"`
+	Type      string         `gorm:"not null" json:"type"`   // "email" or "webhook"
+	Target    string         `gorm:"not null" json:"target"` // Email address or webhook URL
+	Secret    string         `json:"-"`                      // Signs the webhook requests, only shown when the channel is created
+	Events    StringArray    `gorm:"type:jsonb" json:"events"`
+	IsActive  bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Routes tells whether the channel receives the notifications of an event
+func (c *NotificationChannel) Routes(event string) bool {
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "en attente" // Waiting for its first or next attempt
+	DeliveryStatusDelivered DeliveryStatus = "livré"
+	DeliveryStatusFailed    DeliveryStatus = "échoué" // Gave up after too many attempts, or the channel was removed
+)
+
+// NotificationDelivery is a notification waiting to be delivered to a channel. Like
+// the emails of the outbox, it is written with the change it notifies and delivered
+// by the outbox worker.
+type NotificationDelivery struct {
+	ID            uint                 `gorm:"primaryKey" json:"id"`
+	ChannelID     uint                 `gorm:"not null;index" json:"channel_id"`
+	Event         string               `gorm:"not null;index" json:"event"`
+	Payload       JSONData             `gorm:"type:jsonb;not null" json:"payload"` // The notify.Notification
+	Status        DeliveryStatus       `gorm:"default:'en attente';not null;index:idx_notification_delivery_due" json:"status"`
+	Attempts      int                  `gorm:"default:0;not null" json:"attempts"`
+	NextAttemptAt time.Time            `gorm:"not null;index:idx_notification_delivery_due" json:"next_attempt_at"`
+	LastError     string               `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time           `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time            `gorm:"index" json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	Channel       *NotificationChannel `gorm:"foreignKey:ChannelID" json:"channel,omitempty"`
+}
+
+type CreateNotificationChannelRequest struct {
+	Name     string   `json:"name" binding:"required"`
+	Type     string   `json:"type" binding:"required,oneof=email webhook"`
+	Target   string   `json:"target" binding:"required"`
+	Secret   string   `json:"secret,omitempty"` // Webhooks only, generated when empty
+	Events   []string `json:"events" binding:"required,min=1"`
+	IsActive *bool    `json:"is_active,omitempty"` // Defaults to true
+}
+
+// UpdateNotificationChannelRequest only updates the fields that are provided. The
+// type and the secret of a channel cannot be changed.
+type UpdateNotificationChannelRequest struct {
+	Name     *string  `json:"name,omitempty"`
+	Target   *string  `json:"target,omitempty"`
+	Events   []string `json:"events,omitempty" binding:"omitempty,min=1"`
+	IsActive *bool    `json:"is_active,omitempty"`
+}
+
+// CreateNotificationChannelResponse returns the secret of a new webhook channel,
+// which is never shown again
+type CreateNotificationChannelResponse struct {
+	Channel NotificationChannel `json:"channel"`
+	Secret  string              `json:"secret,omitempty"`
+}
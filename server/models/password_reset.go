@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// PasswordResetToken lets a team or an admin choose a new password without knowing
+// the current one. The token is emailed to the account; only its SHA-256 is stored.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserType  string     `gorm:"not null;index:idx_password_reset_user" json:"user_type"` // "team" or "admin"
+	UserID    uint       `gorm:"not null;index:idx_password_reset_user" json:"user_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // Set once used, or when the password is changed another way
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ForgotPasswordRequest asks for a reset link. The identifier is the name or the
+// email of a team, or the username or the email of an admin.
+type ForgotPasswordRequest struct {
+	UserType   string `json:"user_type" binding:"required,oneof=team admin"`
+	Identifier string `json:"identifier" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
@@ -12,8 +12,10 @@ import (
 type PollStatus string
 
 const (
-	PollStatusOpen   PollStatus = "ouvert"
-	PollStatusClosed PollStatus = "fermé"
+	PollStatusOpen      PollStatus = "ouvert"
+	PollStatusClosed    PollStatus = "fermé"
+	PollStatusCancelled PollStatus = "annulé"
+	PollStatusResolved  PollStatus = "résolu"
 )
 
 // StringArray is a custom type for string arrays in PostgreSQL
@@ -26,12 +28,14 @@ func (s *StringArray) Scan(value interface{}) error {
 		return nil
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string: // Drivers that return JSON columns as text
+		return json.Unmarshal([]byte(v), s)
+	default:
 		return errors.New("failed to scan StringArray")
 	}
-
-	return json.Unmarshal(bytes, s)
 }
 
 // Value implements the driver.Valuer interface
@@ -43,14 +47,49 @@ func (s StringArray) Value() (driver.Value, error) {
 }
 
 type Poll struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Question  string         `gorm:"not null" json:"question"`
-	Options   StringArray    `gorm:"type:jsonb" json:"options"`
-	StartDate time.Time      `json:"start_date"`
-	EndDate   time.Time      `json:"end_date"`
-	Status    PollStatus     `gorm:"default:'ouvert'" json:"status"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Votes     []Vote         `gorm:"foreignKey:PollID" json:"votes,omitempty"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	EventID         uint           `gorm:"not null;index" json:"event_id"`
+	Question        string         `gorm:"not null" json:"question"`
+	Options         StringArray    `gorm:"type:jsonb" json:"options"`
+	StartDate       time.Time      `json:"start_date"`
+	EndDate         time.Time      `json:"end_date"`
+	Status          PollStatus     `gorm:"default:'ouvert'" json:"status"`
+	WinningOption   *string        `json:"winning_option,omitempty"`                    // Set when the poll is resolved
+	HouseCutPercent int            `gorm:"default:0;not null" json:"house_cut_percent"` // Share of the pool kept by the organizers
+	ResolvedAt      *time.Time     `json:"resolved_at,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Votes           []Vote         `gorm:"foreignKey:PollID" json:"votes,omitempty"`
+}
+
+// HasOption tells whether option is one of the poll options
+func (p *Poll) HasOption(option string) bool {
+	for _, o := range p.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+type CreatePollRequest struct {
+	Question  string    `json:"question" binding:"required"`
+	Options   []string  `json:"options" binding:"required,min=2"`
+	StartDate time.Time `json:"start_date"` // Defaults to now
+	EndDate   time.Time `json:"end_date" binding:"required"`
+}
+
+// UpdatePollRequest only updates the fields that are provided.
+// Options can no longer be changed once the poll has received a vote.
+type UpdatePollRequest struct {
+	Question  *string    `json:"question,omitempty"`
+	Options   []string   `json:"options,omitempty"`
+	StartDate *time.Time `json:"start_date,omitempty"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+}
+
+type ResolvePollRequest struct {
+	WinningOption   string `json:"winning_option" binding:"required"`
+	HouseCutPercent int    `json:"house_cut_percent" binding:"min=0,max=100"`
 }
@@ -16,15 +16,20 @@ const (
 
 type Purchase struct {
 	ID                uint           `gorm:"primaryKey" json:"id"`
+	EventID           uint           `gorm:"not null;index" json:"event_id"`  // Event of the team
 	BatchID           *string        `gorm:"index" json:"batch_id,omitempty"` // Groups multiple items purchased together
 	TeamID            uint           `gorm:"not null;index" json:"team_id"`
 	ResourceID        uint           `gorm:"not null;index" json:"resource_id"`
 	Quantity          int            `gorm:"not null" json:"quantity"`
 	RequestedQuantity int            `gorm:"not null" json:"requested_quantity"` // Original quantity requested
+	UnitCost          int            `gorm:"not null;default:0" json:"unit_cost"` // Cost of a unit when the team bought it, used for the refunds
 	Comment           string         `gorm:"type:text" json:"comment"`           // Required comment explaining why the purchase is needed
 	PurchaseDate      time.Time      `json:"purchase_date"`
 	IsReturned        bool           `gorm:"default:false" json:"is_returned"`     // Marks if item was physically returned (no refund)
 	NeedsReturn       bool           `gorm:"default:false" json:"needs_return"`    // Marks if item needs to be returned
+	ReturnDueAt       *time.Time     `gorm:"index" json:"return_due_at,omitempty"` // Set when a rental is confirmed, from the rental duration of the resource or the end of the event
+	ReminderSentAt    *time.Time     `json:"reminder_sent_at,omitempty"`           // The team was reminded of the deadline
+	OverdueSentAt     *time.Time     `json:"overdue_sent_at,omitempty"`            // The team and the staff were told the return is late
 	Status            PurchaseStatus `gorm:"default:'en attente'" json:"status"`
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
@@ -33,6 +38,11 @@ type Purchase struct {
 	Resource          Resource       `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
 }
 
+// TotalCost is the credit paid for the current quantity
+func (p Purchase) TotalCost() int {
+	return p.UnitCost * p.Quantity
+}
+
 type PurchaseRequest struct {
 	ResourceID uint `json:"resource_id" binding:"required"`
 	Quantity   int  `json:"quantity" binding:"required,min=1"`
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type QuotaScope string
+
+const (
+	QuotaScopeResource QuotaScope = "ressource" // Limits one resource
+	QuotaScopeType     QuotaScope = "type"      // Limits every resource of a type
+)
+
+// QuotaRule limits the quantity a team can order, summed over its pending and
+// confirmed purchases. With a time window, only purchases made during the last
+// WindowMinutes count (e.g. 2 mentoring sessions per 1440 minutes).
+type QuotaRule struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	EventID       uint           `gorm:"not null;index" json:"event_id"`
+	Name          string         `gorm:"not null" json:"name"`
+	Scope         QuotaScope     `gorm:"not null" json:"scope"`
+	ResourceID    *uint          `gorm:"index" json:"resource_id,omitempty"` // Set for the "ressource" scope
+	ResourceType  string         `json:"resource_type,omitempty"`            // Set for the "type" scope
+	MaxQuantity   int            `gorm:"not null" json:"max_quantity"`
+	WindowMinutes int            `gorm:"default:0;not null" json:"window_minutes"` // 0 means the whole event
+	IsActive      bool           `gorm:"default:true" json:"is_active"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	Resource      *Resource      `gorm:"foreignKey:ResourceID" json:"resource,omitempty"`
+}
+
+type CreateQuotaRuleRequest struct {
+	Name          string     `json:"name" binding:"required"`
+	Scope         QuotaScope `json:"scope" binding:"required,oneof=ressource type"`
+	ResourceID    *uint      `json:"resource_id,omitempty"`
+	ResourceType  string     `json:"resource_type,omitempty"`
+	MaxQuantity   int        `json:"max_quantity" binding:"min=0"`
+	WindowMinutes int        `json:"window_minutes" binding:"min=0"`
+	IsActive      *bool      `json:"is_active,omitempty"` // Defaults to true
+}
+
+// UpdateQuotaRuleRequest only updates the fields that are provided
+type UpdateQuotaRuleRequest struct {
+	Name          *string     `json:"name,omitempty"`
+	Scope         *QuotaScope `json:"scope,omitempty" binding:"omitempty,oneof=ressource type"`
+	ResourceID    *uint       `json:"resource_id,omitempty"`
+	ResourceType  *string     `json:"resource_type,omitempty"`
+	MaxQuantity   *int        `json:"max_quantity,omitempty" binding:"omitempty,min=0"`
+	WindowMinutes *int        `json:"window_minutes,omitempty" binding:"omitempty,min=0"`
+	IsActive      *bool       `json:"is_active,omitempty"`
+}
+
+// QuotaViolation explains why a purchase request exceeds a limit
+type QuotaViolation struct {
+	RuleID        *uint      `json:"rule_id,omitempty"` // Empty for the resource max per team
+	Rule          string     `json:"rule"`
+	Scope         QuotaScope `json:"scope"`
+	ResourceID    *uint      `json:"resource_id,omitempty"`
+	ResourceType  string     `json:"resource_type,omitempty"`
+	Limit         int        `json:"limit"`
+	Used          int        `json:"used"` // Quantity already pending or confirmed in the window
+	Requested     int        `json:"requested"`
+	WindowMinutes int        `json:"window_minutes"`
+	Message       string     `json:"message"`
+}
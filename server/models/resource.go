@@ -1,24 +1,115 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type Resource struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	Name            string         `gorm:"not null" json:"name"`
-	Description     string         `json:"description"`
-	Cost            int            `gorm:"not null" json:"cost"`
-	Quantity        int            `gorm:"not null" json:"quantity"`
-	MaxPerTeam      int            `gorm:"not null" json:"max_per_team"`
-	Type            string         `gorm:"not null" json:"type"` // "service", "matériel", "avantage"
-	ImageURL        string         `json:"image_url"`
-	IsActive        bool           `gorm:"default:true" json:"is_active"`
-	IsNonReturnable bool           `gorm:"default:false" json:"is_non_returnable"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
-	Purchases       []Purchase     `gorm:"foreignKey:ResourceID" json:"purchases,omitempty"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	EventID           uint           `gorm:"not null;index" json:"event_id"`
+	Name              string         `gorm:"not null" json:"name"`
+	Description       string         `json:"description"`
+	Cost              int            `gorm:"not null" json:"cost"`
+	Quantity          int            `gorm:"not null" json:"quantity"`                    // Units in stock, including reserved ones
+	Reserved          int            `gorm:"default:0;not null" json:"reserved_quantity"` // Units held by pending purchases
+	MaxPerTeam        int            `gorm:"not null" json:"max_per_team"`
+	Type              string         `gorm:"not null" json:"type"` // "service", "matériel", "avantage"
+	ImageURL          string         `json:"image_url"`
+	IsActive          bool           `gorm:"default:true" json:"is_active"`
+	IsNonReturnable   bool           `gorm:"default:false" json:"is_non_returnable"`
+	LowStockThreshold int            `gorm:"default:0;not null" json:"low_stock_threshold"` // The staff is notified when the available units fall to it
+	RentalMinutes     int            `gorm:"default:0;not null" json:"rental_minutes"`      // Time a team may keep a rented unit, until the end of the event when 0
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+	Purchases         []Purchase     `gorm:"foreignKey:ResourceID" json:"purchases,omitempty"`
+}
+
+// AvailableQuantity is the number of units that can still be ordered
+func (r *Resource) AvailableQuantity() int {
+	return r.Quantity - r.Reserved
+}
+
+// MarshalJSON adds the available quantity to the resource
+func (r Resource) MarshalJSON() ([]byte, error) {
+	type resourceJSON Resource
+	return json.Marshal(struct {
+		resourceJSON
+		AvailableQuantity int `json:"available_quantity"`
+	}{resourceJSON(r), r.AvailableQuantity()})
+}
+
+const (
+	ResourceTypeService   = "service"
+	ResourceTypeMaterial  = "matériel"
+	ResourceTypeAdvantage = "avantage"
+)
+
+// IsValidResourceType reports whether t is one of the resource types known by the shop
+func IsValidResourceType(t string) bool {
+	switch t {
+	case ResourceTypeService, ResourceTypeMaterial, ResourceTypeAdvantage:
+		return true
+	}
+	return false
+}
+
+// Validate checks the business rules shared by resource creation, update and import.
+// It returns an error message, or an empty string when the resource is valid.
+func (r *Resource) Validate() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return "Name is required"
+	}
+	if !IsValidResourceType(r.Type) {
+		return "Invalid resource type (expected service, matériel or avantage)"
+	}
+	if r.Cost < 0 {
+		return "Cost must be positive"
+	}
+	if r.Quantity < 0 {
+		return "Quantity must be positive"
+	}
+	if r.LowStockThreshold < 0 {
+		return "Low stock threshold must be positive"
+	}
+	if r.RentalMinutes < 0 {
+		return "Rental duration must be positive"
+	}
+	if r.MaxPerTeam < 1 {
+		return "Max per team must be at least 1"
+	}
+	return ""
+}
+
+type CreateResourceRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Description       string `json:"description"`
+	Cost              int    `json:"cost" binding:"min=0"`
+	Quantity          int    `json:"quantity" binding:"min=0"`
+	MaxPerTeam        int    `json:"max_per_team" binding:"required,min=1"`
+	Type              string `json:"type" binding:"required"`
+	ImageURL          string `json:"image_url"`
+	IsActive          *bool  `json:"is_active,omitempty"` // Defaults to true
+	IsNonReturnable   bool   `json:"is_non_returnable"`
+	LowStockThreshold int    `json:"low_stock_threshold" binding:"min=0"`
+	RentalMinutes     int    `json:"rental_minutes" binding:"min=0"` // 0 to return by the end of the event
+}
+
+// UpdateResourceRequest only updates the fields that are provided
+type UpdateResourceRequest struct {
+	Name              *string `json:"name,omitempty"`
+	Description       *string `json:"description,omitempty"`
+	Cost              *int    `json:"cost,omitempty"`
+	Quantity          *int    `json:"quantity,omitempty"`
+	MaxPerTeam        *int    `json:"max_per_team,omitempty"`
+	Type              *string `json:"type,omitempty"`
+	ImageURL          *string `json:"image_url,omitempty"`
+	IsActive          *bool   `json:"is_active,omitempty"`
+	IsNonReturnable   *bool   `json:"is_non_returnable,omitempty"`
+	LowStockThreshold *int    `json:"low_stock_threshold,omitempty" binding:"omitempty,min=0"`
+	RentalMinutes     *int    `json:"rental_minutes,omitempty" binding:"omitempty,min=0"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Session is a login of a team or an admin. Its refresh token is replaced each time
+// it is used; only the SHA-256 of the current and previous ones are stored. Access
+// tokens carry the ID of their session and are refused once it is revoked.
+type Session struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	UserType          string     `gorm:"not null;index:idx_session_user" json:"user_type"` // "team" or "admin"
+	UserID            uint       `gorm:"not null;index:idx_session_user" json:"user_id"`
+	EventID           *uint      `json:"event_id,omitempty"` // Event selected by an admin, the current one when nil
+	RefreshTokenHash  string     `gorm:"not null;uniqueIndex" json:"-"`
+	PreviousTokenHash string     `gorm:"index" json:"-"` // Presenting it again revokes the session
+	UserAgent         string     `json:"user_agent"`
+	IPAddress         string     `json:"ip_address"`
+	ExpiresAt         time.Time  `gorm:"not null" json:"expires_at"` // Pushed back each time the session is refreshed
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// IsActive reports whether the session can still be refreshed
+func (s Session) IsActive() bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(time.Now())
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenResponse is returned when a session is refreshed
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // Always "Bearer"
+	ExpiresIn    int    `json:"expires_in"` // Lifetime of the access token, in seconds
+}
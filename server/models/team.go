@@ -8,10 +8,13 @@ import (
 
 type Team struct {
 	ID           uint           `gorm:"primaryKey" json:"id"`
-	Name         string         `gorm:"unique;not null" json:"name"`
-	Email        string         `gorm:"unique;not null" json:"email"`
+	EventID      uint           `gorm:"not null;uniqueIndex:idx_team_event_name;uniqueIndex:idx_team_event_email" json:"event_id"`
+	Name         string         `gorm:"not null;uniqueIndex:idx_team_event_name" json:"name"`   // Unique within the event
+	Email        string         `gorm:"not null;uniqueIndex:idx_team_event_email" json:"email"` // Unique within the event
 	PasswordHash string         `gorm:"not null" json:"-"`
 	Credit       int            `gorm:"default:1000;not null" json:"credit"`
+	IsSuspended  bool           `gorm:"default:false" json:"is_suspended"` // Suspended teams cannot log in nor use their token
+	Locale       string         `gorm:"default:fr;not null" json:"locale"` // Language of the emails sent to the team
 	LastActivity time.Time      `json:"last_activity"`
 	CreatedAt    time.Time      `json:"created_at"`
 	UpdatedAt    time.Time      `json:"updated_at"`
@@ -20,6 +23,15 @@ type Team struct {
 	Votes        []Vote         `gorm:"foreignKey:TeamID" json:"votes,omitempty"`
 }
 
+// DefaultTeamCredit is the starting credit of a team when none is given
+const DefaultTeamCredit = 1000
+
+// Locales of the emails sent to the teams
+const (
+	LocaleFrench  = "fr"
+	LocaleEnglish = "en"
+)
+
 type TeamLoginRequest struct {
 	Name     string `json:"name" binding:"required"`
 	Password string `json:"password" binding:"required"`
@@ -30,11 +42,41 @@ type TeamResponse struct {
 	Name         string    `json:"name"`
 	Email        string    `json:"email"`
 	Credit       int       `json:"credit"`
+	IsSuspended  bool      `json:"is_suspended"`
+	Locale       string    `json:"locale"`
 	LastActivity time.Time `json:"last_activity"`
 }
 
 type UpdateTeamProfileRequest struct {
-	Email string `json:"email" binding:"required,email"`
+	Email  string  `json:"email" binding:"required,email"`
+	Locale *string `json:"locale,omitempty" binding:"omitempty,oneof=fr en"` // Unchanged when omitted
+}
+
+type CreateTeamRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Email  string `json:"email" binding:"required,email"`
+	Credit *int   `json:"credit,omitempty" binding:"omitempty,min=0"`       // Defaults to DefaultTeamCredit
+	Locale string `json:"locale,omitempty" binding:"omitempty,oneof=fr en"` // Defaults to LocaleFrench
+}
+
+// UpdateTeamRequest only updates the fields that are provided
+type UpdateTeamRequest struct {
+	Name   *string `json:"name,omitempty"`
+	Email  *string `json:"email,omitempty" binding:"omitempty,email"`
+	Locale *string `json:"locale,omitempty" binding:"omitempty,oneof=fr en"`
+}
+
+// AdjustCreditRequest either sets the credit to Amount ("set") or adds Amount to it ("adjust")
+type AdjustCreditRequest struct {
+	Mode          string `json:"mode" binding:"required,oneof=set adjust"`
+	Amount        int    `json:"amount"`
+	Justification string `json:"justification" binding:"required,min=5"`
+}
+
+// TeamCredentialsResponse is returned once when a password is generated
+type TeamCredentialsResponse struct {
+	Team     TeamResponse `json:"team"`
+	Password string       `json:"password"`
 }
 
 func (t *Team) ToResponse() TeamResponse {
@@ -43,6 +85,8 @@ func (t *Team) ToResponse() TeamResponse {
 		Name:         t.Name,
 		Email:        t.Email,
 		Credit:       t.Credit,
+		IsSuspended:  t.IsSuspended,
+		Locale:       t.Locale,
 		LastActivity: t.LastActivity,
 	}
 }
@@ -8,7 +8,8 @@ import (
 
 type TeamComposition struct {
 	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"unique;not null" json:"name"`
+	EventID   uint           `gorm:"not null;uniqueIndex:idx_team_composition_event_name" json:"event_id"`
+	Name      string         `gorm:"not null;uniqueIndex:idx_team_composition_event_name" json:"name"` // Unique within the event
 	// Total slots per department (fixed capacity)
 	DevTotal    int `gorm:"default:0;not null" json:"dev_total"`
 	InfraTotal  int `gorm:"default:0;not null" json:"infra_total"`
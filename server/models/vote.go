@@ -13,6 +13,7 @@ type Vote struct {
 	ChosenOption  string         `gorm:"not null" json:"chosen_option"`
 	CreditStaked  int            `gorm:"not null" json:"credit_staked"`
 	VoteDate      time.Time      `json:"vote_date"`
+	Payout        *int           `json:"payout"` // Credits paid back when the poll is resolved, null until then
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     time.Time      `json:"updated_at"`
 	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
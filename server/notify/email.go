@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/ericp/ylab-hackathon/emails"
+)
+
+// Mailer sends the rendered emails. It is implemented by utils.EmailService.
+type Mailer interface {
+	Send(to string, msg emails.Message) error
+}
+
+// EmailNotifier sends notifications to an email address
+type EmailNotifier struct {
+	Mailer    Mailer
+	Templates *emails.Registry
+	To        string
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, notification Notification) error {
+	msg, err := n.Templates.Render(emails.StaffNotification, emails.DefaultLocale, emails.NotificationData{
+		Title:   notification.Title,
+		Message: notification.Message,
+	})
+	if err != nil {
+		return err
+	}
+	return n.Mailer.Send(n.To, *msg)
+}
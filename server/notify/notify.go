@@ -0,0 +1,49 @@
+// Package notify delivers notifications to the staff through the channels set up by
+// the admins: email addresses and webhooks such as Discord or Slack incoming webhooks.
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Events that can be routed to channels
+const (
+	EventPurchasePending = "purchase.pending"   // A team ordered resources, waiting for approval
+	EventLowStock        = "resource.low_stock" // The available units of a resource reached its threshold
+	EventReturnOverdue   = "return.overdue"     // Rented equipment was not returned in time
+	EventPollClosed      = "poll.closed"        // A poll no longer accepts votes
+)
+
+// Events lists the events that can be routed to channels
+var Events = []string{EventPurchasePending, EventLowStock, EventReturnOverdue, EventPollClosed}
+
+// IsEvent tells whether a channel can be routed to the event
+func IsEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Types of channels
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+)
+
+// Notification is a message for the staff
+type Notification struct {
+	Event   string                 `json:"event"`
+	Title   string                 `json:"title"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"` // Details for the endpoints that process notifications
+	At      time.Time              `json:"at"`
+}
+
+// Notifier delivers notifications to a channel
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
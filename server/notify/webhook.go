@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of the webhook requests
+const (
+	HeaderEvent     = "X-YLab-Event"
+	HeaderTimestamp = "X-YLab-Timestamp" // Unix time of the request, part of the signature
+	HeaderSignature = "X-YLab-Signature" // See Sign
+)
+
+// webhookTimeout bounds a webhook request, the delivery is retried later
+const webhookTimeout = 10 * time.Second
+
+// webhookPayload is the body of the webhook requests. Text and Content are the
+// fields read by Slack and Discord incoming webhooks, other endpoints can use the
+// structured fields of the notification.
+type webhookPayload struct {
+	Notification
+	Text    string `json:"text"`
+	Content string `json:"content"`
+}
+
+// WebhookNotifier posts notifications as JSON to a URL, signed with the secret of
+// the channel
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		Notification: notification,
+		Text:         fmt.Sprintf("*%s*\n%s", notification.Title, notification.Message),
+		Content:      fmt.Sprintf("**%s**\n%s", notification.Title, notification.Message),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, notification.Event)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(n.Secret, timestamp, body))
+
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature of a webhook request: "sha256=" followed by the hex
+// HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret of the
+// channel. Receivers should also reject old timestamps to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
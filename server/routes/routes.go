@@ -3,67 +3,160 @@ package routes
 import (
 	"github.com/ericp/ylab-hackathon/controllers"
 	"github.com/ericp/ylab-hackathon/middleware"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/services"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRoutes(router *gin.Engine) {
+func SetupRoutes(router *gin.Engine, deps services.Deps) {
+	h := controllers.New(deps)
+
 	// Public routes
 	api := router.Group("/api")
 	{
 		// Auth routes
 		auth := api.Group("/auth")
 		{
-			auth.POST("/team/login", controllers.TeamLogin)
-			auth.POST("/admin/login", controllers.AdminLogin)
-			auth.GET("/verify", middleware.AuthMiddleware(""), controllers.VerifyToken)
+			auth.POST("/team/login", h.TeamLogin)
+			auth.POST("/admin/login", h.AdminLogin)
+			auth.POST("/refresh", h.RefreshToken)
+			auth.POST("/logout", middleware.AuthMiddleware(deps.DB, ""), h.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(deps.DB, ""), h.LogoutAll)
+			auth.GET("/verify", middleware.AuthMiddleware(deps.DB, ""), h.VerifyToken)
+			auth.POST("/forgot-password", h.ForgotPassword)
+			auth.POST("/reset-password", h.ResetPassword)
+			auth.PUT("/password", middleware.AuthMiddleware(deps.DB, ""), h.ChangePassword)
 		}
 
 		// Public resources (view only)
-		api.GET("/resources", controllers.GetResources)
-		api.GET("/resources/:id", controllers.GetResource)
+		api.GET("/resources", h.GetResources)
+		api.GET("/resources/:id", h.GetResource)
+
+		// Current event, with its branding
+		api.GET("/event", h.GetCurrentEvent)
 
 		// Public polls (view only)
-		api.GET("/polls", controllers.GetPolls)
-		api.GET("/polls/:id", controllers.GetPoll)
-		api.GET("/polls/:id/results", controllers.GetPollResults)
+		api.GET("/polls", h.GetPolls)
+		api.GET("/polls/:id", h.GetPoll)
+		api.GET("/polls/:id/results", h.GetPollResults)
+
+		// Live updates (Server-Sent Events) for teams and admins
+		api.GET("/events", middleware.StreamAuthMiddleware(deps.DB), h.StreamEvents)
 	}
 
 	// Team protected routes
 	team := api.Group("/team")
-	team.Use(middleware.AuthMiddleware("team"))
+	team.Use(middleware.AuthMiddleware(deps.DB, "team"))
 	{
-		team.GET("/profile", controllers.GetTeamProfile)
-		team.PUT("/profile", controllers.UpdateTeamProfile)
-		team.GET("/purchases", controllers.GetTeamPurchases)
-		team.GET("/votes", controllers.GetTeamVotes)
+		team.GET("/profile", h.GetTeamProfile)
+		team.PUT("/profile", h.UpdateTeamProfile)
+		team.GET("/purchases", h.GetTeamPurchases)
+		team.GET("/votes", h.GetTeamVotes)
+		team.GET("/ledger", h.GetTeamLedger)
 
 		// Purchase management
-		team.POST("/purchases", controllers.CreatePurchase)
-		team.POST("/purchases/batch", controllers.CreateBatchPurchase)
-		team.POST("/purchases/:id/return", controllers.ReturnPurchase)
+		team.POST("/purchases", h.CreatePurchase)
+		team.POST("/purchases/batch", h.CreateBatchPurchase)
+		team.POST("/purchases/:id/return", h.ReturnPurchase)
 
 		// Voting
-		team.POST("/votes", controllers.CreateVote)
-		team.GET("/votes/poll/:pollId", controllers.GetVote)
+		team.POST("/votes", h.CreateVote)
+		team.GET("/votes/poll/:pollId", h.GetVote)
 	}
 
-	// Admin protected routes
+	// Admin protected routes. Each route requires a permission, granted by the role
+	// of the admin (see models.AdminRole).
 	admin := api.Group("/admin")
-	admin.Use(middleware.AuthMiddleware("admin"))
+	admin.Use(middleware.AuthMiddleware(deps.DB, "admin"))
+	can := middleware.RequirePermission
 	{
 		// Purchase management
-		admin.GET("/purchases", controllers.GetAllPurchases)
-		admin.POST("/purchases/:id/action", controllers.UpdatePurchaseStatus)
-		admin.POST("/purchases/batch/action", controllers.UpdateBatchPurchaseStatus)
-		admin.POST("/purchases/:id/mark-returned", controllers.MarkPurchaseAsReturned)
-		admin.POST("/purchases/:id/unmark-returned", controllers.UnmarkPurchaseAsReturned)
-		admin.GET("/teams", controllers.GetAllTeams)
+		admin.GET("/purchases", can(models.PermissionView), h.GetAllPurchases)
+		admin.POST("/purchases/:id/action", can(models.PermissionPurchases), h.UpdatePurchaseStatus)
+		admin.POST("/purchases/batch/action", can(models.PermissionPurchases), h.UpdateBatchPurchaseStatus)
+		admin.POST("/purchases/:id/mark-returned", can(models.PermissionReturns), h.MarkPurchaseAsReturned)
+		admin.POST("/purchases/:id/unmark-returned", can(models.PermissionReturns), h.UnmarkPurchaseAsReturned)
+		admin.GET("/teams", can(models.PermissionView), h.GetAllTeams)
+		admin.POST("/teams", can(models.PermissionTeams), h.CreateTeam)
+		admin.PUT("/teams/:id", can(models.PermissionTeams), h.UpdateTeam)
+		admin.POST("/teams/:id/reset-password", can(models.PermissionTeams), h.ResetTeamPassword)
+		admin.POST("/teams/:id/credit", can(models.PermissionTeams), h.AdjustTeamCredit)
+		admin.POST("/teams/:id/suspend", can(models.PermissionTeams), h.SuspendTeam)
+		admin.POST("/teams/:id/unsuspend", can(models.PermissionTeams), h.UnsuspendTeam)
+		admin.GET("/teams/:id/ledger", can(models.PermissionView), h.GetTeamLedgerAdmin)
+		admin.GET("/ledger/check", can(models.PermissionView), h.CheckLedgers)
+
+		// Resource catalog management
+		admin.GET("/resources", can(models.PermissionView), h.GetAllResourcesAdmin)
+		admin.POST("/resources", can(models.PermissionCatalog), h.CreateResource)
+		admin.PUT("/resources/:id", can(models.PermissionCatalog), h.UpdateResource)
+		admin.POST("/resources/:id/activate", can(models.PermissionCatalog), h.ActivateResource)
+		admin.POST("/resources/:id/deactivate", can(models.PermissionCatalog), h.DeactivateResource)
+		admin.DELETE("/resources/:id", can(models.PermissionCatalog), h.DeleteResource)
+		admin.POST("/resources/:id/restore", can(models.PermissionCatalog), h.RestoreResource)
+
+		// Quota management
+		admin.GET("/quotas", can(models.PermissionView), h.GetQuotaRules)
+		admin.POST("/quotas", can(models.PermissionCatalog), h.CreateQuotaRule)
+		admin.PUT("/quotas/:id", can(models.PermissionCatalog), h.UpdateQuotaRule)
+		admin.DELETE("/quotas/:id", can(models.PermissionCatalog), h.DeleteQuotaRule)
+
+		// Poll management
+		admin.POST("/polls", can(models.PermissionPolls), h.CreatePoll)
+		admin.PUT("/polls/:id", can(models.PermissionPolls), h.UpdatePoll)
+		admin.POST("/polls/:id/close", can(models.PermissionPolls), h.ClosePoll)
+		admin.POST("/polls/:id/cancel", can(models.PermissionPolls), h.CancelPoll)
+		admin.POST("/polls/:id/resolve", can(models.PermissionPolls), h.ResolvePoll)
 
 		// Team composition management
-		admin.GET("/team-compositions", controllers.GetAllTeamCompositions)
-		admin.POST("/team-compositions/:id/toggle", controllers.ToggleTeamSlot)
+		admin.GET("/team-compositions", can(models.PermissionView), h.GetAllTeamCompositions)
+		admin.POST("/team-compositions/:id/toggle", can(models.PermissionCompositions), h.ToggleTeamSlot)
+
+		// CSV imports
+		admin.POST("/import/teams", can(models.PermissionTeams), h.ImportTeams)
+		admin.POST("/import/resources", can(models.PermissionCatalog), h.ImportResources)
+
+		// Spreadsheet exports
+		admin.GET("/export/purchases", can(models.PermissionView), h.ExportPurchases)
+		admin.GET("/export/inventory", can(models.PermissionView), h.ExportInventory)
+		admin.GET("/export/teams", can(models.PermissionView), h.ExportTeamBalances)
+
+		// Audit log
+		admin.GET("/audit", can(models.PermissionView), h.GetAuditLog)
+
+		// Emails and their templates
+		admin.GET("/emails", can(models.PermissionView), h.GetEmails)
+		admin.GET("/emails/:id", can(models.PermissionView), h.GetEmail)
+		admin.POST("/emails/:id/resend", can(models.PermissionNotifications), h.ResendEmail)
+		admin.GET("/emails/templates", can(models.PermissionView), h.GetEmailTemplates)
+		admin.GET("/emails/templates/:name/preview", can(models.PermissionView), h.PreviewEmailTemplate)
+
+		// Staff notification channels and deliveries
+		admin.GET("/notifications/channels", can(models.PermissionView), h.GetNotificationChannels)
+		admin.POST("/notifications/channels", can(models.PermissionNotifications), h.CreateNotificationChannel)
+		admin.PUT("/notifications/channels/:id", can(models.PermissionNotifications), h.UpdateNotificationChannel)
+		admin.DELETE("/notifications/channels/:id", can(models.PermissionNotifications), h.DeleteNotificationChannel)
+		admin.GET("/notifications/deliveries", can(models.PermissionView), h.GetNotificationDeliveries)
+		admin.POST("/notifications/deliveries/:id/resend", can(models.PermissionNotifications), h.ResendNotificationDelivery)
+
+		// Sessions of teams and admins, and logins locked after repeated failures
+		admin.GET("/sessions", can(models.PermissionSecurity), h.GetSessions)
+		admin.POST("/sessions/:id/revoke", can(models.PermissionSecurity), h.RevokeSession)
+		admin.GET("/login-lockouts", can(models.PermissionSecurity), h.GetLoginLockouts)
+		admin.POST("/login-lockouts/:id/unlock", can(models.PermissionSecurity), h.UnlockLogin)
+
+		// Editions of the hackathon. Switching only changes the event of the admin's session.
+		admin.GET("/events", can(models.PermissionView), h.GetEvents)
+		admin.POST("/events", can(models.PermissionEvents), h.CreateEvent)
+		admin.PUT("/events/:id", can(models.PermissionEvents), h.UpdateEvent)
+		admin.POST("/events/:id/activate", can(models.PermissionEvents), h.ActivateEvent)
+		admin.POST("/events/:id/switch", can(models.PermissionView), h.SwitchEvent)
+
+		// Admin accounts and their roles
+		admin.GET("/admins", can(models.PermissionSecurity), h.GetAdmins)
+		admin.PUT("/admins/:id/role", can(models.PermissionSecurity), h.UpdateAdminRole)
 	}
 
 	// Health check
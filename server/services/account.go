@@ -0,0 +1,245 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	passwordResetTTL      = time.Hour   // How long a reset link can be used
+	passwordResetInterval = time.Minute // Minimum time between two reset emails to an account
+)
+
+// account is a team or an admin, as seen by the password flows
+type account struct {
+	userType     string // "team" or "admin"
+	id           uint
+	name         string // Team name or admin username
+	email        string
+	locale       string
+	passwordHash string
+}
+
+// teamID is the team the emails to the account are about, nil for admins
+func (a account) teamID() *uint {
+	if a.userType != "team" {
+		return nil
+	}
+	id := a.id
+	return &id
+}
+
+func (a account) table() string {
+	if a.userType == "team" {
+		return "teams"
+	}
+	return "admins"
+}
+
+// policyNames are the names a password of the account must not contain
+func (a account) policyNames() []string {
+	return []string{a.name, strings.SplitN(a.email, "@", 2)[0]}
+}
+
+func teamAccount(team models.Team) account {
+	return account{userType: "team", id: team.ID, name: team.Name, email: team.Email, locale: team.Locale, passwordHash: team.PasswordHash}
+}
+
+func adminAccount(admin models.Admin) account {
+	return account{userType: "admin", id: admin.ID, name: admin.Username, email: admin.Email, locale: emails.DefaultLocale, passwordHash: admin.PasswordHash}
+}
+
+// findAccount loads a team or an admin by ID
+func findAccount(db *gorm.DB, userType string, id uint) (account, bool) {
+	switch userType {
+	case "team":
+		var team models.Team
+		if db.First(&team, id).Error == nil {
+			return teamAccount(team), true
+		}
+	case "admin":
+		var admin models.Admin
+		if db.First(&admin, id).Error == nil {
+			return adminAccount(admin), true
+		}
+	}
+	return account{}, false
+}
+
+// lookupAccount finds a team of the current event by name or email, or an admin by
+// username or email
+func lookupAccount(db *gorm.DB, userType, identifier string) (account, bool) {
+	identifier = strings.TrimSpace(identifier)
+	email := utils.NormalizeEmail(identifier)
+	switch userType {
+	case "team":
+		event, err := CurrentEvent(db)
+		if err != nil {
+			return account{}, false
+		}
+		var team models.Team
+		if db.Where("event_id = ? AND (name = ? OR email = ?)", event.ID, identifier, email).First(&team).Error == nil {
+			return teamAccount(team), true
+		}
+	case "admin":
+		var admin models.Admin
+		if db.Where("username = ? OR email = ?", identifier, email).First(&admin).Error == nil {
+			return adminAccount(admin), true
+		}
+	}
+	return account{}, false
+}
+
+// setPassword stores the hash of a new password, invalidates the reset links of the
+// account and ends its sessions except keepSession (0 to end them all)
+func setPassword(tx *gorm.DB, a account, passwordHash string, keepSession uint) error {
+	if err := tx.Table(a.table()).Where("id = ?", a.id).Updates(map[string]interface{}{
+		"password_hash": passwordHash,
+		"updated_at":    time.Now(),
+	}).Error; err != nil {
+		return internal("Failed to update password", err)
+	}
+	if _, err := revokeSessions(tx, a.userType, a.id, keepSession); err != nil {
+		return err
+	}
+	return invalidateResetTokens(tx, a.userType, a.id)
+}
+
+// invalidateResetTokens makes the outstanding reset links of an account unusable
+func invalidateResetTokens(tx *gorm.DB, userType string, userID uint) error {
+	if err := tx.Model(&models.PasswordResetToken{}).
+		Where("user_type = ? AND user_id = ? AND used_at IS NULL", userType, userID).
+		Update("used_at", time.Now()).Error; err != nil {
+		return internal("Failed to invalidate reset tokens", err)
+	}
+	return nil
+}
+
+// hashToken is the form of a reset or refresh token stored in the database
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AccountService lets teams and admins manage their own password
+type AccountService interface {
+	// RequestPasswordReset emails a single-use reset link to the account, if it
+	// exists. It does not tell whether it does.
+	RequestPasswordReset(req models.ForgotPasswordRequest) error
+	// ResetPassword sets a new password with the token of a reset link
+	ResetPassword(req models.ResetPasswordRequest) error
+	// ChangePassword sets a new password for the authenticated account
+	ChangePassword(actor Actor, req models.ChangePasswordRequest) error
+}
+
+type accountService struct {
+	base
+}
+
+func NewAccountService(deps Deps) AccountService {
+	return &accountService{newBase(deps)}
+}
+
+func (s *accountService) RequestPasswordReset(req models.ForgotPasswordRequest) error {
+	a, ok := lookupAccount(s.db, req.UserType, req.Identifier)
+	if !ok {
+		return nil
+	}
+
+	return transaction(s.db, func(tx *gorm.DB) error {
+		// Repeated requests do not flood the mailbox of the account
+		var recent int64
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_type = ? AND user_id = ? AND created_at > ?", a.userType, a.id, time.Now().Add(-passwordResetInterval)).
+			Count(&recent).Error; err != nil {
+			return internal("Failed to create reset token", err)
+		}
+		if recent > 0 {
+			return nil
+		}
+
+		// Only the latest link works
+		if err := invalidateResetTokens(tx, a.userType, a.id); err != nil {
+			return err
+		}
+
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return internal("Failed to create reset token", err)
+		}
+		token := hex.EncodeToString(key)
+		if err := tx.Create(&models.PasswordResetToken{
+			UserType:  a.userType,
+			UserID:    a.id,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(passwordResetTTL),
+		}).Error; err != nil {
+			return internal("Failed to create reset token", err)
+		}
+
+		return s.queueEmailTo(tx, a.email, a.teamID(), a.locale, emails.PasswordReset, emails.PasswordResetData{
+			Name:             a.name,
+			URL:              s.publicURL + "/reset-password?token=" + token,
+			ExpiresInMinutes: int(passwordResetTTL / time.Minute),
+		})
+	})
+}
+
+func (s *accountService) ResetPassword(req models.ResetPasswordRequest) error {
+	return transaction(s.db, func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashToken(req.Token), time.Now()).
+			First(&token).Error; err != nil {
+			return invalid("Invalid or expired token")
+		}
+
+		a, ok := findAccount(tx, token.UserType, token.UserID)
+		if !ok {
+			return invalid("Invalid or expired token")
+		}
+		if msg := utils.CheckPasswordPolicy(req.Password, a.policyNames()...); msg != "" {
+			return invalid(msg)
+		}
+
+		passwordHash, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return internal("Failed to hash password", err)
+		}
+		return setPassword(tx, a, passwordHash, 0)
+	})
+}
+
+func (s *accountService) ChangePassword(actor Actor, req models.ChangePasswordRequest) error {
+	a, ok := findAccount(s.db, actor.UserType, actor.UserID)
+	if !ok {
+		return notFound("Account not found")
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, a.passwordHash) {
+		return invalid("Current password is incorrect")
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return invalid("New password must be different from the current one")
+	}
+	if msg := utils.CheckPasswordPolicy(req.NewPassword, a.policyNames()...); msg != "" {
+		return invalid(msg)
+	}
+
+	passwordHash, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return internal("Failed to hash password", err)
+	}
+	// The other sessions may be the reason of the change
+	return transaction(s.db, func(tx *gorm.DB) error {
+		return setPassword(tx, a, passwordHash, actor.SessionID)
+	})
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminService manages the roles of the admin accounts
+type AdminService interface {
+	// UpdateRole changes the role of an admin and ends its sessions, so that the new
+	// role applies right away
+	UpdateRole(actor Actor, id uint, req models.UpdateAdminRoleRequest) (*models.Admin, error)
+}
+
+type adminService struct {
+	base
+}
+
+func NewAdminService(deps Deps) AdminService {
+	return &adminService{newBase(deps)}
+}
+
+func (s *adminService) UpdateRole(actor Actor, id uint, req models.UpdateAdminRoleRequest) (*models.Admin, error) {
+	if !models.IsValidAdminRole(string(req.Role)) {
+		return nil, invalid("Invalid role")
+	}
+
+	var admin models.Admin
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&admin, id).Error; err != nil {
+			return notFound("Admin not found")
+		}
+		if admin.Role == req.Role {
+			return nil
+		}
+
+		// Someone must be left to manage the roles
+		if admin.Role == models.RoleSuperAdmin {
+			var superAdmins int64
+			if err := tx.Model(&models.Admin{}).Where("role = ?", models.RoleSuperAdmin).Count(&superAdmins).Error; err != nil {
+				return internal("Failed to update role", err)
+			}
+			if superAdmins <= 1 {
+				return conflict("Cannot demote the last super-admin")
+			}
+		}
+
+		before := admin
+		admin.Role = req.Role
+		if err := tx.Model(&admin).Update("role", admin.Role).Error; err != nil {
+			return internal("Failed to update role", err)
+		}
+		if _, err := revokeSessions(tx, "admin", admin.ID, 0); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditAdminUpdateRole, models.AuditEntityAdmin, admin.ID, before, admin)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"encoding/json"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+// RecordAudit appends an audit entry for an admin action. It should run in the same
+// transaction as the change so that no action is left unrecorded. Actions of teams
+// are not audited. before and after are serialized as JSON, nil meaning the entity
+// did not exist.
+func RecordAudit(tx *gorm.DB, actor Actor, action, entityType string, entityID uint, before, after interface{}) error {
+	adminID := actor.AdminID()
+	if adminID == nil {
+		return nil
+	}
+
+	entry := models.AuditEntry{
+		AdminID:    *adminID,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	if before != nil {
+		data, err := json.Marshal(before)
+		if err != nil {
+			return err
+		}
+		entry.Before = data
+	}
+	if after != nil {
+		data, err := json.Marshal(after)
+		if err != nil {
+			return err
+		}
+		entry.After = data
+	}
+
+	return tx.Create(&entry).Error
+}
+
+// recordAudit records an admin action, reported as a service error when it fails
+func recordAudit(tx *gorm.DB, actor Actor, action, entityType string, entityID uint, before, after interface{}) error {
+	if err := RecordAudit(tx, actor, action, entityType, entityID, before, after); err != nil {
+		return internal("Failed to record audit entry", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+// CompositionService tracks the filled slots of each department in the teams
+type CompositionService interface {
+	// List returns the team compositions of an event
+	List(eventID uint) ([]models.TeamComposition, error)
+	// ToggleSlot fills or empties a slot of a department, within its bounds
+	ToggleSlot(actor Actor, id uint, req models.ToggleSlotRequest) (*models.TeamComposition, error)
+}
+
+type compositionService struct {
+	base
+}
+
+func NewCompositionService(deps Deps) CompositionService {
+	return &compositionService{newBase(deps)}
+}
+
+func (s *compositionService) List(eventID uint) ([]models.TeamComposition, error) {
+	var teams []models.TeamComposition
+	if err := s.db.Where("event_id = ?", eventID).Find(&teams).Error; err != nil {
+		return nil, internal("Failed to fetch team compositions", err)
+	}
+	return teams, nil
+}
+
+// toggleSlot changes a filled count by one, keeping it between 0 and total
+func toggleSlot(filled *int, total, change int) {
+	*filled += change
+	if *filled < 0 {
+		*filled = 0
+	}
+	if *filled > total {
+		*filled = total
+	}
+}
+
+func (s *compositionService) ToggleSlot(actor Actor, id uint, req models.ToggleSlotRequest) (*models.TeamComposition, error) {
+	var team models.TeamComposition
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := inEvent(tx, actor).First(&team, id).Error; err != nil {
+			return notFound("Team composition not found")
+		}
+
+		before := team
+
+		// Calculate the change
+		change := 1
+		if req.Action == "empty" {
+			change = -1
+		}
+
+		// Update the appropriate department filled count
+		switch req.Department {
+		case "dev":
+			toggleSlot(&team.DevFilled, team.DevTotal, change)
+		case "infra":
+			toggleSlot(&team.InfraFilled, team.InfraTotal, change)
+		case "data":
+			toggleSlot(&team.DataFilled, team.DataTotal, change)
+		case "iot":
+			toggleSlot(&team.IoTFilled, team.IoTTotal, change)
+		case "sysemb":
+			toggleSlot(&team.SysembFilled, team.SysembTotal, change)
+		}
+
+		if err := tx.Save(&team).Error; err != nil {
+			return internal("Failed to update team composition", err)
+		}
+		return recordAudit(tx, actor, models.AuditCompositionToggleSlot, models.AuditEntityTeamComposition, team.ID, before, team)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
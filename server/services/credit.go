@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var errInsufficientCredit = errors.New("insufficient credit")
+
+// applyCreditChange locks the team row, updates its balance and appends the matching
+// ledger entry. The team is reloaded so that the caller sees the new balance.
+// Debits that would make the balance negative fail with errInsufficientCredit.
+func applyCreditChange(tx *gorm.DB, team *models.Team, amount int, entry models.CreditTransaction) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(team, team.ID).Error; err != nil {
+		return err
+	}
+
+	if amount < 0 && team.Credit+amount < 0 {
+		return errInsufficientCredit
+	}
+
+	team.Credit += amount
+	if err := tx.Model(team).Update("credit", team.Credit).Error; err != nil {
+		return err
+	}
+
+	entry.TeamID = team.ID
+	entry.Amount = amount
+	entry.BalanceAfter = team.Credit
+	return tx.Create(&entry).Error
+}
+
+// EnsureOpeningBalances records the current credit of teams that have no ledger entry
+// yet, so that Team.Credit can be derived from the ledger for teams created before it existed.
+func EnsureOpeningBalances(db *gorm.DB) error {
+	var teams []models.Team
+	if err := db.
+		Where("NOT EXISTS (SELECT 1 FROM credit_transactions ct WHERE ct.team_id = teams.id)").
+		Find(&teams).Error; err != nil {
+		return err
+	}
+
+	for _, team := range teams {
+		entry := models.CreditTransaction{
+			TeamID:       team.ID,
+			Amount:       team.Credit,
+			BalanceAfter: team.Credit,
+			Reason:       models.CreditReasonOpening,
+		}
+		if err := db.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(teams) > 0 {
+		log.Printf("Recorded opening balances for %d team(s)", len(teams))
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// Kind classifies the errors returned by the services
+type Kind int
+
+const (
+	KindInvalid         Kind = iota + 1 // The request breaks a business rule
+	KindNotFound                        // The entity does not exist
+	KindForbidden                       // The actor may not act on the entity
+	KindConflict                        // The entity is in a state that prevents the change
+	KindUnauthorized                    // The credentials are missing or no longer valid
+	KindTooManyRequests                 // The action is refused until later, see the retry_after detail
+	KindInternal                        // Database or other unexpected failure
+)
+
+// Error is returned by the services when an action is refused or fails. Message is
+// safe to show to the client; Details are extra fields of the error response.
+type Error struct {
+	Kind    Kind
+	Message string
+	Details map[string]interface{}
+	Err     error // Underlying cause of an internal error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With adds a field to the error response
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func invalid(msg string) *Error {
+	return &Error{Kind: KindInvalid, Message: msg}
+}
+
+func notFound(msg string) *Error {
+	return &Error{Kind: KindNotFound, Message: msg}
+}
+
+func forbidden(msg string) *Error {
+	return &Error{Kind: KindForbidden, Message: msg}
+}
+
+func conflict(msg string) *Error {
+	return &Error{Kind: KindConflict, Message: msg}
+}
+
+func unauthorized(msg string) *Error {
+	return &Error{Kind: KindUnauthorized, Message: msg}
+}
+
+func internal(msg string, err error) *Error {
+	return &Error{Kind: KindInternal, Message: msg, Err: err}
+}
+
+// transaction runs fn in a database transaction. Errors that are not service errors,
+// such as a failed commit, are reported as "Transaction failed".
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	err := db.Transaction(fn)
+	var svcErr *Error
+	if err != nil && !errors.As(err, &svcErr) {
+		return internal("Transaction failed", err)
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CurrentEvent returns the event of the public pages and team logins
+func CurrentEvent(db *gorm.DB) (*models.Event, error) {
+	var event models.Event
+	if err := db.Where("is_current = ?", true).First(&event).Error; err != nil {
+		return nil, notFound("No current event")
+	}
+	return &event, nil
+}
+
+// inEvent restricts a query to the rows of the event of the actor, so that an admin
+// only reaches by ID the entities of the event it selected
+func inEvent(db *gorm.DB, actor Actor) *gorm.DB {
+	return db.Where("event_id = ?", actor.EventID)
+}
+
+// EventService manages the editions of the hackathon
+type EventService interface {
+	// List returns every event, the latest first
+	List() ([]models.Event, error)
+	Get(id uint) (*models.Event, error)
+	Create(actor Actor, req models.CreateEventRequest) (*models.Event, error)
+	Update(actor Actor, id uint, req models.UpdateEventRequest) (*models.Event, error)
+	// Activate makes an event the current one, for the public pages and team logins
+	Activate(actor Actor, id uint) (*models.Event, error)
+	// Select chooses the event the admin listings and changes of a session apply to
+	Select(actor Actor, id uint) (*models.Event, error)
+}
+
+type eventService struct {
+	base
+}
+
+func NewEventService(deps Deps) EventService {
+	return &eventService{newBase(deps)}
+}
+
+func (s *eventService) List() ([]models.Event, error) {
+	var events []models.Event
+	if err := s.db.Order("starts_at DESC, id DESC").Find(&events).Error; err != nil {
+		return nil, internal("Failed to fetch events", err)
+	}
+	return events, nil
+}
+
+func (s *eventService) Get(id uint) (*models.Event, error) {
+	var event models.Event
+	if err := s.db.First(&event, id).Error; err != nil {
+		return nil, notFound("Event not found")
+	}
+	return &event, nil
+}
+
+// eventNameTaken checks the unique name of the events
+func eventNameTaken(db *gorm.DB, name string, excludeID uint) bool {
+	var count int64
+	db.Model(&models.Event{}).Where("name = ? AND id != ?", name, excludeID).Count(&count)
+	return count > 0
+}
+
+func (s *eventService) Create(actor Actor, req models.CreateEventRequest) (*models.Event, error) {
+	event := models.Event{
+		Name:           strings.TrimSpace(req.Name),
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		StartingCredit: models.DefaultTeamCredit,
+		LogoURL:        req.LogoURL,
+		PrimaryColor:   req.PrimaryColor,
+	}
+	if req.StartingCredit != nil {
+		event.StartingCredit = *req.StartingCredit
+	}
+
+	if msg := event.Validate(); msg != "" {
+		return nil, invalid(msg)
+	}
+	if eventNameTaken(s.db, event.Name, 0) {
+		return nil, invalid("Event name already taken")
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&event).Error; err != nil {
+			return internal("Failed to create event", err)
+		}
+
+		// GORM skips zero values on create and would keep the column default
+		if event.StartingCredit == 0 {
+			if err := tx.Model(&event).Update("starting_credit", 0).Error; err != nil {
+				return internal("Failed to create event", err)
+			}
+		}
+
+		return recordAudit(tx, actor, models.AuditEventCreate, models.AuditEntityEvent, event.ID, nil, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *eventService) Update(actor Actor, id uint, req models.UpdateEventRequest) (*models.Event, error) {
+	event, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *event
+	if req.Name != nil {
+		event.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.StartsAt != nil {
+		event.StartsAt = *req.StartsAt
+	}
+	if req.EndsAt != nil {
+		event.EndsAt = *req.EndsAt
+	}
+	if req.StartingCredit != nil {
+		event.StartingCredit = *req.StartingCredit
+	}
+	if req.LogoURL != nil {
+		event.LogoURL = *req.LogoURL
+	}
+	if req.PrimaryColor != nil {
+		event.PrimaryColor = *req.PrimaryColor
+	}
+
+	if msg := event.Validate(); msg != "" {
+		return nil, invalid(msg)
+	}
+	if eventNameTaken(s.db, event.Name, event.ID) {
+		return nil, invalid("Event name already taken")
+	}
+
+	err = transaction(s.db, func(tx *gorm.DB) error {
+		// The current event is only changed by Activate
+		if err := tx.Omit("is_current").Save(event).Error; err != nil {
+			return internal("Failed to update event", err)
+		}
+		return recordAudit(tx, actor, models.AuditEventUpdate, models.AuditEntityEvent, event.ID, before, *event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *eventService) Activate(actor Actor, id uint) (*models.Event, error) {
+	var event models.Event
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&event, id).Error; err != nil {
+			return notFound("Event not found")
+		}
+		if event.IsCurrent {
+			return nil
+		}
+
+		before := event
+		if err := tx.Model(&models.Event{}).Where("is_current = ?", true).Update("is_current", false).Error; err != nil {
+			return internal("Failed to activate event", err)
+		}
+		if err := tx.Model(&event).Update("is_current", true).Error; err != nil {
+			return internal("Failed to activate event", err)
+		}
+		return recordAudit(tx, actor, models.AuditEventActivate, models.AuditEntityEvent, event.ID, before, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *eventService) Select(actor Actor, id uint) (*models.Event, error) {
+	if actor.UserType != "admin" {
+		return nil, forbidden("Only admins can switch events")
+	}
+	event, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&models.Session{}).Where("id = ?", actor.SessionID).Update("event_id", event.ID).Error; err != nil {
+		return nil, internal("Failed to switch event", err)
+	}
+	return event, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	loginFreeFailures    = 3                // Failures to an account before its next attempts are delayed
+	loginMaxDelay        = time.Minute      // Longest delay between two attempts to an account
+	loginAccountLimit    = 10               // Failures locking an account
+	loginIPLimit         = 50               // Failures locking an address, whatever the accounts
+	loginLockoutDuration = 15 * time.Minute // How long a lockout lasts unless an admin lifts it
+	loginFailureWindow   = time.Hour        // Failures are forgotten after this long without attempts
+)
+
+// LoginAttempt is a login to throttle: the account it targets, if one matches, and
+// the address it comes from
+type LoginAttempt struct {
+	UserType   string // "team" or "admin"
+	UserID     uint   // 0 when no account matches the identifier
+	Identifier string // Name, username or email, as typed
+	IPAddress  string
+}
+
+// accountKey is the throttle key of the account, empty when there is none
+func (a LoginAttempt) accountKey() string {
+	if a.UserID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", a.UserType, a.UserID)
+}
+
+// loginDelay is the wait imposed after the failures to an account: nothing for the
+// first ones, then a second doubled at each failure
+func loginDelay(failures int) time.Duration {
+	if failures < loginFreeFailures {
+		return 0
+	}
+	if failures-loginFreeFailures >= 6 {
+		return loginMaxDelay
+	}
+	return time.Second << uint(failures-loginFreeFailures)
+}
+
+// tooManyAttempts refuses a login that comes too early, telling when to retry
+func tooManyAttempts(wait time.Duration) *Error {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	err := &Error{Kind: KindTooManyRequests, Message: "Too many failed login attempts, try again later"}
+	return err.With("retry_after", seconds)
+}
+
+// LoginService throttles the failed logins per account and per address. Failures to
+// an account delay its next attempts, then lock it for a while, as do too many
+// failures from the same address.
+type LoginService interface {
+	// Check refuses an attempt while its account or its address is locked or must
+	// wait after its last failures. It is called before the password is checked.
+	Check(attempt LoginAttempt) error
+	// Fail counts a failed attempt, locking the account or the address past the limits
+	Fail(attempt LoginAttempt) error
+	// Succeed forgets the failures to the account
+	Succeed(attempt LoginAttempt) error
+	// Unlock lifts a lockout before it ends
+	Unlock(actor Actor, id uint) (*models.LoginLockout, error)
+}
+
+type loginService struct {
+	base
+}
+
+func NewLoginService(deps Deps) LoginService {
+	return &loginService{newBase(deps)}
+}
+
+func (s *loginService) Check(attempt LoginAttempt) error {
+	return transaction(s.db, func(tx *gorm.DB) error {
+		now := time.Now()
+		for _, key := range []string{attempt.IPAddress, attempt.accountKey()} {
+			if key == "" {
+				continue
+			}
+
+			var throttle models.LoginThrottle
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&throttle).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return internal("Failed to check login attempts", err)
+			}
+
+			if throttle.LockedUntil != nil && throttle.LockedUntil.After(now) {
+				return tooManyAttempts(throttle.LockedUntil.Sub(now))
+			}
+			delay := loginDelay(throttle.Failures)
+			if throttle.Scope != models.LoginScopeAccount || delay == 0 || now.Sub(throttle.LastAttemptAt) > loginFailureWindow {
+				continue
+			}
+			if wait := throttle.LastAttemptAt.Add(delay).Sub(now); wait > 0 {
+				return tooManyAttempts(wait)
+			}
+			// Concurrent attempts wait for each other too
+			if err := tx.Model(&throttle).Update("last_attempt_at", now).Error; err != nil {
+				return internal("Failed to check login attempts", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *loginService) Fail(attempt LoginAttempt) error {
+	return transaction(s.db, func(tx *gorm.DB) error {
+		if key := attempt.accountKey(); key != "" {
+			if err := countFailure(tx, models.LoginScopeAccount, key, attempt, loginAccountLimit); err != nil {
+				return err
+			}
+		}
+		if attempt.IPAddress != "" {
+			return countFailure(tx, models.LoginScopeIP, attempt.IPAddress, attempt, loginIPLimit)
+		}
+		return nil
+	})
+}
+
+// countFailure adds a failure to an account or an address and locks it when it
+// reaches the limit
+func countFailure(tx *gorm.DB, scope, key string, attempt LoginAttempt, limit int) error {
+	now := time.Now()
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Scope: scope, Key: key, LastAttemptAt: now}).Error; err != nil {
+		return internal("Failed to record login attempt", err)
+	}
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&throttle).Error; err != nil {
+		return internal("Failed to record login attempt", err)
+	}
+
+	// A new count starts once a lockout has ended or after a long quiet period
+	if (throttle.LockedUntil != nil && !throttle.LockedUntil.After(now)) || now.Sub(throttle.LastAttemptAt) > loginFailureWindow {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	}
+	throttle.Failures++
+	throttle.LastAttemptAt = now
+
+	if throttle.Failures >= limit && throttle.LockedUntil == nil {
+		until := now.Add(loginLockoutDuration)
+		throttle.LockedUntil = &until
+
+		lockout := models.LoginLockout{
+			Scope:       scope,
+			Key:         key,
+			IPAddress:   attempt.IPAddress,
+			Identifier:  attempt.Identifier,
+			Failures:    throttle.Failures,
+			LockedUntil: until,
+		}
+		if scope == models.LoginScopeAccount {
+			userID := attempt.UserID
+			lockout.UserType = attempt.UserType
+			lockout.UserID = &userID
+		}
+		if err := tx.Create(&lockout).Error; err != nil {
+			return internal("Failed to record lockout", err)
+		}
+		log.Printf("Login locked for %s %s after %d failures, last from %s", scope, key, throttle.Failures, attempt.IPAddress)
+	}
+
+	if err := tx.Save(&throttle).Error; err != nil {
+		return internal("Failed to record login attempt", err)
+	}
+	return nil
+}
+
+func (s *loginService) Succeed(attempt LoginAttempt) error {
+	if err := s.db.Where("key = ?", attempt.accountKey()).Delete(&models.LoginThrottle{}).Error; err != nil {
+		return internal("Failed to record login attempt", err)
+	}
+	return nil
+}
+
+func (s *loginService) Unlock(actor Actor, id uint) (*models.LoginLockout, error) {
+	var lockout models.LoginLockout
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&lockout, id).Error; err != nil {
+			return notFound("Lockout not found")
+		}
+		if !lockout.IsActive() {
+			return invalid("Lockout is not active")
+		}
+
+		before := lockout
+		now := time.Now()
+		lockout.UnlockedAt = &now
+		lockout.UnlockedBy = actor.AdminID()
+		if err := tx.Model(&lockout).Updates(map[string]interface{}{
+			"unlocked_at": lockout.UnlockedAt,
+			"unlocked_by": lockout.UnlockedBy,
+		}).Error; err != nil {
+			return internal("Failed to unlock", err)
+		}
+		if err := tx.Model(&models.LoginThrottle{}).Where("key = ?", lockout.Key).Updates(map[string]interface{}{
+			"failures":     0,
+			"locked_until": nil,
+		}).Error; err != nil {
+			return internal("Failed to unlock", err)
+		}
+		return recordAudit(tx, actor, models.AuditLockoutUnlock, models.AuditEntityLockout, lockout.ID, before, lockout)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lockout, nil
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notifyStaff queues a notification for every active channel of the hackathon event
+// that caused it and routed to its type. Like queueEmail, it runs in the transaction
+// of the change it notifies.
+func (b base) notifyStaff(tx *gorm.DB, eventID uint, n notify.Notification) error {
+	var channels []models.NotificationChannel
+	if err := tx.Where("event_id = ? AND is_active = ?", eventID, true).Order("id ASC").Find(&channels).Error; err != nil {
+		return internal("Failed to fetch notification channels", err)
+	}
+
+	n.At = time.Now()
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return internal("Failed to queue notification", err)
+	}
+
+	for _, channel := range channels {
+		if !channel.Routes(n.Event) {
+			continue
+		}
+		delivery := models.NotificationDelivery{
+			ChannelID:     channel.ID,
+			Event:         n.Event,
+			Payload:       payload,
+			Status:        models.DeliveryStatusPending,
+			NextAttemptAt: n.At,
+		}
+		if err := tx.Create(&delivery).Error; err != nil {
+			return internal("Failed to queue notification", err)
+		}
+	}
+	return nil
+}
+
+// notifyPurchasePending tells the staff that purchases wait for approval
+func (b base) notifyPurchasePending(tx *gorm.DB, team models.Team, purchases ...models.Purchase) error {
+	items := make([]string, len(purchases))
+	data := make([]map[string]interface{}, len(purchases))
+	total := 0
+	for i, p := range purchases {
+		items[i] = fmt.Sprintf("%d × %s", p.Quantity, p.Resource.Name)
+		data[i] = map[string]interface{}{
+			"purchase_id":   p.ID,
+			"resource_id":   p.ResourceID,
+			"resource_name": p.Resource.Name,
+			"quantity":      p.Quantity,
+		}
+		total += p.TotalCost()
+	}
+
+	return b.notifyStaff(tx, team.EventID, notify.Notification{
+		Event:   notify.EventPurchasePending,
+		Title:   "Nouvelle commande en attente",
+		Message: fmt.Sprintf("%s a commandé %s pour %d crédits.", team.Name, strings.Join(items, ", "), total),
+		Data: map[string]interface{}{
+			"team_id":    team.ID,
+			"team_name":  team.Name,
+			"batch_id":   purchases[0].BatchID,
+			"total_cost": total,
+			"purchases":  data,
+		},
+	})
+}
+
+// notifyLowStock tells the staff when taking units of a resource brings its available
+// units down to its low stock threshold. It runs after the stock change, which locks
+// the resource row: concurrent changes are counted once each.
+func (b base) notifyLowStock(tx *gorm.DB, resourceID uint, taken int) error {
+	var resource models.Resource
+	if err := tx.First(&resource, resourceID).Error; err != nil {
+		return internal("Failed to fetch resource", err)
+	}
+
+	available := resource.AvailableQuantity()
+	if available > resource.LowStockThreshold || available+taken <= resource.LowStockThreshold {
+		return nil
+	}
+
+	message := fmt.Sprintf("Il ne reste que %d unité(s) disponible(s) de %s.", available, resource.Name)
+	if available <= 0 {
+		message = fmt.Sprintf("%s est en rupture de stock.", resource.Name)
+	}
+	return b.notifyStaff(tx, resource.EventID, notify.Notification{
+		Event:   notify.EventLowStock,
+		Title:   "Stock faible : " + resource.Name,
+		Message: message,
+		Data: map[string]interface{}{
+			"resource_id":         resource.ID,
+			"resource_name":       resource.Name,
+			"available_quantity":  available,
+			"reserved_quantity":   resource.Reserved,
+			"quantity":            resource.Quantity,
+			"low_stock_threshold": resource.LowStockThreshold,
+		},
+	})
+}
+
+// notifyReturnOverdue tells the staff that a team has not returned rented equipment
+// in time
+func (b base) notifyReturnOverdue(tx *gorm.DB, team models.Team, rentals []models.Purchase) error {
+	items := make([]string, len(rentals))
+	data := make([]map[string]interface{}, len(rentals))
+	for i, p := range rentals {
+		items[i] = fmt.Sprintf("%d × %s", p.Quantity, p.Resource.Name)
+		data[i] = map[string]interface{}{
+			"purchase_id":   p.ID,
+			"resource_id":   p.ResourceID,
+			"resource_name": p.Resource.Name,
+			"quantity":      p.Quantity,
+			"return_due_at": p.ReturnDueAt,
+		}
+	}
+
+	return b.notifyStaff(tx, team.EventID, notify.Notification{
+		Event:   notify.EventReturnOverdue,
+		Title:   "Retour en retard : " + team.Name,
+		Message: fmt.Sprintf("%s n'a pas rendu à temps %s.", team.Name, strings.Join(items, ", ")),
+		Data: map[string]interface{}{
+			"team_id":   team.ID,
+			"team_name": team.Name,
+			"purchases": data,
+		},
+	})
+}
+
+// notifyPollClosed tells the staff that a poll no longer accepts votes and can be
+// resolved
+func (b base) notifyPollClosed(tx *gorm.DB, poll models.Poll) error {
+	var votes int64
+	var staked int
+	if err := tx.Model(&models.Vote{}).Where("poll_id = ?", poll.ID).Count(&votes).Error; err != nil {
+		return internal("Failed to count votes", err)
+	}
+	if err := tx.Model(&models.Vote{}).Where("poll_id = ?", poll.ID).
+		Select("COALESCE(SUM(credit_staked), 0)").Scan(&staked).Error; err != nil {
+		return internal("Failed to count votes", err)
+	}
+
+	return b.notifyStaff(tx, poll.EventID, notify.Notification{
+		Event:   notify.EventPollClosed,
+		Title:   "Sondage fermé",
+		Message: fmt.Sprintf("Le sondage « %s » est fermé avec %d vote(s) pour %d crédits misés. Il peut être résolu.", poll.Question, votes, staked),
+		Data: map[string]interface{}{
+			"poll_id":       poll.ID,
+			"question":      poll.Question,
+			"votes":         votes,
+			"credit_staked": staked,
+		},
+	})
+}
+
+// validateNotificationChannel checks the business rules shared by channel creation
+// and update
+func validateNotificationChannel(channel *models.NotificationChannel) string {
+	if strings.TrimSpace(channel.Name) == "" {
+		return "Name is required"
+	}
+	switch channel.Type {
+	case notify.ChannelEmail:
+		if _, err := mail.ParseAddress(channel.Target); err != nil {
+			return "Invalid email address"
+		}
+	case notify.ChannelWebhook:
+		u, err := url.Parse(channel.Target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "Invalid webhook URL (expected http or https)"
+		}
+	default:
+		return "Invalid channel type (expected email or webhook)"
+	}
+	if len(channel.Events) == 0 {
+		return "At least one event is required"
+	}
+	for _, event := range channel.Events {
+		if !notify.IsEvent(event) {
+			return fmt.Sprintf("Unknown event %q (expected one of %s)", event, strings.Join(notify.Events, ", "))
+		}
+	}
+	return ""
+}
+
+// generateSecret returns a random key to sign webhook requests
+func generateSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// NotificationService manages the channels the staff is notified on
+type NotificationService interface {
+	// ListChannels returns the channels of an event
+	ListChannels(eventID uint) ([]models.NotificationChannel, error)
+	// CreateChannel returns the new channel and, for webhooks, the secret signing its
+	// requests, which is never shown again
+	CreateChannel(actor Actor, req models.CreateNotificationChannelRequest) (*models.NotificationChannel, string, error)
+	UpdateChannel(actor Actor, id uint, req models.UpdateNotificationChannelRequest) (*models.NotificationChannel, error)
+	DeleteChannel(actor Actor, id uint) error
+	// ResendDelivery queues a failed delivery again, for a new series of attempts
+	ResendDelivery(actor Actor, id uint) (*models.NotificationDelivery, error)
+}
+
+type notificationService struct {
+	base
+}
+
+func NewNotificationService(deps Deps) NotificationService {
+	return &notificationService{newBase(deps)}
+}
+
+func (s *notificationService) ListChannels(eventID uint) ([]models.NotificationChannel, error) {
+	var channels []models.NotificationChannel
+	if err := s.db.Where("event_id = ?", eventID).Order("id ASC").Find(&channels).Error; err != nil {
+		return nil, internal("Failed to fetch notification channels", err)
+	}
+	return channels, nil
+}
+
+func (s *notificationService) CreateChannel(actor Actor, req models.CreateNotificationChannelRequest) (*models.NotificationChannel, string, error) {
+	channel := models.NotificationChannel{
+		EventID:  actor.EventID,
+		Name:     strings.TrimSpace(req.Name),
+		Type:     req.Type,
+		Target:   strings.TrimSpace(req.Target),
+		Events:   models.StringArray(req.Events),
+		IsActive: true,
+	}
+	if req.IsActive != nil {
+		channel.IsActive = *req.IsActive
+	}
+
+	if msg := validateNotificationChannel(&channel); msg != "" {
+		return nil, "", invalid(msg)
+	}
+	if channel.Type == notify.ChannelWebhook {
+		channel.Secret = req.Secret
+		if channel.Secret == "" {
+			secret, err := generateSecret()
+			if err != nil {
+				return nil, "", internal("Failed to generate secret", err)
+			}
+			channel.Secret = secret
+		}
+	}
+
+	active := channel.IsActive
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&channel).Error; err != nil {
+			return internal("Failed to create notification channel", err)
+		}
+
+		// GORM skips false booleans on create and would keep the column default (true)
+		if !active {
+			if err := tx.Model(&channel).Update("is_active", false).Error; err != nil {
+				return internal("Failed to create notification channel", err)
+			}
+		}
+
+		return recordAudit(tx, actor, models.AuditNotificationCreate, models.AuditEntityNotification, channel.ID, nil, channel)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &channel, channel.Secret, nil
+}
+
+func (s *notificationService) UpdateChannel(actor Actor, id uint, req models.UpdateNotificationChannelRequest) (*models.NotificationChannel, error) {
+	var channel models.NotificationChannel
+	if err := inEvent(s.db, actor).First(&channel, id).Error; err != nil {
+		return nil, notFound("Notification channel not found")
+	}
+	before := channel
+
+	if req.Name != nil {
+		channel.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Target != nil {
+		channel.Target = strings.TrimSpace(*req.Target)
+	}
+	if req.Events != nil {
+		channel.Events = models.StringArray(req.Events)
+	}
+	if req.IsActive != nil {
+		channel.IsActive = *req.IsActive
+	}
+
+	if msg := validateNotificationChannel(&channel); msg != "" {
+		return nil, invalid(msg)
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Save(&channel).Error; err != nil {
+			return internal("Failed to update notification channel", err)
+		}
+		return recordAudit(tx, actor, models.AuditNotificationUpdate, models.AuditEntityNotification, channel.ID, before, channel)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (s *notificationService) DeleteChannel(actor Actor, id uint) error {
+	var channel models.NotificationChannel
+	if err := inEvent(s.db, actor).First(&channel, id).Error; err != nil {
+		return notFound("Notification channel not found")
+	}
+
+	return transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Delete(&channel).Error; err != nil {
+			return internal("Failed to delete notification channel", err)
+		}
+		return recordAudit(tx, actor, models.AuditNotificationDelete, models.AuditEntityNotification, channel.ID, channel, nil)
+	})
+}
+
+func (s *notificationService) ResendDelivery(actor Actor, id uint) (*models.NotificationDelivery, error) {
+	var delivery models.NotificationDelivery
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("channel_id IN (?)", inEvent(tx.Unscoped().Model(&models.NotificationChannel{}).Select("id"), actor)).
+			First(&delivery, id).Error; err != nil {
+			return notFound("Notification delivery not found")
+		}
+		if delivery.Status != models.DeliveryStatusFailed {
+			return invalid("Only failed deliveries can be resent")
+		}
+
+		before := delivery
+		delivery.Status = models.DeliveryStatusPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = time.Now()
+		if err := tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":          delivery.Status,
+			"attempts":        0,
+			"next_attempt_at": delivery.NextAttemptAt,
+		}).Error; err != nil {
+			return internal("Failed to resend notification", err)
+		}
+		return recordAudit(tx, actor, models.AuditDeliveryResend, models.AuditEntityDelivery, delivery.ID, deliveryAuditState(before), deliveryAuditState(delivery))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// deliveryAuditState is the part of a delivery recorded in the audit log, without its
+// payload
+func deliveryAuditState(delivery models.NotificationDelivery) map[string]interface{} {
+	return map[string]interface{}{
+		"channel_id": delivery.ChannelID,
+		"event":      delivery.Event,
+		"status":     delivery.Status,
+		"attempts":   delivery.Attempts,
+		"last_error": delivery.LastError,
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/models"
+)
+
+// purchaseItems lists purchases in an email
+func purchaseItems(purchases ...models.Purchase) []emails.Item {
+	items := make([]emails.Item, len(purchases))
+	for i, p := range purchases {
+		items[i] = emails.Item{Resource: p.Resource.Name, Quantity: p.Quantity, RequestedQuantity: p.RequestedQuantity, ReturnBy: p.ReturnDueAt}
+	}
+	return items
+}
+
+// publishPurchaseEvent notifies the team owning a purchase, and the admins for new
+// requests and returns
+func (b base) publishPurchaseEvent(eventType string, purchase models.Purchase) {
+	if b.events == nil {
+		return
+	}
+
+	data := map[string]interface{}{
+		"purchase_id":        purchase.ID,
+		"batch_id":           purchase.BatchID,
+		"team_id":            purchase.TeamID,
+		"resource_id":        purchase.ResourceID,
+		"quantity":           purchase.Quantity,
+		"requested_quantity": purchase.RequestedQuantity,
+		"status":             purchase.Status,
+		"is_returned":        purchase.IsReturned,
+	}
+	if purchase.Resource.ID != 0 {
+		data["resource_name"] = purchase.Resource.Name
+	}
+
+	b.events.ToTeam(purchase.TeamID, eventType, data)
+	if eventType == events.PurchaseCreated || eventType == events.PurchaseReturned {
+		b.events.ToAdmins(purchase.EventID, eventType, data)
+	}
+}
+
+// publishCreditUpdate sends its new balance to a team
+func (b base) publishCreditUpdate(team models.Team) {
+	if b.events != nil {
+		b.events.ToTeam(team.ID, events.CreditUpdated, map[string]interface{}{"credit": team.Credit})
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// queueEmail renders a template in the locale of the team and adds it to the outbox.
+// It runs in the transaction of the change it notifies: the email is sent if and
+// only if the change is committed.
+func (b base) queueEmail(tx *gorm.DB, team models.Team, template string, data interface{}) error {
+	teamID := team.ID
+	return b.queueEmailTo(tx, team.Email, &teamID, team.Locale, template, data)
+}
+
+// queueEmailTo is queueEmail for any recipient, e.g. an admin. teamID is nil when the
+// recipient is not a team.
+func (b base) queueEmailTo(tx *gorm.DB, recipient string, teamID *uint, locale, template string, data interface{}) error {
+	if b.templates == nil {
+		return nil
+	}
+
+	msg, err := b.templates.RenderFor(eventName(tx, teamID), template, locale, data)
+	if err != nil {
+		// A broken template must not block the change it notifies
+		log.Printf("Failed to render email %s for %s: %v", template, recipient, err)
+		return nil
+	}
+
+	email := models.EmailOutbox{
+		TeamID:        teamID,
+		Recipient:     recipient,
+		Template:      template,
+		Subject:       msg.Subject,
+		HTML:          msg.HTML,
+		Text:          msg.Text,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := tx.Create(&email).Error; err != nil {
+		return internal("Failed to queue email", err)
+	}
+	return nil
+}
+
+// eventName is the name shown in the emails: the event of the team, or the current
+// one for the other recipients. It is empty when there is none, and the templates
+// fall back to EVENT_NAME.
+func eventName(tx *gorm.DB, teamID *uint) string {
+	var name string
+	if teamID != nil {
+		tx.Model(&models.Event{}).
+			Joins("JOIN teams ON teams.event_id = events.id").
+			Where("teams.id = ?", *teamID).
+			Select("events.name").
+			Scan(&name)
+	} else if event, err := CurrentEvent(tx); err == nil {
+		name = event.Name
+	}
+	return name
+}
+
+// OutboxService lets admins follow the emails of the outbox
+type OutboxService interface {
+	// Resend queues a failed email again, for a new series of attempts
+	Resend(actor Actor, id uint) (*models.EmailOutbox, error)
+}
+
+type outboxService struct {
+	base
+}
+
+func NewOutboxService(deps Deps) OutboxService {
+	return &outboxService{newBase(deps)}
+}
+
+func (s *outboxService) Resend(actor Actor, id uint) (*models.EmailOutbox, error) {
+	var email models.EmailOutbox
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&email, id).Error; err != nil {
+			return notFound("Email not found")
+		}
+		if email.Status != models.EmailStatusFailed {
+			return invalid("Only failed emails can be resent")
+		}
+
+		before := email
+		email.Status = models.EmailStatusPending
+		email.Attempts = 0
+		email.NextAttemptAt = time.Now()
+		if err := tx.Model(&email).Updates(map[string]interface{}{
+			"status":          email.Status,
+			"attempts":        0,
+			"next_attempt_at": email.NextAttemptAt,
+		}).Error; err != nil {
+			return internal("Failed to resend email", err)
+		}
+		return recordAudit(tx, actor, models.AuditEmailResend, models.AuditEntityEmail, email.ID, outboxAuditState(before), outboxAuditState(email))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &email, nil
+}
+
+// outboxAuditState is the part of an email recorded in the audit log, without its body
+func outboxAuditState(email models.EmailOutbox) map[string]interface{} {
+	return map[string]interface{}{
+		"recipient":  email.Recipient,
+		"template":   email.Template,
+		"status":     email.Status,
+		"attempts":   email.Attempts,
+		"last_error": email.LastError,
+	}
+}
+
+// OutboxOptions tune the outbox worker
+type OutboxOptions struct {
+	Interval    time.Duration // Time between two looks at the outbox
+	BatchSize   int           // Emails, and notifications, sent per look
+	BaseDelay   time.Duration // Delay before the first retry, doubled after each failure
+	MaxDelay    time.Duration // Longest delay between two attempts
+	MaxAttempts int           // Attempts before the email or notification is marked as failed
+	Lease       time.Duration // Time an email or notification is set aside while being sent, retried after it if the worker dies
+}
+
+// DefaultOutboxOptions retry for about a day before giving up
+var DefaultOutboxOptions = OutboxOptions{
+	Interval:    5 * time.Second,
+	BatchSize:   20,
+	BaseDelay:   30 * time.Second,
+	MaxDelay:    2 * time.Hour,
+	MaxAttempts: 15,
+	Lease:       2 * time.Minute,
+}
+
+// OutboxWorker sends the emails of the outbox and delivers the staff notifications.
+// Several workers may run at once, on one or several servers: each email and
+// delivery is claimed by a single one. Without a mailer, emails wait in the outbox.
+type OutboxWorker struct {
+	base
+	opts OutboxOptions
+}
+
+func NewOutboxWorker(deps Deps, opts OutboxOptions) *OutboxWorker {
+	return &OutboxWorker{base: newBase(deps), opts: opts}
+}
+
+// Run sends the due emails and notifications until ctx is cancelled
+func (w *OutboxWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain the outbox before waiting for the next tick
+		for {
+			attempted, err := w.ProcessDue()
+			if err != nil {
+				log.Printf("Outbox: %v", err)
+			}
+			if err != nil || attempted < w.opts.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue makes an attempt at sending the emails and notifications that are due,
+// and returns how many were attempted
+func (w *OutboxWorker) ProcessDue() (int, error) {
+	var due []models.EmailOutbox
+	if w.mailer != nil {
+		if err := w.claim(&models.EmailOutbox{}, models.EmailStatusPending, &due); err != nil {
+			return 0, err
+		}
+		for _, email := range due {
+			w.attempt(email)
+		}
+	}
+
+	var deliveries []models.NotificationDelivery
+	if err := w.claim(&models.NotificationDelivery{}, models.DeliveryStatusPending, &deliveries); err != nil {
+		return len(due), err
+	}
+	for _, delivery := range deliveries {
+		w.deliver(delivery)
+	}
+	return len(due) + len(deliveries), nil
+}
+
+// claim takes the due rows of an outbox table and sets them aside for the lease, so
+// that another worker does not process them at the same time
+func (w *OutboxWorker) claim(model interface{}, pending interface{}, due interface{}) error {
+	return w.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var ids []uint
+		if err := tx.Model(model).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", pending, now).
+			Order("next_attempt_at, id").
+			Limit(w.opts.BatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(model).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(w.opts.Lease)).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Order("id").Find(due).Error
+	})
+}
+
+// attempt sends a claimed email and records the outcome
+func (w *OutboxWorker) attempt(email models.EmailOutbox) {
+	err := w.mailer.Send(email.Recipient, emails.Message{Subject: email.Subject, HTML: email.HTML, Text: email.Text})
+
+	attempts := email.Attempts + 1
+	updates := w.outcome(attempts, err, models.EmailStatusSent, models.EmailStatusFailed, "sent_at")
+	switch {
+	case err == nil:
+	case attempts >= w.opts.MaxAttempts:
+		log.Printf("Email %d to %s failed for good after %d attempts: %v", email.ID, email.Recipient, attempts, err)
+	default:
+		log.Printf("Email %d to %s failed, attempt %d: %v", email.ID, email.Recipient, attempts, err)
+	}
+
+	if err := w.db.Model(&models.EmailOutbox{}).Where("id = ?", email.ID).Updates(updates).Error; err != nil {
+		// The lease expires and the email is sent again: better twice than never
+		log.Printf("Failed to record the outcome of email %d: %v", email.ID, err)
+	}
+}
+
+// deliver sends a claimed notification to its channel and records the outcome.
+// Notifications for a removed or disabled channel fail without an attempt.
+func (w *OutboxWorker) deliver(delivery models.NotificationDelivery) {
+	attempts := delivery.Attempts + 1
+	var channel models.NotificationChannel
+	var err error
+	if w.db.Where("is_active = ?", true).First(&channel, delivery.ChannelID).Error != nil {
+		attempts = w.opts.MaxAttempts
+		err = errors.New("channel removed or disabled")
+	} else {
+		err = w.send(channel, delivery)
+	}
+
+	updates := w.outcome(attempts, err, models.DeliveryStatusDelivered, models.DeliveryStatusFailed, "delivered_at")
+	switch {
+	case err == nil:
+	case attempts >= w.opts.MaxAttempts:
+		log.Printf("Notification %d (%s) to channel %d failed for good after %d attempts: %v", delivery.ID, delivery.Event, delivery.ChannelID, attempts, err)
+	default:
+		log.Printf("Notification %d (%s) to channel %d failed, attempt %d: %v", delivery.ID, delivery.Event, delivery.ChannelID, attempts, err)
+	}
+
+	if err := w.db.Model(&models.NotificationDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error; err != nil {
+		log.Printf("Failed to record the outcome of notification %d: %v", delivery.ID, err)
+	}
+}
+
+// send delivers a notification through the notifier of its channel
+func (w *OutboxWorker) send(channel models.NotificationChannel, delivery models.NotificationDelivery) error {
+	var n notify.Notification
+	if err := json.Unmarshal(delivery.Payload, &n); err != nil {
+		return err
+	}
+
+	var notifier notify.Notifier
+	switch channel.Type {
+	case notify.ChannelEmail:
+		if w.mailer == nil || w.templates == nil {
+			return errors.New("SMTP is not configured")
+		}
+		notifier = &notify.EmailNotifier{Mailer: w.mailer, Templates: w.templates, To: channel.Target}
+	case notify.ChannelWebhook:
+		notifier = notify.NewWebhookNotifier(channel.Target, channel.Secret)
+	default:
+		return fmt.Errorf("unknown channel type %q", channel.Type)
+	}
+	return notifier.Notify(context.Background(), n)
+}
+
+// outcome is the update recording an attempt: done, retried later, or failed for good
+// once the attempts are exhausted
+func (w *OutboxWorker) outcome(attempts int, err error, done, failed interface{}, doneAtColumn string) map[string]interface{} {
+	now := time.Now()
+	updates := map[string]interface{}{"attempts": attempts}
+	switch {
+	case err == nil:
+		updates["status"] = done
+		updates[doneAtColumn] = now
+		updates["last_error"] = ""
+	case attempts >= w.opts.MaxAttempts:
+		updates["status"] = failed
+		updates["last_error"] = err.Error()
+	default:
+		updates["next_attempt_at"] = now.Add(w.retryDelay(attempts))
+		updates["last_error"] = err.Error()
+	}
+	return updates
+}
+
+// retryDelay is the delay after the given number of failed attempts, doubled after
+// each of them
+func (w *OutboxWorker) retryDelay(failures int) time.Duration {
+	delay := w.opts.BaseDelay
+	for i := 1; i < failures && delay < w.opts.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > w.opts.MaxDelay {
+		delay = w.opts.MaxDelay
+	}
+	return delay
+}
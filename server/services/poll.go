@@ -0,0 +1,473 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PollService manages the polls the teams bet on and redistributes the stakes
+type PollService interface {
+	// List returns the polls of an event, optionally filtered by status
+	List(eventID uint, status string) ([]models.Poll, error)
+	// Get returns a poll with its votes
+	Get(id uint) (*models.Poll, error)
+	// Results counts the votes and credits staked on each option
+	Results(id uint) (*PollResults, error)
+	Create(actor Actor, req models.CreatePollRequest) (*models.Poll, error)
+	Update(actor Actor, id uint, req models.UpdatePollRequest) (*models.Poll, error)
+	Close(actor Actor, id uint) (*models.Poll, error)
+	// Cancel refunds every stake of a poll that is not resolved yet
+	Cancel(actor Actor, id uint) (*PollCancellation, error)
+	// Resolve pays out the winners. Resolving again with the same option is a no-op.
+	Resolve(actor Actor, id uint, req models.ResolvePollRequest) (*PollResolution, error)
+}
+
+// OptionResult sums the votes on a poll option
+type OptionResult struct {
+	Count        int `json:"count"`
+	TotalCredits int `json:"total_credits"`
+}
+
+// PollResults are the current results of a poll
+type PollResults struct {
+	Poll    models.Poll             `json:"poll"`
+	Results map[string]OptionResult `json:"results"`
+}
+
+// PollCancellation is the outcome of a poll cancellation
+type PollCancellation struct {
+	Poll            models.Poll
+	RefundedVotes   int
+	RefundedCredits int
+}
+
+// PollResolution is the outcome of a poll resolution
+type PollResolution struct {
+	Poll            models.Poll
+	Votes           []models.Vote
+	Pool            int
+	HouseTake       int
+	AlreadyResolved bool // The poll had already been resolved with the same option
+}
+
+type pollService struct {
+	base
+}
+
+func NewPollService(deps Deps) PollService {
+	return &pollService{newBase(deps)}
+}
+
+func (s *pollService) List(eventID uint, status string) ([]models.Poll, error) {
+	var polls []models.Poll
+	query := s.db.Where("event_id = ?", eventID)
+
+	// Filter by status if provided
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Order("start_date DESC").Find(&polls).Error; err != nil {
+		return nil, internal("Failed to fetch polls", err)
+	}
+	return polls, nil
+}
+
+func (s *pollService) Get(id uint) (*models.Poll, error) {
+	var poll models.Poll
+	if err := s.db.Preload("Votes").First(&poll, id).Error; err != nil {
+		return nil, notFound("Poll not found")
+	}
+	return &poll, nil
+}
+
+func (s *pollService) Results(id uint) (*PollResults, error) {
+	var poll models.Poll
+	if err := s.db.First(&poll, id).Error; err != nil {
+		return nil, notFound("Poll not found")
+	}
+
+	var votes []models.Vote
+	if err := s.db.Where("poll_id = ?", id).Find(&votes).Error; err != nil {
+		return nil, internal("Failed to fetch votes", err)
+	}
+
+	// Calculate results
+	results := map[string]OptionResult{}
+	for _, vote := range votes {
+		result := results[vote.ChosenOption]
+		result.Count++
+		result.TotalCredits += vote.CreditStaked
+		results[vote.ChosenOption] = result
+	}
+
+	return &PollResults{Poll: poll, Results: results}, nil
+}
+
+// normalizePollOptions trims the options and rejects empty or duplicated ones
+func normalizePollOptions(options []string) ([]string, string) {
+	normalized := make([]string, 0, len(options))
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, "Options cannot be empty"
+		}
+		if seen[option] {
+			return nil, "Options must be unique"
+		}
+		seen[option] = true
+		normalized = append(normalized, option)
+	}
+	if len(normalized) < 2 {
+		return nil, "A poll needs at least two options"
+	}
+	return normalized, ""
+}
+
+func (s *pollService) Create(actor Actor, req models.CreatePollRequest) (*models.Poll, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, invalid("Question is required")
+	}
+
+	options, msg := normalizePollOptions(req.Options)
+	if msg != "" {
+		return nil, invalid(msg)
+	}
+
+	startDate := req.StartDate
+	if startDate.IsZero() {
+		startDate = time.Now()
+	}
+	if !req.EndDate.After(startDate) {
+		return nil, invalid("End date must be after start date")
+	}
+
+	poll := models.Poll{
+		EventID:   actor.EventID,
+		Question:  question,
+		Options:   options,
+		StartDate: startDate,
+		EndDate:   req.EndDate,
+		Status:    models.PollStatusOpen,
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&poll).Error; err != nil {
+			return internal("Failed to create poll", err)
+		}
+		return recordAudit(tx, actor, models.AuditPollCreate, models.AuditEntityPoll, poll.ID, nil, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (s *pollService) Update(actor Actor, id uint, req models.UpdatePollRequest) (*models.Poll, error) {
+	var poll models.Poll
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Lock the poll so that no vote is cast while the options are checked
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&poll, id).Error; err != nil {
+			return notFound("Poll not found")
+		}
+
+		if poll.Status == models.PollStatusCancelled || poll.Status == models.PollStatusResolved {
+			return invalid("Poll can no longer be edited")
+		}
+
+		before := poll
+
+		if req.Question != nil {
+			question := strings.TrimSpace(*req.Question)
+			if question == "" {
+				return invalid("Question is required")
+			}
+			poll.Question = question
+		}
+
+		if req.Options != nil {
+			options, msg := normalizePollOptions(req.Options)
+			if msg != "" {
+				return invalid(msg)
+			}
+
+			var voteCount int64
+			if err := tx.Model(&models.Vote{}).Where("poll_id = ?", poll.ID).Count(&voteCount).Error; err != nil {
+				return internal("Failed to check votes", err)
+			}
+			if voteCount > 0 {
+				return conflict("Options cannot be changed once the poll has votes")
+			}
+
+			poll.Options = options
+		}
+
+		if req.StartDate != nil {
+			poll.StartDate = *req.StartDate
+		}
+		if req.EndDate != nil {
+			poll.EndDate = *req.EndDate
+		}
+		if !poll.EndDate.After(poll.StartDate) {
+			return invalid("End date must be after start date")
+		}
+
+		if err := tx.Save(&poll).Error; err != nil {
+			return internal("Failed to update poll", err)
+		}
+		return recordAudit(tx, actor, models.AuditPollUpdate, models.AuditEntityPoll, poll.ID, before, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (s *pollService) Close(actor Actor, id uint) (*models.Poll, error) {
+	var poll models.Poll
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Lock the poll so that the votes being cast are committed first
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&poll, id).Error; err != nil {
+			return notFound("Poll not found")
+		}
+
+		if poll.Status != models.PollStatusOpen {
+			return invalid("Poll is not open")
+		}
+
+		before := poll
+		now := time.Now()
+		poll.Status = models.PollStatusClosed
+		if poll.EndDate.After(now) {
+			poll.EndDate = now
+		}
+
+		if err := tx.Save(&poll).Error; err != nil {
+			return internal("Failed to close poll", err)
+		}
+		if err := s.notifyPollClosed(tx, poll); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditPollClose, models.AuditEntityPoll, poll.ID, before, poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &poll, nil
+}
+
+func (s *pollService) Cancel(actor Actor, id uint) (*PollCancellation, error) {
+	result := &PollCancellation{}
+	credited := map[uint]models.Team{} // Latest balance of the refunded teams
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		poll := &result.Poll
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(poll, id).Error; err != nil {
+			return notFound("Poll not found")
+		}
+
+		if poll.Status == models.PollStatusCancelled {
+			return invalid("Poll already cancelled")
+		}
+
+		// Stakes have already been redistributed
+		if poll.Status == models.PollStatusResolved {
+			return invalid("Poll already resolved")
+		}
+
+		before := *poll
+
+		var votes []models.Vote
+		if err := tx.Where("poll_id = ?", poll.ID).Find(&votes).Error; err != nil {
+			return internal("Failed to fetch votes", err)
+		}
+
+		// Refund every stake
+		for _, vote := range votes {
+			var team models.Team
+			if err := tx.First(&team, vote.TeamID).Error; err != nil {
+				return internal("Team not found", err)
+			}
+
+			if err := applyCreditChange(tx, &team, vote.CreditStaked, models.CreditTransaction{
+				Reason:  models.CreditReasonVoteRefund,
+				VoteID:  &vote.ID,
+				AdminID: actor.AdminID(),
+			}); err != nil {
+				return internal("Failed to refund credit", err)
+			}
+			result.RefundedCredits += vote.CreditStaked
+			credited[team.ID] = team
+		}
+		result.RefundedVotes = len(votes)
+
+		poll.Status = models.PollStatusCancelled
+		if err := tx.Save(poll).Error; err != nil {
+			return internal("Failed to cancel poll", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditPollCancel, models.AuditEntityPoll, poll.ID, before, *poll)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, team := range credited {
+		s.publishCreditUpdate(team)
+	}
+
+	return result, nil
+}
+
+// computePollPayouts splits the pool between the winning votes in proportion to their stake
+// (parimutuel). The house cut is taken from the whole pool and rounding leftovers stay with the house.
+// If nobody picked the winning option, every stake is refunded and no cut is taken.
+func computePollPayouts(votes []models.Vote, winningOption string, houseCutPercent int) (map[uint]int, int) {
+	pool := 0
+	winningStake := 0
+	for _, vote := range votes {
+		pool += vote.CreditStaked
+		if vote.ChosenOption == winningOption {
+			winningStake += vote.CreditStaked
+		}
+	}
+
+	payouts := make(map[uint]int, len(votes))
+	if winningStake == 0 {
+		for _, vote := range votes {
+			payouts[vote.ID] = vote.CreditStaked
+		}
+		return payouts, 0
+	}
+
+	distributable := pool - pool*houseCutPercent/100
+	paid := 0
+	for _, vote := range votes {
+		payout := 0
+		if vote.ChosenOption == winningOption {
+			payout = int(int64(distributable) * int64(vote.CreditStaked) / int64(winningStake))
+		}
+		payouts[vote.ID] = payout
+		paid += payout
+	}
+
+	return payouts, pool - paid
+}
+
+func (s *pollService) Resolve(actor Actor, id uint, req models.ResolvePollRequest) (*PollResolution, error) {
+	result := &PollResolution{}
+	credited := map[uint]models.Team{} // Latest balance of the teams paid out
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Lock the poll so that concurrent resolutions are serialized
+		poll := &result.Poll
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(poll, id).Error; err != nil {
+			return notFound("Poll not found")
+		}
+
+		if poll.Status == models.PollStatusCancelled {
+			return invalid("Poll is cancelled")
+		}
+
+		if err := tx.Preload("Team").Where("poll_id = ?", poll.ID).Find(&result.Votes).Error; err != nil {
+			return internal("Failed to fetch votes", err)
+		}
+
+		// Already resolved: same answer is a no-op, a different one is a conflict
+		if poll.Status == models.PollStatusResolved {
+			if poll.WinningOption == nil || *poll.WinningOption != req.WinningOption {
+				return conflict("Poll already resolved with another option")
+			}
+			result.AlreadyResolved = true
+			return nil
+		}
+
+		if !poll.HasOption(req.WinningOption) {
+			return invalid("Invalid option")
+		}
+
+		before := *poll
+		payouts, houseTake := computePollPayouts(result.Votes, req.WinningOption, req.HouseCutPercent)
+		result.HouseTake = houseTake
+
+		for i := range result.Votes {
+			vote := &result.Votes[i]
+			payout := payouts[vote.ID]
+			vote.Payout = &payout
+			if err := tx.Model(vote).Update("payout", payout).Error; err != nil {
+				return internal("Failed to update vote", err)
+			}
+
+			if payout == 0 {
+				continue
+			}
+
+			var team models.Team
+			if err := tx.First(&team, vote.TeamID).Error; err != nil {
+				return internal("Team not found", err)
+			}
+
+			if err := applyCreditChange(tx, &team, payout, models.CreditTransaction{
+				Reason:  models.CreditReasonVotePayout,
+				VoteID:  &vote.ID,
+				AdminID: actor.AdminID(),
+			}); err != nil {
+				return internal("Failed to pay out credit", err)
+			}
+			credited[team.ID] = team
+		}
+
+		now := time.Now()
+		winningOption := req.WinningOption
+		poll.Status = models.PollStatusResolved
+		poll.WinningOption = &winningOption
+		poll.HouseCutPercent = req.HouseCutPercent
+		poll.ResolvedAt = &now
+		if poll.EndDate.After(now) {
+			poll.EndDate = now
+		}
+		if err := tx.Save(poll).Error; err != nil {
+			return internal("Failed to resolve poll", err)
+		}
+
+		if err := recordAudit(tx, actor, models.AuditPollResolve, models.AuditEntityPoll, poll.ID, before, *poll); err != nil {
+			return err
+		}
+
+		// Notify every team of its result
+		for _, vote := range result.Votes {
+			if err := s.queueEmail(tx, vote.Team, emails.PollPayout, emails.PollPayoutData{
+				Team:          vote.Team.Name,
+				Question:      poll.Question,
+				WinningOption: req.WinningOption,
+				Staked:        vote.CreditStaked,
+				Payout:        *vote.Payout,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.AlreadyResolved {
+		return result, nil
+	}
+
+	for _, team := range credited {
+		s.publishCreditUpdate(team)
+	}
+	for _, vote := range result.Votes {
+		result.Pool += vote.CreditStaked
+	}
+
+	return result, nil
+}
@@ -0,0 +1,678 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxPurchaseCommentLength limits the comment of a batch purchase
+const maxPurchaseCommentLength = 3000
+
+// PurchaseService handles the purchase requests of the teams and their approval
+type PurchaseService interface {
+	// Create requests a resource: the units are reserved and the team is debited
+	Create(teamID uint, req models.PurchaseRequest) (*models.Purchase, error)
+	// CreateBatch requests several resources at once, all or nothing
+	CreateBatch(teamID uint, req models.BatchPurchaseRequest) ([]models.Purchase, error)
+	// List returns one page of purchases and the total number of matching purchases
+	List(q PurchaseQuery, preloads ...string) ([]models.Purchase, int64, error)
+	// Page returns one page of purchases, without counting them
+	Page(q PurchaseQuery, preloads ...string) ([]models.Purchase, error)
+	// Export passes every matching purchase to fn, page by page, as of the start of
+	// the export
+	Export(q PurchaseQuery, fn func([]models.Purchase) error, preloads ...string) error
+	// Decide confirms or cancels a pending purchase
+	Decide(actor Actor, id uint, action string) (*models.Purchase, error)
+	// DecideBatch processes several pending purchases, each in its own transaction,
+	// with optional partial approvals
+	DecideBatch(actor Actor, items []models.PurchaseItemAction) []BatchActionResult
+	// Return lets a team give back a confirmed purchase, without refund
+	Return(teamID, id uint) (*models.Purchase, error)
+	MarkReturned(actor Actor, id uint) (*models.Purchase, error)
+	UnmarkReturned(actor Actor, id uint) (*models.Purchase, error)
+}
+
+// BatchActionResult is the outcome of one item of a batch approval
+type BatchActionResult struct {
+	PurchaseID uint   `json:"purchase_id"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Quantity   int    `json:"quantity,omitempty"`
+}
+
+type purchaseService struct {
+	base
+}
+
+func NewPurchaseService(deps Deps) PurchaseService {
+	return &purchaseService{newBase(deps)}
+}
+
+func (s *purchaseService) Create(teamID uint, req models.PurchaseRequest) (*models.Purchase, error) {
+	var team models.Team
+	var purchase models.Purchase
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Get team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return notFound("Team not found")
+		}
+
+		// Get resource, from the event of the team
+		var resource models.Resource
+		if err := tx.Where("event_id = ?", team.EventID).First(&resource, req.ResourceID).Error; err != nil {
+			return notFound("Resource not found")
+		}
+
+		// Check if resource is active
+		if !resource.IsActive {
+			return invalid("Resource is not available")
+		}
+
+		// Check quantity available (units reserved by pending purchases are not available)
+		if resource.AvailableQuantity() < req.Quantity {
+			return invalid("Insufficient quantity available")
+		}
+
+		// Check max per team and quota rules
+		violations, err := checkQuotas(tx, teamID, []quotaItem{{resource: resource, quantity: req.Quantity}})
+		if err != nil {
+			return internal("Failed to check quotas", err)
+		}
+		if len(violations) > 0 {
+			return quotaExceeded(violations)
+		}
+
+		// Check team credit
+		totalCost := resource.Cost * req.Quantity
+		if team.Credit < totalCost {
+			return invalid("Insufficient credit")
+		}
+
+		// Create purchase
+		purchase = models.Purchase{
+			EventID:           team.EventID,
+			TeamID:            teamID,
+			ResourceID:        req.ResourceID,
+			Quantity:          req.Quantity,
+			RequestedQuantity: req.Quantity,
+			UnitCost:          resource.Cost,
+			PurchaseDate:      time.Now(),
+			Status:            models.StatusPending,
+			IsReturned:        false,
+			NeedsReturn:       !resource.IsNonReturnable,
+		}
+		if err := tx.Create(&purchase).Error; err != nil {
+			return internal("Failed to create purchase", err)
+		}
+
+		// Hold the units until the purchase is confirmed or cancelled
+		if err := reserveStock(tx, resource.ID, purchase.Quantity); err != nil {
+			if err == errInsufficientStock {
+				return invalid("Insufficient quantity available")
+			}
+			return internal("Failed to reserve stock", err)
+		}
+		if err := s.notifyLowStock(tx, resource.ID, purchase.Quantity); err != nil {
+			return err
+		}
+
+		// Deduct credit
+		if err := debitPurchase(tx, &team, totalCost, purchase.ID); err != nil {
+			return err
+		}
+
+		// Send purchase creation email (pending status) and tell the staff
+		purchase.Resource = resource
+		if err := s.notifyPurchasePending(tx, team, purchase); err != nil {
+			return err
+		}
+		return s.queueEmail(tx, team, emails.PurchaseReceived, emails.PurchaseData{
+			Team:  team.Name,
+			Items: purchaseItems(purchase),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Load relations for response
+	s.db.Preload("Resource").Preload("Team").First(&purchase, purchase.ID)
+
+	s.publishPurchaseEvent(events.PurchaseCreated, purchase)
+	s.publishCreditUpdate(team)
+
+	return &purchase, nil
+}
+
+// debitPurchase takes the cost of a purchase from the team credit
+func debitPurchase(tx *gorm.DB, team *models.Team, cost int, purchaseID uint) error {
+	if err := applyCreditChange(tx, team, -cost, models.CreditTransaction{
+		Reason:     models.CreditReasonPurchase,
+		PurchaseID: &purchaseID,
+	}); err != nil {
+		if err == errInsufficientCredit {
+			return invalid("Insufficient credit")
+		}
+		return internal("Failed to update credit", err)
+	}
+	return nil
+}
+
+func (s *purchaseService) CreateBatch(teamID uint, req models.BatchPurchaseRequest) ([]models.Purchase, error) {
+	// Assure the comment is not too long
+	if len(req.Comment) > maxPurchaseCommentLength {
+		return nil, invalid("Comment is too long")
+	}
+
+	var team models.Team
+	purchases := make([]models.Purchase, 0, len(req.Items))
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Get team
+		if err := tx.First(&team, teamID).Error; err != nil {
+			return notFound("Team not found")
+		}
+
+		// Validate all items first and calculate total cost
+		totalCost := 0
+		items := make([]quotaItem, 0, len(req.Items))
+		for _, item := range req.Items {
+			var resource models.Resource
+			if err := tx.Where("event_id = ?", team.EventID).First(&resource, item.ResourceID).Error; err != nil {
+				return notFound("Resource not found").With("resource_id", item.ResourceID)
+			}
+			if !resource.IsActive {
+				return invalid("Resource is not available").With("resource", resource.Name)
+			}
+			if resource.AvailableQuantity() < item.Quantity {
+				return invalid("Insufficient quantity available").With("resource", resource.Name)
+			}
+
+			totalCost += resource.Cost * item.Quantity
+			items = append(items, quotaItem{resource: resource, quantity: item.Quantity})
+		}
+
+		// Check max per team and quota rules over the whole cart
+		violations, err := checkQuotas(tx, teamID, items)
+		if err != nil {
+			return internal("Failed to check quotas", err)
+		}
+		if len(violations) > 0 {
+			return quotaExceeded(violations)
+		}
+
+		// Check team credit
+		if team.Credit < totalCost {
+			return invalid("Insufficient credit")
+		}
+
+		// Generate unique batch ID for this purchase group
+		batchID := fmt.Sprintf("%s-%d", time.Now().Format("20060102150405"), teamID)
+
+		// Create purchases with batch_id and comment
+		for _, item := range items {
+			purchase := models.Purchase{
+				EventID:           team.EventID,
+				BatchID:           &batchID,
+				TeamID:            teamID,
+				ResourceID:        item.resource.ID,
+				Quantity:          item.quantity,
+				RequestedQuantity: item.quantity,
+				UnitCost:          item.resource.Cost,
+				Comment:           req.Comment,
+				PurchaseDate:      time.Now(),
+				Status:            models.StatusPending,
+				IsReturned:        false,
+				NeedsReturn:       !item.resource.IsNonReturnable, // Set needs_return based on resource type
+			}
+			if err := tx.Create(&purchase).Error; err != nil {
+				return internal("Failed to create purchase", err)
+			}
+
+			// Hold the units until the purchase is confirmed or cancelled
+			if err := reserveStock(tx, item.resource.ID, item.quantity); err != nil {
+				if err == errInsufficientStock {
+					return invalid("Insufficient quantity available").With("resource", item.resource.Name)
+				}
+				return internal("Failed to reserve stock", err)
+			}
+			if err := s.notifyLowStock(tx, item.resource.ID, item.quantity); err != nil {
+				return err
+			}
+
+			// Deduct credit, one ledger entry per purchased item
+			if err := debitPurchase(tx, &team, purchase.TotalCost(), purchase.ID); err != nil {
+				return err
+			}
+
+			purchase.Resource = item.resource
+			purchases = append(purchases, purchase)
+		}
+
+		// Send batch purchase creation email and tell the staff
+		if err := s.notifyPurchasePending(tx, team, purchases...); err != nil {
+			return err
+		}
+		return s.queueEmail(tx, team, emails.PurchaseReceived, emails.PurchaseData{
+			Team:  team.Name,
+			Items: purchaseItems(purchases...),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Load relations for response
+	for i := range purchases {
+		s.db.Preload("Resource").Preload("Team").First(&purchases[i], purchases[i].ID)
+		s.publishPurchaseEvent(events.PurchaseCreated, purchases[i])
+	}
+	s.publishCreditUpdate(team)
+
+	return purchases, nil
+}
+
+func (s *purchaseService) Decide(actor Actor, id uint, action string) (*models.Purchase, error) {
+	var purchase models.Purchase
+	var team models.Team // Reloaded with its new balance when the purchase is refunded
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Locked until the purchase is processed, so that a concurrent decision waits and
+		// then sees its new status
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Team").Preload("Resource").First(&purchase, id).Error; err != nil {
+			return notFound("Purchase not found")
+		}
+		if purchase.Status != models.StatusPending {
+			return invalid("Purchase already processed")
+		}
+
+		before := purchase
+		auditAction := models.AuditPurchaseConfirm
+
+		switch action {
+		case "confirm":
+			// Turn the reserved units into stock leaving the shop
+			if err := consumeReservation(tx, purchase.ResourceID, purchase.Quantity, purchase.Quantity); err != nil {
+				if err == errInsufficientStock {
+					return invalid("Insufficient stock")
+				}
+				return internal("Failed to update resource", err)
+			}
+			if err := setReturnDeadline(tx, &purchase, time.Now()); err != nil {
+				return err
+			}
+			purchase.Status = models.StatusConfirmed
+
+		case "cancel":
+			// Release the reserved units
+			if err := releaseStock(tx, purchase.ResourceID, purchase.Quantity); err != nil {
+				return internal("Failed to update resource", err)
+			}
+
+			// Refund credit
+			if err := tx.First(&team, purchase.TeamID).Error; err != nil {
+				return internal("Team not found", err)
+			}
+			refund := purchase.TotalCost()
+			if err := applyCreditChange(tx, &team, refund, models.CreditTransaction{
+				Reason:     models.CreditReasonPurchaseRefund,
+				PurchaseID: &purchase.ID,
+				AdminID:    actor.AdminID(),
+			}); err != nil {
+				return internal("Failed to refund credit", err)
+			}
+			purchase.Status = models.StatusCancelled
+			auditAction = models.AuditPurchaseCancel
+
+		default:
+			return invalid("Invalid action (expected confirm or cancel)")
+		}
+
+		if err := tx.Save(&purchase).Error; err != nil {
+			return internal("Failed to update purchase", err)
+		}
+		if err := recordAudit(tx, actor, auditAction, models.AuditEntityPurchase, purchase.ID, before, purchase); err != nil {
+			return err
+		}
+
+		template := emails.PurchaseConfirmed
+		if purchase.Status == models.StatusCancelled {
+			template = emails.PurchaseRejected
+		}
+		return s.queueEmail(tx, purchase.Team, template, emails.PurchaseData{
+			Team:  purchase.Team.Name,
+			Items: purchaseItems(purchase),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if purchase.Status == models.StatusConfirmed {
+		s.publishPurchaseEvent(events.PurchaseConfirmed, purchase)
+	} else {
+		s.publishPurchaseEvent(events.PurchaseCancelled, purchase)
+		s.publishCreditUpdate(team)
+	}
+
+	return &purchase, nil
+}
+
+// batchTeamSummary collects the processed purchases of a team for the summary email
+type batchTeamSummary struct {
+	team      models.Team
+	confirmed []models.Purchase
+	adjusted  []models.Purchase
+	cancelled []models.Purchase
+}
+
+func (s *purchaseService) DecideBatch(actor Actor, items []models.PurchaseItemAction) []BatchActionResult {
+	results := make([]BatchActionResult, 0, len(items))
+
+	// Track purchases by team for email summary
+	summaries := map[uint]*batchTeamSummary{}
+	teamOrder := []uint{}
+
+	for _, item := range items {
+		purchase, team, err := s.decideItem(actor, item)
+		if err != nil {
+			results = append(results, BatchActionResult{
+				PurchaseID: item.PurchaseID,
+				Success:    false,
+				Error:      err.Message,
+			})
+			continue
+		}
+
+		switch {
+		case purchase.Status == models.StatusCancelled:
+			s.publishPurchaseEvent(events.PurchaseCancelled, purchase)
+		case purchase.Quantity != purchase.RequestedQuantity:
+			s.publishPurchaseEvent(events.PurchaseAdjusted, purchase)
+		default:
+			s.publishPurchaseEvent(events.PurchaseConfirmed, purchase)
+		}
+		if team.ID != 0 {
+			s.publishCreditUpdate(team)
+		}
+
+		// Track successful purchases for email summary
+		summary, exists := summaries[purchase.TeamID]
+		if !exists {
+			summary = &batchTeamSummary{team: purchase.Team}
+			summaries[purchase.TeamID] = summary
+			teamOrder = append(teamOrder, purchase.TeamID)
+		}
+		switch {
+		case item.Action == "cancel":
+			summary.cancelled = append(summary.cancelled, purchase)
+		case item.ApprovedQuantity != nil && *item.ApprovedQuantity != purchase.RequestedQuantity:
+			// Partial approval
+			summary.adjusted = append(summary.adjusted, purchase)
+		default:
+			// Full approval
+			summary.confirmed = append(summary.confirmed, purchase)
+		}
+
+		results = append(results, BatchActionResult{
+			PurchaseID: item.PurchaseID,
+			Success:    true,
+			Status:     string(purchase.Status),
+			Quantity:   purchase.Quantity,
+		})
+	}
+
+	// Send summary email for each team
+	for _, teamID := range teamOrder {
+		s.sendBatchSummary(summaries[teamID])
+	}
+
+	return results
+}
+
+// decideItem processes one item of a batch approval in its own transaction. The team
+// is returned with its new balance when credit was refunded.
+func (s *purchaseService) decideItem(actor Actor, item models.PurchaseItemAction) (models.Purchase, models.Team, *Error) {
+	var purchase models.Purchase
+	var team models.Team
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Team").Preload("Resource").First(&purchase, item.PurchaseID).Error; err != nil {
+			return notFound("Purchase not found")
+		}
+		if purchase.Status != models.StatusPending {
+			return invalid("Purchase already processed")
+		}
+
+		before := purchase
+		heldQty := purchase.Quantity // Units reserved when the purchase was created
+		auditAction := models.AuditPurchaseConfirm
+
+		switch item.Action {
+		case "confirm":
+			// Check if quantity adjustment is requested
+			if item.ApprovedQuantity != nil && *item.ApprovedQuantity != purchase.Quantity {
+				approvedQty := *item.ApprovedQuantity
+
+				// Validate approved quantity
+				if approvedQty < 1 || approvedQty > purchase.RequestedQuantity {
+					return invalid("Invalid approved quantity")
+				}
+
+				// Refund the credit difference when reducing quantity
+				if approvedQty < purchase.Quantity {
+					creditDiff := (purchase.Quantity - approvedQty) * purchase.UnitCost
+					if err := tx.First(&team, purchase.TeamID).Error; err != nil {
+						return internal("Team not found", err)
+					}
+					if err := applyCreditChange(tx, &team, creditDiff, models.CreditTransaction{
+						Reason:     models.CreditReasonPurchaseRefund,
+						PurchaseID: &purchase.ID,
+						AdminID:    actor.AdminID(),
+						Note:       fmt.Sprintf("Quantité approuvée : %d sur %d", approvedQty, purchase.Quantity),
+					}); err != nil {
+						return internal("Failed to refund credit difference", err)
+					}
+				}
+
+				// Update purchase quantity
+				purchase.Quantity = approvedQty
+			}
+
+			// Release the reservation and deduct the approved units from stock
+			if err := consumeReservation(tx, purchase.ResourceID, heldQty, purchase.Quantity); err != nil {
+				if err == errInsufficientStock {
+					return invalid("Insufficient stock")
+				}
+				return internal("Failed to update resource stock", err)
+			}
+			if err := setReturnDeadline(tx, &purchase, time.Now()); err != nil {
+				return err
+			}
+			purchase.Status = models.StatusConfirmed
+
+		case "cancel":
+			// Release the reserved units
+			if err := releaseStock(tx, purchase.ResourceID, heldQty); err != nil {
+				return internal("Failed to update resource stock", err)
+			}
+
+			// Refund full amount
+			if err := tx.First(&team, purchase.TeamID).Error; err != nil {
+				return internal("Team not found", err)
+			}
+			refund := purchase.TotalCost()
+			if err := applyCreditChange(tx, &team, refund, models.CreditTransaction{
+				Reason:     models.CreditReasonPurchaseRefund,
+				PurchaseID: &purchase.ID,
+				AdminID:    actor.AdminID(),
+			}); err != nil {
+				return internal("Failed to refund credit", err)
+			}
+			purchase.Status = models.StatusCancelled
+			auditAction = models.AuditPurchaseCancel
+
+		default:
+			return invalid("Invalid action (expected confirm or cancel)")
+		}
+
+		if err := tx.Save(&purchase).Error; err != nil {
+			return internal("Failed to update purchase", err)
+		}
+		return recordAudit(tx, actor, auditAction, models.AuditEntityPurchase, purchase.ID, before, purchase)
+	})
+	if err != nil {
+		return purchase, team, err.(*Error)
+	}
+	return purchase, team, nil
+}
+
+// sendBatchSummary queues the email telling a team the outcome of its processed
+// purchases. The summary covers the transactions of several items, so it is queued
+// once they are all committed.
+func (s *purchaseService) sendBatchSummary(summary *batchTeamSummary) {
+	if len(summary.confirmed) == 0 && len(summary.adjusted) == 0 && len(summary.cancelled) == 0 {
+		return
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		return s.queueEmail(tx, summary.team, emails.PurchaseProcessed, emails.PurchaseSummaryData{
+			Team:      summary.team.Name,
+			Confirmed: purchaseItems(summary.confirmed...),
+			Adjusted:  purchaseItems(summary.adjusted...),
+			Cancelled: purchaseItems(summary.cancelled...),
+		})
+	})
+	if err != nil {
+		log.Printf("Failed to queue the order summary of team %d: %v", summary.team.ID, err)
+	}
+}
+
+func (s *purchaseService) Return(teamID, id uint) (*models.Purchase, error) {
+	var purchase models.Purchase
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Resource").First(&purchase, id).Error; err != nil {
+			return notFound("Purchase not found")
+		}
+
+		// Check ownership
+		if purchase.TeamID != teamID {
+			return forbidden("Not authorized")
+		}
+		if purchase.IsReturned {
+			return invalid("Purchase already returned")
+		}
+		if purchase.Status != models.StatusConfirmed {
+			return invalid("Can only return confirmed purchases")
+		}
+		if purchase.Resource.IsNonReturnable {
+			return invalid("This resource is non-returnable")
+		}
+
+		// Mark as returned (NO REFUND - just marks the item as physically returned)
+		purchase.IsReturned = true
+		if err := tx.Save(&purchase).Error; err != nil {
+			return internal("Failed to mark as returned", err)
+		}
+
+		// Restore resource quantity
+		if err := restockResource(tx, purchase.ResourceID, purchase.Quantity); err != nil {
+			return internal("Failed to restore resource", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishPurchaseEvent(events.PurchaseReturned, purchase)
+
+	return &purchase, nil
+}
+
+func (s *purchaseService) MarkReturned(actor Actor, id uint) (*models.Purchase, error) {
+	var purchase models.Purchase
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Resource").Preload("Team").First(&purchase, id).Error; err != nil {
+			return notFound("Purchase not found")
+		}
+		if purchase.Status != models.StatusConfirmed {
+			return invalid("Only confirmed purchases can be marked as returned")
+		}
+		if purchase.IsReturned {
+			return invalid("Purchase already marked as returned")
+		}
+
+		before := purchase
+		purchase.IsReturned = true
+		if err := tx.Save(&purchase).Error; err != nil {
+			return internal("Failed to update purchase", err)
+		}
+
+		// Returned units are back in stock
+		if err := restockResource(tx, purchase.ResourceID, purchase.Quantity); err != nil {
+			return internal("Failed to restore resource", err)
+		}
+
+		if err := recordAudit(tx, actor, models.AuditPurchaseMarkReturned, models.AuditEntityPurchase, purchase.ID, before, purchase); err != nil {
+			return err
+		}
+
+		// Send return confirmation email to user
+		return s.queueEmail(tx, purchase.Team, emails.PurchaseReturned, emails.PurchaseData{
+			Team:  purchase.Team.Name,
+			Items: purchaseItems(purchase),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publishPurchaseEvent(events.PurchaseReturned, purchase)
+
+	return &purchase, nil
+}
+
+func (s *purchaseService) UnmarkReturned(actor Actor, id uint) (*models.Purchase, error) {
+	var purchase models.Purchase
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&purchase, id).Error; err != nil {
+			return notFound("Purchase not found")
+		}
+		if !purchase.IsReturned {
+			return invalid("Purchase is not marked as returned")
+		}
+
+		before := purchase
+		purchase.IsReturned = false
+		if err := tx.Save(&purchase).Error; err != nil {
+			return internal("Failed to update purchase", err)
+		}
+
+		// The units were not actually returned, take them out of stock again
+		if err := unstockResource(tx, purchase.ResourceID, purchase.Quantity); err != nil {
+			if err == errInsufficientStock {
+				return conflict("Returned units are already reserved by pending purchases")
+			}
+			return internal("Failed to update resource", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditPurchaseUnmarkReturned, models.AuditEntityPurchase, purchase.ID, before, purchase)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &purchase, nil
+}
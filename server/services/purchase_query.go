@@ -0,0 +1,155 @@
+package services
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+// purchaseSortColumns maps the accepted sort keys to their SQL expression
+var purchaseSortColumns = map[string]string{
+	"date":     "purchases.purchase_date",
+	"cost":     "purchases.unit_cost * purchases.quantity",
+	"team":     "teams.name",
+	"quantity": "purchases.quantity",
+	"status":   "purchases.status",
+	"due":      "purchases.return_due_at",
+}
+
+// PurchaseQuery holds the filters, sort and page of a purchase listing. It is shared
+// by the admin and team listings; the admin listing always sets EventID and the team
+// listing TeamID.
+type PurchaseQuery struct {
+	EventID      uint // Any event when 0
+	TeamID       string
+	ResourceID   string
+	ResourceType string
+	BatchID      string
+	Status       string
+	NeedsReturn  bool
+	Overdue      bool // Rentals still out after their return deadline
+	From         *time.Time
+	To           *time.Time
+	Search       string // Free text searched in the comment
+	Sort         string // date, cost, team, quantity, status or due
+	Desc         bool
+	Limit        int
+	Offset       int
+}
+
+// IsValidPurchaseSort tells whether a sort key is accepted by purchase listings
+func IsValidPurchaseSort(sort string) bool {
+	_, ok := purchaseSortColumns[sort]
+	return ok
+}
+
+// filter builds the filtered purchase query, joined with the resource and team so
+// that they can be used in filters and sorts
+func (q PurchaseQuery) filter(db *gorm.DB) *gorm.DB {
+	query := db.Model(&models.Purchase{}).
+		Joins("LEFT JOIN resources ON resources.id = purchases.resource_id").
+		Joins("LEFT JOIN teams ON teams.id = purchases.team_id")
+
+	if q.EventID != 0 {
+		query = query.Where("purchases.event_id = ?", q.EventID)
+	}
+	if q.TeamID != "" {
+		query = query.Where("purchases.team_id = ?", q.TeamID)
+	}
+	if q.ResourceID != "" {
+		query = query.Where("purchases.resource_id = ?", q.ResourceID)
+	}
+	if q.ResourceType != "" {
+		query = query.Where("resources.type = ?", q.ResourceType)
+	}
+	if q.BatchID != "" {
+		query = query.Where("purchases.batch_id = ?", q.BatchID)
+	}
+	if q.Status != "" {
+		query = query.Where("purchases.status = ?", q.Status)
+	}
+	if q.NeedsReturn {
+		query = query.Where("purchases.needs_return = ? AND purchases.status = ? AND purchases.is_returned = ?", true, models.StatusConfirmed, false)
+	}
+	if q.Overdue {
+		query = query.Where("purchases.needs_return = ? AND purchases.status = ? AND purchases.is_returned = ? AND purchases.return_due_at < ?", true, models.StatusConfirmed, false, time.Now())
+	}
+	if q.From != nil {
+		query = query.Where("purchases.purchase_date >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("purchases.purchase_date <= ?", *q.To)
+	}
+	if q.Search != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q.Search)) + "%"
+		query = query.Where(`LOWER(purchases.comment) LIKE ? ESCAPE '\'`, pattern)
+	}
+
+	return query
+}
+
+// List returns one page of purchases and the total number of matching purchases
+func (s *purchaseService) List(q PurchaseQuery, preloads ...string) ([]models.Purchase, int64, error) {
+	var total int64
+	if err := q.filter(s.db).Count(&total).Error; err != nil {
+		return nil, 0, internal("Failed to fetch purchases", err)
+	}
+
+	purchases, err := s.Page(q, preloads...)
+	return purchases, total, err
+}
+
+// Page returns the purchases between Offset and Offset+Limit in the requested order
+func (s *purchaseService) Page(q PurchaseQuery, preloads ...string) ([]models.Purchase, error) {
+	return q.page(s.db, preloads...)
+}
+
+// Export passes every matching purchase to fn, Limit at a time. The pages are read
+// from a single snapshot of the database, so that the purchases created or changed
+// during a long export are neither repeated nor skipped.
+func (s *purchaseService) Export(q PurchaseQuery, fn func([]models.Purchase) error, preloads ...string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for q.Offset = 0; ; {
+			purchases, err := q.page(tx, preloads...)
+			if err != nil || len(purchases) == 0 {
+				return err
+			}
+			if err := fn(purchases); err != nil {
+				return err
+			}
+			q.Offset += len(purchases)
+		}
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+}
+
+// page loads the purchases between Offset and Offset+Limit in the requested order
+func (q PurchaseQuery) page(db *gorm.DB, preloads ...string) ([]models.Purchase, error) {
+	direction := "ASC"
+	if q.Desc {
+		direction = "DESC"
+	}
+	query := q.filter(db)
+	for _, preload := range preloads {
+		query = query.Preload(preload)
+	}
+
+	purchases := []models.Purchase{}
+	err := query.
+		Order(purchaseSortColumns[q.Sort] + " " + direction).
+		Order("purchases.id " + direction). // Stable order between pages
+		Limit(q.Limit).
+		Offset(q.Offset).
+		Find(&purchases).Error
+	if err != nil {
+		return nil, internal("Failed to fetch purchases", err)
+	}
+	return purchases, nil
+}
+
+// escapeLike escapes the LIKE wildcards of a user search
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
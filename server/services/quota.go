@@ -0,0 +1,311 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// quotaItem is one line of a purchase request checked against the quotas
+type quotaItem struct {
+	resource models.Resource
+	quantity int
+}
+
+// quotaCountedStatuses are the purchases that use up a quota: pending requests
+// count so that a team cannot queue more than it is allowed to get
+var quotaCountedStatuses = []models.PurchaseStatus{models.StatusPending, models.StatusConfirmed}
+
+// checkQuotas evaluates the resources max per team and the active quota rules for
+// a purchase request. Quantities are summed over the request items and the team's
+// pending and confirmed purchases. The team row is locked so that concurrent
+// requests of the same team are evaluated one after the other.
+func checkQuotas(tx *gorm.DB, teamID uint, items []quotaItem) ([]models.QuotaViolation, error) {
+	var team models.Team
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, teamID).Error; err != nil {
+		return nil, err
+	}
+
+	// Requested quantities per resource and per type
+	resources := map[uint]models.Resource{}
+	resourceOrder := []uint{}
+	requestedByResource := map[uint]int{}
+	requestedByType := map[string]int{}
+	for _, item := range items {
+		if _, ok := resources[item.resource.ID]; !ok {
+			resources[item.resource.ID] = item.resource
+			resourceOrder = append(resourceOrder, item.resource.ID)
+		}
+		requestedByResource[item.resource.ID] += item.quantity
+		requestedByType[item.resource.Type] += item.quantity
+	}
+
+	violations := []models.QuotaViolation{}
+
+	// Max per team of each resource, over the whole event
+	for _, id := range resourceOrder {
+		resource := resources[id]
+		used, err := usedQuota(tx, teamID, models.QuotaScopeResource, id, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		requested := requestedByResource[id]
+		if used+requested > resource.MaxPerTeam {
+			resourceID := id
+			violations = append(violations, models.QuotaViolation{
+				Rule:       "max_per_team",
+				Scope:      models.QuotaScopeResource,
+				ResourceID: &resourceID,
+				Limit:      resource.MaxPerTeam,
+				Used:       used,
+				Requested:  requested,
+				Message:    fmt.Sprintf("Ressource %s : dépassement de la quantité maximale par équipe (%d déjà demandés, maximum %d)", resource.Name, used, resource.MaxPerTeam),
+			})
+		}
+	}
+
+	// Quota rules matching the requested resources or their types
+	types := make([]string, 0, len(requestedByType))
+	for t := range requestedByType {
+		types = append(types, t)
+	}
+	var rules []models.QuotaRule
+	if err := tx.Where("event_id = ? AND is_active = ?", team.EventID, true).
+		Where(tx.Where("scope = ? AND resource_id IN ?", models.QuotaScopeResource, resourceOrder).
+			Or("scope = ? AND resource_type IN ?", models.QuotaScopeType, types)).
+		Order("id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		var requested int
+		var resourceID uint
+		if rule.Scope == models.QuotaScopeResource {
+			resourceID = *rule.ResourceID
+			requested = requestedByResource[resourceID]
+		} else {
+			requested = requestedByType[rule.ResourceType]
+		}
+
+		used, err := usedQuota(tx, teamID, rule.Scope, resourceID, rule.ResourceType, rule.WindowMinutes)
+		if err != nil {
+			return nil, err
+		}
+		if used+requested <= rule.MaxQuantity {
+			continue
+		}
+
+		ruleID := rule.ID
+		violation := models.QuotaViolation{
+			RuleID:        &ruleID,
+			Rule:          rule.Name,
+			Scope:         rule.Scope,
+			ResourceID:    rule.ResourceID,
+			ResourceType:  rule.ResourceType,
+			Limit:         rule.MaxQuantity,
+			Used:          used,
+			Requested:     requested,
+			WindowMinutes: rule.WindowMinutes,
+		}
+		period := ""
+		if rule.WindowMinutes > 0 {
+			period = fmt.Sprintf(" sur %d minutes", rule.WindowMinutes)
+		}
+		violation.Message = fmt.Sprintf("%s : limite de %d%s dépassée (%d déjà demandés)", rule.Name, rule.MaxQuantity, period, used)
+		violations = append(violations, violation)
+	}
+
+	return violations, nil
+}
+
+// usedQuota sums the quantities of the team's pending and confirmed purchases for a
+// resource or a resource type, optionally restricted to the last windowMinutes
+func usedQuota(tx *gorm.DB, teamID uint, scope models.QuotaScope, resourceID uint, resourceType string, windowMinutes int) (int, error) {
+	query := tx.Model(&models.Purchase{}).
+		Where("purchases.team_id = ? AND purchases.status IN ?", teamID, quotaCountedStatuses)
+
+	if scope == models.QuotaScopeResource {
+		query = query.Where("purchases.resource_id = ?", resourceID)
+	} else {
+		query = query.Joins("JOIN resources ON resources.id = purchases.resource_id").
+			Where("resources.type = ?", resourceType)
+	}
+
+	if windowMinutes > 0 {
+		query = query.Where("purchases.purchase_date >= ?", time.Now().Add(-time.Duration(windowMinutes)*time.Minute))
+	}
+
+	var used int
+	if err := query.Select("COALESCE(SUM(purchases.quantity), 0)").Scan(&used).Error; err != nil {
+		return 0, err
+	}
+	return used, nil
+}
+
+// quotaExceeded rejects a purchase request that exceeds its quotas
+func quotaExceeded(violations []models.QuotaViolation) *Error {
+	return invalid(violations[0].Message).
+		With("code", "quota_exceeded").
+		With("violations", violations)
+}
+
+// validateQuotaRule checks the business rules shared by quota rule creation and update
+func validateQuotaRule(db *gorm.DB, rule *models.QuotaRule) string {
+	if strings.TrimSpace(rule.Name) == "" {
+		return "Name is required"
+	}
+	if rule.MaxQuantity < 0 {
+		return "Max quantity must be positive"
+	}
+	if rule.WindowMinutes < 0 {
+		return "Window must be positive"
+	}
+	switch rule.Scope {
+	case models.QuotaScopeResource:
+		if rule.ResourceID == nil {
+			return "resource_id is required for a resource quota"
+		}
+		var count int64
+		db.Model(&models.Resource{}).Where("id = ? AND event_id = ?", *rule.ResourceID, rule.EventID).Count(&count)
+		if count == 0 {
+			return "Resource not found"
+		}
+		rule.ResourceType = ""
+	case models.QuotaScopeType:
+		if !models.IsValidResourceType(rule.ResourceType) {
+			return "Invalid resource type (expected service, matériel or avantage)"
+		}
+		rule.ResourceID = nil
+	default:
+		return "Invalid scope (expected ressource or type)"
+	}
+	return ""
+}
+
+// QuotaService manages the quota rules applied to purchase requests
+type QuotaService interface {
+	// List returns the quota rules of an event
+	List(eventID uint) ([]models.QuotaRule, error)
+	Create(actor Actor, req models.CreateQuotaRuleRequest) (*models.QuotaRule, error)
+	Update(actor Actor, id uint, req models.UpdateQuotaRuleRequest) (*models.QuotaRule, error)
+	Delete(actor Actor, id uint) error
+}
+
+type quotaService struct {
+	base
+}
+
+func NewQuotaService(deps Deps) QuotaService {
+	return &quotaService{newBase(deps)}
+}
+
+func (s *quotaService) List(eventID uint) ([]models.QuotaRule, error) {
+	var rules []models.QuotaRule
+	if err := s.db.Preload("Resource").Where("event_id = ?", eventID).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, internal("Failed to fetch quota rules", err)
+	}
+	return rules, nil
+}
+
+func (s *quotaService) Create(actor Actor, req models.CreateQuotaRuleRequest) (*models.QuotaRule, error) {
+	rule := models.QuotaRule{
+		EventID:       actor.EventID,
+		Name:          strings.TrimSpace(req.Name),
+		Scope:         req.Scope,
+		ResourceID:    req.ResourceID,
+		ResourceType:  req.ResourceType,
+		MaxQuantity:   req.MaxQuantity,
+		WindowMinutes: req.WindowMinutes,
+		IsActive:      true,
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+
+	if msg := validateQuotaRule(s.db, &rule); msg != "" {
+		return nil, invalid(msg)
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&rule).Error; err != nil {
+			return internal("Failed to create quota rule", err)
+		}
+
+		// GORM skips false booleans on create and would keep the column default (true)
+		if !rule.IsActive {
+			if err := tx.Model(&rule).Update("is_active", false).Error; err != nil {
+				return internal("Failed to create quota rule", err)
+			}
+		}
+
+		return recordAudit(tx, actor, models.AuditQuotaCreate, models.AuditEntityQuotaRule, rule.ID, nil, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *quotaService) Update(actor Actor, id uint, req models.UpdateQuotaRuleRequest) (*models.QuotaRule, error) {
+	var rule models.QuotaRule
+	if err := inEvent(s.db, actor).First(&rule, id).Error; err != nil {
+		return nil, notFound("Quota rule not found")
+	}
+	before := rule
+
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Scope != nil {
+		rule.Scope = *req.Scope
+	}
+	if req.ResourceID != nil {
+		rule.ResourceID = req.ResourceID
+	}
+	if req.ResourceType != nil {
+		rule.ResourceType = *req.ResourceType
+	}
+	if req.MaxQuantity != nil {
+		rule.MaxQuantity = *req.MaxQuantity
+	}
+	if req.WindowMinutes != nil {
+		rule.WindowMinutes = *req.WindowMinutes
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+
+	if msg := validateQuotaRule(s.db, &rule); msg != "" {
+		return nil, invalid(msg)
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Save(&rule).Error; err != nil {
+			return internal("Failed to update quota rule", err)
+		}
+		return recordAudit(tx, actor, models.AuditQuotaUpdate, models.AuditEntityQuotaRule, rule.ID, before, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (s *quotaService) Delete(actor Actor, id uint) error {
+	var rule models.QuotaRule
+	if err := inEvent(s.db, actor).First(&rule, id).Error; err != nil {
+		return notFound("Quota rule not found")
+	}
+
+	return transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Delete(&rule).Error; err != nil {
+			return internal("Failed to delete quota rule", err)
+		}
+		return recordAudit(tx, actor, models.AuditQuotaDelete, models.AuditEntityQuotaRule, rule.ID, rule, nil)
+	})
+}
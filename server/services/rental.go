@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// setReturnDeadline sets the return deadline of a purchase being confirmed, when it
+// is a rental: after the rental duration of the resource, and at the latest at the
+// end of the event. The resource of the purchase must be loaded.
+func setReturnDeadline(tx *gorm.DB, purchase *models.Purchase, confirmedAt time.Time) error {
+	if !purchase.NeedsReturn {
+		return nil
+	}
+
+	var event models.Event
+	if err := tx.First(&event, purchase.EventID).Error; err != nil {
+		return internal("Failed to fetch event", err)
+	}
+
+	due := event.EndsAt
+	if minutes := purchase.Resource.RentalMinutes; minutes > 0 {
+		if end := confirmedAt.Add(time.Duration(minutes) * time.Minute); due.IsZero() || end.Before(due) {
+			due = end
+		}
+	}
+	if !due.IsZero() {
+		purchase.ReturnDueAt = &due
+	}
+	return nil
+}
+
+// ReturnReminderOptions tune the return reminders
+type ReturnReminderOptions struct {
+	Interval     time.Duration // Time between two looks at the rentals
+	RemindBefore time.Duration // Time before the deadline the team is reminded
+	BatchSize    int           // Rentals reminded per look
+}
+
+// DefaultReturnReminderOptions remind the teams an hour before the deadline
+var DefaultReturnReminderOptions = ReturnReminderOptions{
+	Interval:     time.Minute,
+	RemindBefore: time.Hour,
+	BatchSize:    100,
+}
+
+// ReturnReminderWorker emails the teams about their rentals: once before the return
+// deadline, and once it has passed, when the staff is notified too. The emails go
+// through the outbox. Several workers may run at once: each rental is reminded by a
+// single one.
+type ReturnReminderWorker struct {
+	base
+	opts ReturnReminderOptions
+}
+
+func NewReturnReminderWorker(deps Deps, opts ReturnReminderOptions) *ReturnReminderWorker {
+	return &ReturnReminderWorker{base: newBase(deps), opts: opts}
+}
+
+// Run queues the due reminders until ctx is cancelled
+func (w *ReturnReminderWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.ProcessDue(); err != nil {
+			log.Printf("Return reminders: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDue queues the reminders that are due, and returns the number of rentals
+// reminded
+func (w *ReturnReminderWorker) ProcessDue() (int, error) {
+	now := time.Now()
+
+	// Rentals due soon, that are not late yet
+	reminded, err := w.remind("reminder_sent_at", emails.ReturnReminder,
+		"purchases.return_due_at > ? AND purchases.return_due_at <= ?", now, now.Add(w.opts.RemindBefore))
+	if err != nil {
+		return reminded, err
+	}
+
+	overdue, err := w.remind("overdue_sent_at", emails.ReturnOverdue,
+		"purchases.return_due_at <= ?", now)
+	return reminded + overdue, err
+}
+
+// remind queues one email per team for the rentals out that match the condition and
+// were not reminded yet, then records it in sentColumn
+func (w *ReturnReminderWorker) remind(sentColumn, template, condition string, args ...interface{}) (int, error) {
+	var rentals []models.Purchase
+
+	err := transaction(w.db, func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&models.Purchase{}).Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("needs_return = ? AND is_returned = ? AND status = ?", true, false, models.StatusConfirmed).
+			Where("purchases."+sentColumn+" IS NULL").
+			Where(condition, args...).
+			Order("return_due_at, id").
+			Limit(w.opts.BatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return internal("Failed to fetch rentals", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Purchase{}).Where("id IN ?", ids).Update(sentColumn, time.Now()).Error; err != nil {
+			return internal("Failed to update rentals", err)
+		}
+		if err := tx.Preload("Resource").Where("id IN ?", ids).Order("team_id, return_due_at, id").Find(&rentals).Error; err != nil {
+			return internal("Failed to fetch rentals", err)
+		}
+
+		for start := 0; start < len(rentals); {
+			end := start
+			for end < len(rentals) && rentals[end].TeamID == rentals[start].TeamID {
+				end++
+			}
+			if err := w.remindTeam(tx, template, rentals[start:end]); err != nil {
+				return err
+			}
+			start = end
+		}
+		return nil
+	})
+	return len(rentals), err
+}
+
+// remindTeam queues the email of a team about some of its rentals, and tells the
+// staff when they are late
+func (w *ReturnReminderWorker) remindTeam(tx *gorm.DB, template string, rentals []models.Purchase) error {
+	var team models.Team
+	if err := tx.Unscoped().First(&team, rentals[0].TeamID).Error; err != nil {
+		return internal("Failed to fetch team", err)
+	}
+
+	if template == emails.ReturnOverdue {
+		if err := w.notifyReturnOverdue(tx, team, rentals); err != nil {
+			return err
+		}
+	}
+	return w.queueEmail(tx, team, template, emails.ReturnData{
+		Team:  team.Name,
+		Items: purchaseItems(rentals...),
+	})
+}
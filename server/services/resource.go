@@ -0,0 +1,281 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ResourceService manages the catalog of the shop
+type ResourceService interface {
+	// ListActive returns the resources of an event on sale, optionally of one type
+	ListActive(eventID uint, resourceType string) ([]models.Resource, error)
+	// ListAll returns every resource of an event, or only the deleted ones
+	ListAll(eventID uint, resourceType string, deleted bool) ([]models.Resource, error)
+	Get(id uint) (*models.Resource, error)
+	Create(actor Actor, req models.CreateResourceRequest) (*models.Resource, error)
+	Update(actor Actor, id uint, req models.UpdateResourceRequest) (*models.Resource, error)
+	SetActive(actor Actor, id uint, active bool) (*models.Resource, error)
+	// Delete soft deletes a resource without pending or unreturned purchases
+	Delete(actor Actor, id uint) error
+	Restore(actor Actor, id uint) (*models.Resource, error)
+}
+
+type resourceService struct {
+	base
+}
+
+func NewResourceService(deps Deps) ResourceService {
+	return &resourceService{newBase(deps)}
+}
+
+func (s *resourceService) ListActive(eventID uint, resourceType string) ([]models.Resource, error) {
+	var resources []models.Resource
+	query := s.db.Where("event_id = ? AND is_active = ?", eventID, true)
+
+	if resourceType != "" {
+		query = query.Where("type = ?", resourceType)
+	}
+
+	if err := query.Find(&resources).Error; err != nil {
+		return nil, internal("Failed to fetch resources", err)
+	}
+	return resources, nil
+}
+
+func (s *resourceService) ListAll(eventID uint, resourceType string, deleted bool) ([]models.Resource, error) {
+	var resources []models.Resource
+	query := s.db.Where("event_id = ?", eventID)
+
+	if deleted {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	if resourceType != "" {
+		query = query.Where("type = ?", resourceType)
+	}
+
+	if err := query.Order("name ASC").Find(&resources).Error; err != nil {
+		return nil, internal("Failed to fetch resources", err)
+	}
+	return resources, nil
+}
+
+func (s *resourceService) Get(id uint) (*models.Resource, error) {
+	var resource models.Resource
+	if err := s.db.First(&resource, id).Error; err != nil {
+		return nil, notFound("Resource not found")
+	}
+	return &resource, nil
+}
+
+func (s *resourceService) Create(actor Actor, req models.CreateResourceRequest) (*models.Resource, error) {
+	resource := models.Resource{
+		EventID:           actor.EventID,
+		Name:              strings.TrimSpace(req.Name),
+		Description:       req.Description,
+		Cost:              req.Cost,
+		Quantity:          req.Quantity,
+		MaxPerTeam:        req.MaxPerTeam,
+		Type:              req.Type,
+		ImageURL:          req.ImageURL,
+		IsActive:          true,
+		IsNonReturnable:   req.IsNonReturnable,
+		LowStockThreshold: req.LowStockThreshold,
+		RentalMinutes:     req.RentalMinutes,
+	}
+	if req.IsActive != nil {
+		resource.IsActive = *req.IsActive
+	}
+
+	if msg := resource.Validate(); msg != "" {
+		return nil, invalid(msg)
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&resource).Error; err != nil {
+			return internal("Failed to create resource", err)
+		}
+
+		// GORM skips false booleans on create and would keep the column default (true)
+		if !resource.IsActive {
+			if err := tx.Model(&resource).Update("is_active", false).Error; err != nil {
+				return internal("Failed to create resource", err)
+			}
+		}
+
+		return recordAudit(tx, actor, models.AuditResourceCreate, models.AuditEntityResource, resource.ID, nil, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func (s *resourceService) Update(actor Actor, id uint, req models.UpdateResourceRequest) (*models.Resource, error) {
+	var resource models.Resource
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// The lock keeps concurrent stock changes from being checked against or
+		// overwritten by a stale copy
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&resource, id).Error; err != nil {
+			return notFound("Resource not found")
+		}
+
+		before := resource
+		updates := map[string]interface{}{}
+		if req.Name != nil {
+			resource.Name = strings.TrimSpace(*req.Name)
+			updates["name"] = resource.Name
+		}
+		if req.Description != nil {
+			resource.Description = *req.Description
+			updates["description"] = resource.Description
+		}
+		if req.Cost != nil {
+			resource.Cost = *req.Cost
+			updates["cost"] = resource.Cost
+		}
+		if req.Quantity != nil {
+			resource.Quantity = *req.Quantity
+			updates["quantity"] = resource.Quantity
+		}
+		if req.MaxPerTeam != nil {
+			resource.MaxPerTeam = *req.MaxPerTeam
+			updates["max_per_team"] = resource.MaxPerTeam
+		}
+		if req.Type != nil {
+			resource.Type = *req.Type
+			updates["type"] = resource.Type
+		}
+		if req.ImageURL != nil {
+			resource.ImageURL = *req.ImageURL
+			updates["image_url"] = resource.ImageURL
+		}
+		if req.IsActive != nil {
+			resource.IsActive = *req.IsActive
+			updates["is_active"] = resource.IsActive
+		}
+		if req.IsNonReturnable != nil {
+			resource.IsNonReturnable = *req.IsNonReturnable
+			updates["is_non_returnable"] = resource.IsNonReturnable
+		}
+		if req.LowStockThreshold != nil {
+			resource.LowStockThreshold = *req.LowStockThreshold
+			updates["low_stock_threshold"] = resource.LowStockThreshold
+		}
+		if req.RentalMinutes != nil {
+			resource.RentalMinutes = *req.RentalMinutes
+			updates["rental_minutes"] = resource.RentalMinutes
+		}
+
+		if msg := resource.Validate(); msg != "" {
+			return invalid(msg)
+		}
+		if resource.Quantity < resource.Reserved {
+			return conflict("Quantity cannot be lower than the units reserved by pending purchases").
+				With("reserved_quantity", resource.Reserved)
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		// Reserved units are only changed by the purchase flow
+		if err := tx.Model(&resource).Updates(updates).Error; err != nil {
+			return internal("Failed to update resource", err)
+		}
+		if err := s.notifyLowStock(tx, resource.ID, before.AvailableQuantity()-resource.AvailableQuantity()); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditResourceUpdate, models.AuditEntityResource, resource.ID, before, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func (s *resourceService) SetActive(actor Actor, id uint, active bool) (*models.Resource, error) {
+	var resource models.Resource
+	if err := inEvent(s.db, actor).First(&resource, id).Error; err != nil {
+		return nil, notFound("Resource not found")
+	}
+
+	before := resource
+	resource.IsActive = active
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Model(&resource).Update("is_active", active).Error; err != nil {
+			return internal("Failed to update resource", err)
+		}
+
+		auditAction := models.AuditResourceActivate
+		if !active {
+			auditAction = models.AuditResourceDeactivate
+		}
+		return recordAudit(tx, actor, auditAction, models.AuditEntityResource, resource.ID, before, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
+
+func (s *resourceService) Delete(actor Actor, id uint) error {
+	return transaction(s.db, func(tx *gorm.DB) error {
+		// The lock holds back the purchases of the resource while it is checked
+		var resource models.Resource
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&resource, id).Error; err != nil {
+			return notFound("Resource not found")
+		}
+
+		var pendingCount int64
+		if err := tx.Model(&models.Purchase{}).
+			Where("resource_id = ? AND status = ?", resource.ID, models.StatusPending).
+			Count(&pendingCount).Error; err != nil {
+			return internal("Failed to check purchases", err)
+		}
+
+		var unreturnedCount int64
+		if err := tx.Model(&models.Purchase{}).
+			Where("resource_id = ? AND status = ? AND needs_return = ? AND is_returned = ?", resource.ID, models.StatusConfirmed, true, false).
+			Count(&unreturnedCount).Error; err != nil {
+			return internal("Failed to check purchases", err)
+		}
+
+		if pendingCount > 0 || unreturnedCount > 0 {
+			return conflict("Resource still has pending or unreturned purchases").
+				With("pending_count", pendingCount).
+				With("unreturned_count", unreturnedCount)
+		}
+
+		if err := tx.Delete(&resource).Error; err != nil {
+			return internal("Failed to delete resource", err)
+		}
+		return recordAudit(tx, actor, models.AuditResourceDelete, models.AuditEntityResource, resource.ID, resource, nil)
+	})
+}
+
+func (s *resourceService) Restore(actor Actor, id uint) (*models.Resource, error) {
+	var resource models.Resource
+	if err := inEvent(s.db, actor).Unscoped().First(&resource, id).Error; err != nil {
+		return nil, notFound("Resource not found")
+	}
+
+	if !resource.DeletedAt.Valid {
+		return nil, invalid("Resource is not deleted")
+	}
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&resource).Update("deleted_at", nil).Error; err != nil {
+			return internal("Failed to restore resource", err)
+		}
+
+		resource.DeletedAt = gorm.DeletedAt{}
+		return recordAudit(tx, actor, models.AuditResourceRestore, models.AuditEntityResource, resource.ID, nil, resource)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resource, nil
+}
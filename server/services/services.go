@@ -0,0 +1,93 @@
+// Package services holds the business rules of the shop: purchases, votes, polls,
+// team credit, stock and quotas. Services receive their dependencies through Deps
+// and know nothing about HTTP, so the same rules can run from the API, the command
+// line tools or tests.
+package services
+
+import (
+	"github.com/ericp/ylab-hackathon/emails"
+	"github.com/ericp/ylab-hackathon/events"
+	"gorm.io/gorm"
+)
+
+// Mailer sends the emails of the outbox. It is implemented by utils.EmailService.
+type Mailer interface {
+	Send(to string, msg emails.Message) error
+}
+
+// Deps are the dependencies shared by the services
+type Deps struct {
+	DB        *gorm.DB
+	Mailer    Mailer
+	Templates *emails.Registry // Renders the emails, in the locale of each team
+	Events    *events.Broker   // Live updates, published once a change is committed
+	PublicURL string           // Address of the app, used in the links of the emails
+}
+
+// Services groups every service built from the same dependencies
+type Services struct {
+	Purchases     PurchaseService
+	Votes         VoteService
+	Polls         PollService
+	Teams         TeamService
+	Resources     ResourceService
+	Compositions  CompositionService
+	Quotas        QuotaService
+	Outbox        OutboxService
+	Notifications NotificationService
+	Accounts      AccountService
+	Sessions      SessionService
+	Logins        LoginService
+	Admins        AdminService
+	Events        EventService
+}
+
+// New builds the services
+func New(deps Deps) *Services {
+	return &Services{
+		Purchases:     NewPurchaseService(deps),
+		Votes:         NewVoteService(deps),
+		Polls:         NewPollService(deps),
+		Teams:         NewTeamService(deps),
+		Resources:     NewResourceService(deps),
+		Compositions:  NewCompositionService(deps),
+		Quotas:        NewQuotaService(deps),
+		Outbox:        NewOutboxService(deps),
+		Notifications: NewNotificationService(deps),
+		Accounts:      NewAccountService(deps),
+		Sessions:      NewSessionService(deps),
+		Logins:        NewLoginService(deps),
+		Admins:        NewAdminService(deps),
+		Events:        NewEventService(deps),
+	}
+}
+
+// base holds the dependencies shared by the service implementations
+type base struct {
+	db        *gorm.DB
+	mailer    Mailer
+	templates *emails.Registry
+	events    *events.Broker
+	publicURL string
+}
+
+func newBase(deps Deps) base {
+	return base{db: deps.DB, mailer: deps.Mailer, templates: deps.Templates, events: deps.Events, publicURL: deps.PublicURL}
+}
+
+// Actor is the authenticated user performing an action
+type Actor struct {
+	UserType  string // "team" or "admin"
+	UserID    uint
+	SessionID uint // Session of the access token
+	EventID   uint // Event of the team, or selected by the admin
+}
+
+// AdminID returns the ID of the admin performing the action, or nil for teams
+func (a Actor) AdminID() *uint {
+	if a.UserType != "admin" {
+		return nil
+	}
+	adminID := a.UserID
+	return &adminID
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refreshTokenTTL is how long a session stays open without being refreshed
+const refreshTokenTTL = 7 * 24 * time.Hour
+
+// Client describes where a session is used from
+type Client struct {
+	UserAgent string
+	IPAddress string
+}
+
+// revokeSessions ends the open sessions of an account, except the given one (0 to end
+// them all), and returns how many were ended
+func revokeSessions(tx *gorm.DB, userType string, userID, except uint) (int64, error) {
+	result := tx.Model(&models.Session{}).
+		Where("user_type = ? AND user_id = ? AND id <> ? AND revoked_at IS NULL", userType, userID, except).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return 0, internal("Failed to revoke sessions", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SessionEvent checks that a session still grants access and returns the event its
+// user acts in: the event of the team, or the one the admin selected for the session,
+// by default the current one. Logging out, revoking the session or suspending the
+// team denies access before the access tokens expire.
+func SessionEvent(db *gorm.DB, userType string, userID, sessionID uint) (uint, error) {
+	var session models.Session
+	if err := db.Select("id", "event_id", "revoked_at").
+		Where("user_type = ? AND user_id = ?", userType, userID).
+		First(&session, sessionID).Error; err != nil || session.RevokedAt != nil {
+		return 0, unauthorized("Session has been revoked")
+	}
+
+	if userType == "team" {
+		var team models.Team
+		if err := db.Select("id", "event_id", "is_suspended").First(&team, userID).Error; err != nil {
+			return 0, unauthorized("User not found")
+		}
+		if team.IsSuspended {
+			return 0, forbidden("Team is suspended")
+		}
+		return team.EventID, nil
+	}
+
+	if session.EventID != nil {
+		return *session.EventID, nil
+	}
+	if event, err := CurrentEvent(db); err == nil {
+		return event.ID, nil
+	}
+	return 0, nil
+}
+
+// SessionService manages the logins of teams and admins and their refresh tokens.
+// Access tokens are signed by the handlers for the sessions it returns.
+type SessionService interface {
+	// Start opens a session for an authenticated account and returns its refresh token
+	Start(userType string, userID uint, client Client) (*models.Session, string, error)
+	// Refresh replaces the refresh token of a session. A token that was already
+	// replaced may have been stolen: presenting it again revokes the session.
+	Refresh(refreshToken string, client Client) (*models.Session, string, error)
+	// Logout ends the session of the actor
+	Logout(actor Actor) error
+	// LogoutAll ends every session of the actor and returns how many were ended
+	LogoutAll(actor Actor) (int64, error)
+	// Revoke lets an admin end any session
+	Revoke(actor Actor, id uint) (*models.Session, error)
+}
+
+type sessionService struct {
+	base
+}
+
+func NewSessionService(deps Deps) SessionService {
+	return &sessionService{newBase(deps)}
+}
+
+func (s *sessionService) Start(userType string, userID uint, client Client) (*models.Session, string, error) {
+	refreshToken, err := generateSecret()
+	if err != nil {
+		return nil, "", internal("Failed to create session", err)
+	}
+
+	now := time.Now()
+	session := models.Session{
+		UserType:         userType,
+		UserID:           userID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        client.UserAgent,
+		IPAddress:        client.IPAddress,
+		ExpiresAt:        now.Add(refreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := s.db.Create(&session).Error; err != nil {
+		return nil, "", internal("Failed to create session", err)
+	}
+	return &session, refreshToken, nil
+}
+
+func (s *sessionService) Refresh(refreshToken string, client Client) (*models.Session, string, error) {
+	var session models.Session
+	var next string
+	hash := hashToken(refreshToken)
+	unknown := false
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", hash).
+			First(&session).Error; err != nil {
+			unknown = true
+			return unauthorized("Invalid or expired refresh token")
+		}
+		if !session.IsActive() {
+			return unauthorized("Invalid or expired refresh token")
+		}
+
+		if _, ok := findAccount(tx, session.UserType, session.UserID); !ok {
+			return unauthorized("User not found")
+		}
+		if session.UserType == "team" {
+			var team models.Team
+			if err := tx.Select("id", "is_suspended").First(&team, session.UserID).Error; err != nil {
+				return internal("Failed to refresh session", err)
+			}
+			if team.IsSuspended {
+				return forbidden("Team is suspended")
+			}
+		}
+
+		var err error
+		if next, err = generateSecret(); err != nil {
+			return internal("Failed to refresh session", err)
+		}
+		now := time.Now()
+		session.PreviousTokenHash = hash
+		session.RefreshTokenHash = hashToken(next)
+		session.UserAgent = client.UserAgent
+		session.IPAddress = client.IPAddress
+		session.ExpiresAt = now.Add(refreshTokenTTL)
+		session.LastUsedAt = now
+		if err := tx.Save(&session).Error; err != nil {
+			return internal("Failed to refresh session", err)
+		}
+		return nil
+	})
+	if unknown {
+		// The token may have been replaced already: whoever presented it first, the
+		// client or a thief, loses the session
+		if err := s.db.Model(&models.Session{}).
+			Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return nil, "", internal("Failed to refresh session", err)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &session, next, nil
+}
+
+func (s *sessionService) Logout(actor Actor) error {
+	if err := s.db.Model(&models.Session{}).
+		Where("id = ? AND user_type = ? AND user_id = ? AND revoked_at IS NULL", actor.SessionID, actor.UserType, actor.UserID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return internal("Failed to log out", err)
+	}
+	return nil
+}
+
+func (s *sessionService) LogoutAll(actor Actor) (int64, error) {
+	return revokeSessions(s.db, actor.UserType, actor.UserID, 0)
+}
+
+func (s *sessionService) Revoke(actor Actor, id uint) (*models.Session, error) {
+	var session models.Session
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&session, id).Error; err != nil {
+			return notFound("Session not found")
+		}
+		if session.RevokedAt != nil {
+			return invalid("Session is already revoked")
+		}
+
+		before := session
+		now := time.Now()
+		session.RevokedAt = &now
+		if err := tx.Model(&session).Update("revoked_at", now).Error; err != nil {
+			return internal("Failed to revoke session", err)
+		}
+		return recordAudit(tx, actor, models.AuditSessionRevoke, models.AuditEntitySession, session.ID, before, session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+)
+
+var errInsufficientStock = errors.New("insufficient stock")
+
+// Stock changes are single conditional UPDATE statements so that concurrent requests
+// cannot oversell a resource. Resource.Quantity counts the units in stock and
+// Resource.Reserved the part of them held by pending purchases.
+
+// reserveStock holds units of a resource for a pending purchase
+func reserveStock(tx *gorm.DB, resourceID uint, quantity int) error {
+	result := tx.Model(&models.Resource{}).
+		Where("id = ? AND quantity - reserved >= ?", resourceID, quantity).
+		UpdateColumn("reserved", gorm.Expr("reserved + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
+
+// releaseStock gives back the units held by a pending purchase that is cancelled
+func releaseStock(tx *gorm.DB, resourceID uint, quantity int) error {
+	return tx.Model(&models.Resource{}).
+		Where("id = ?", resourceID).
+		UpdateColumn("reserved", gorm.Expr("CASE WHEN reserved >= ? THEN reserved - ? ELSE 0 END", quantity, quantity)).
+		Error
+}
+
+// consumeReservation confirms a pending purchase: the held units are released and the
+// approved ones (at most the held ones) leave the stock
+func consumeReservation(tx *gorm.DB, resourceID uint, held, approved int) error {
+	result := tx.Model(&models.Resource{}).
+		Where("id = ? AND quantity >= ?", resourceID, approved).
+		UpdateColumns(map[string]interface{}{
+			"reserved": gorm.Expr("CASE WHEN reserved >= ? THEN reserved - ? ELSE 0 END", held, held),
+			"quantity": gorm.Expr("quantity - ?", approved),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
+
+// restockResource puts returned units back in stock
+func restockResource(tx *gorm.DB, resourceID uint, quantity int) error {
+	return tx.Model(&models.Resource{}).
+		Where("id = ?", resourceID).
+		UpdateColumn("quantity", gorm.Expr("quantity + ?", quantity)).
+		Error
+}
+
+// unstockResource takes back units that were put in stock by mistake, without
+// touching the units reserved by pending purchases
+func unstockResource(tx *gorm.DB, resourceID uint, quantity int) error {
+	result := tx.Model(&models.Resource{}).
+		Where("id = ? AND quantity - reserved >= ?", resourceID, quantity).
+		UpdateColumn("quantity", gorm.Expr("quantity - ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInsufficientStock
+	}
+	return nil
+}
+
+// SyncReservedStock recomputes the reserved units of every resource from its pending
+// purchases. It is run at startup so that purchases made before reservations existed
+// are accounted for.
+func SyncReservedStock(db *gorm.DB) error {
+	return db.Exec(
+		`UPDATE resources SET reserved = COALESCE((
+			SELECT SUM(p.quantity) FROM purchases p
+			WHERE p.resource_id = resources.id AND p.status = ? AND p.deleted_at IS NULL
+		), 0)`,
+		models.StatusPending,
+	).Error
+}
@@ -0,0 +1,393 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/ericp/ylab-hackathon/models"
+	"github.com/ericp/ylab-hackathon/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// generatedPasswordLength is the length of the passwords generated for teams
+const generatedPasswordLength = 12
+
+// TeamService manages the team accounts and their credit
+type TeamService interface {
+	Get(id uint) (*models.Team, error)
+	// List returns the teams of an event
+	List(eventID uint) ([]models.Team, error)
+	// UpdateProfile lets a team change its own email
+	UpdateProfile(teamID uint, req models.UpdateTeamProfileRequest) (*models.Team, error)
+	// Create creates a team in the event of the actor and returns its generated
+	// password, only available once
+	Create(actor Actor, req models.CreateTeamRequest) (*models.Team, string, error)
+	Update(actor Actor, id uint, req models.UpdateTeamRequest) (*models.Team, error)
+	// ResetPassword generates a new password, only available once
+	ResetPassword(actor Actor, id uint) (*models.Team, string, error)
+	// AdjustCredit sets or changes the credit of a team, recorded in its ledger
+	AdjustCredit(actor Actor, id uint, req models.AdjustCreditRequest) (*models.Team, error)
+	SetSuspended(actor Actor, id uint, suspended bool) (*models.Team, error)
+	// Ledger returns the credit history of a team, checked against its balance
+	Ledger(id uint) (*models.LedgerResponse, error)
+	// CheckLedgers compares every balance with its ledger and returns the mismatches
+	CheckLedgers() (int, []LedgerMismatch, error)
+}
+
+// LedgerMismatch is a team whose balance differs from the sum of its ledger
+type LedgerMismatch struct {
+	TeamID        uint   `json:"team_id"`
+	Name          string `json:"name"`
+	Credit        int    `json:"credit"`
+	LedgerBalance int    `json:"ledger_balance"`
+}
+
+type teamService struct {
+	base
+}
+
+func NewTeamService(deps Deps) TeamService {
+	return &teamService{newBase(deps)}
+}
+
+func (s *teamService) Get(id uint) (*models.Team, error) {
+	var team models.Team
+	if err := s.db.First(&team, id).Error; err != nil {
+		return nil, notFound("Team not found")
+	}
+	return &team, nil
+}
+
+// find returns a team of the event of the actor
+func (s *teamService) find(actor Actor, id uint) (*models.Team, error) {
+	var team models.Team
+	if err := inEvent(s.db, actor).First(&team, id).Error; err != nil {
+		return nil, notFound("Team not found")
+	}
+	return &team, nil
+}
+
+func (s *teamService) List(eventID uint) ([]models.Team, error) {
+	var teams []models.Team
+	if err := s.db.Where("event_id = ?", eventID).Find(&teams).Error; err != nil {
+		return nil, internal("Failed to fetch teams", err)
+	}
+	return teams, nil
+}
+
+func (s *teamService) UpdateProfile(teamID uint, req models.UpdateTeamProfileRequest) (*models.Team, error) {
+	email := utils.NormalizeEmail(req.Email)
+
+	// Check if valid email (has @ and .)
+	if !utils.IsValidEmail(email) {
+		return nil, invalid("Invalid email format")
+	}
+
+	team, err := s.Get(teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if email is already taken by another team of the event
+	var existingTeam models.Team
+	if err := s.db.Where("event_id = ? AND email = ? AND id != ?", team.EventID, email, teamID).First(&existingTeam).Error; err == nil {
+		return nil, invalid("Email already taken")
+	}
+
+	// Only the profile columns are written, so that a concurrent credit change is kept
+	team.Email = email
+	if req.Locale != nil {
+		team.Locale = *req.Locale
+	}
+	if err := s.db.Model(team).Updates(map[string]interface{}{"email": team.Email, "locale": team.Locale}).Error; err != nil {
+		return nil, internal("Failed to update profile", err)
+	}
+	return team, nil
+}
+
+// teamNameOrEmailTaken checks the columns unique within an event, including
+// soft-deleted rows
+func teamNameOrEmailTaken(db *gorm.DB, eventID uint, name, email string, excludeID uint) (string, bool) {
+	var existing models.Team
+	if name != "" && db.Unscoped().Where("event_id = ? AND name = ? AND id != ?", eventID, name, excludeID).First(&existing).Error == nil {
+		return "Team name already taken", true
+	}
+	if email != "" && db.Unscoped().Where("event_id = ? AND email = ? AND id != ?", eventID, email, excludeID).First(&existing).Error == nil {
+		return "Email already taken", true
+	}
+	return "", false
+}
+
+// newTeamPassword generates a team password and its hash
+func newTeamPassword() (string, string, error) {
+	password, err := utils.GeneratePassword(generatedPasswordLength)
+	if err != nil {
+		return "", "", internal("Failed to generate password", err)
+	}
+	passwordHash, err := utils.HashPassword(password)
+	if err != nil {
+		return "", "", internal("Failed to hash password", err)
+	}
+	return password, passwordHash, nil
+}
+
+func (s *teamService) Create(actor Actor, req models.CreateTeamRequest) (*models.Team, string, error) {
+	name := strings.TrimSpace(req.Name)
+	email := utils.NormalizeEmail(req.Email)
+	if name == "" {
+		return nil, "", invalid("Name is required")
+	}
+	if !utils.IsValidEmail(email) {
+		return nil, "", invalid("Invalid email format")
+	}
+	if msg, taken := teamNameOrEmailTaken(s.db, actor.EventID, name, email, 0); taken {
+		return nil, "", invalid(msg)
+	}
+
+	var event models.Event
+	if err := s.db.First(&event, actor.EventID).Error; err != nil {
+		return nil, "", notFound("Event not found")
+	}
+	credit := event.StartingCredit
+	if req.Credit != nil {
+		credit = *req.Credit
+	}
+	locale := models.LocaleFrench
+	if req.Locale != "" {
+		locale = req.Locale
+	}
+
+	password, passwordHash, err := newTeamPassword()
+	if err != nil {
+		return nil, "", err
+	}
+
+	team := models.Team{
+		EventID:      event.ID,
+		Name:         name,
+		Email:        email,
+		PasswordHash: passwordHash,
+		Credit:       credit,
+		Locale:       locale,
+	}
+
+	err = transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&team).Error; err != nil {
+			return internal("Failed to create team", err)
+		}
+
+		// GORM skips zero values on create and would keep the column default
+		if credit == 0 {
+			if err := tx.Model(&team).Update("credit", 0).Error; err != nil {
+				return internal("Failed to create team", err)
+			}
+		}
+
+		opening := models.CreditTransaction{
+			TeamID:       team.ID,
+			Amount:       credit,
+			BalanceAfter: credit,
+			Reason:       models.CreditReasonOpening,
+			AdminID:      actor.AdminID(),
+		}
+		if err := tx.Create(&opening).Error; err != nil {
+			return internal("Failed to create team", err)
+		}
+
+		return recordAudit(tx, actor, models.AuditTeamCreate, models.AuditEntityTeam, team.ID, nil, team.ToResponse())
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &team, password, nil
+}
+
+func (s *teamService) Update(actor Actor, id uint, req models.UpdateTeamRequest) (*models.Team, error) {
+	team, err := s.find(actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := team.ToResponse()
+	name, email := "", ""
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, invalid("Name is required")
+		}
+		team.Name = name
+	}
+	if req.Email != nil {
+		email = utils.NormalizeEmail(*req.Email)
+		if !utils.IsValidEmail(email) {
+			return nil, invalid("Invalid email format")
+		}
+		team.Email = email
+	}
+	if req.Locale != nil {
+		team.Locale = *req.Locale
+	}
+
+	if msg, taken := teamNameOrEmailTaken(s.db, team.EventID, name, email, team.ID); taken {
+		return nil, invalid(msg)
+	}
+
+	err = transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Model(team).Updates(map[string]interface{}{
+			"name":   team.Name,
+			"email":  team.Email,
+			"locale": team.Locale,
+		}).Error; err != nil {
+			return internal("Failed to update team", err)
+		}
+		return recordAudit(tx, actor, models.AuditTeamUpdate, models.AuditEntityTeam, team.ID, before, team.ToResponse())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *teamService) ResetPassword(actor Actor, id uint) (*models.Team, string, error) {
+	team, err := s.find(actor, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	password, passwordHash, err := newTeamPassword()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = transaction(s.db, func(tx *gorm.DB) error {
+		if err := setPassword(tx, teamAccount(*team), passwordHash, 0); err != nil {
+			return err
+		}
+		return recordAudit(tx, actor, models.AuditTeamResetPassword, models.AuditEntityTeam, team.ID, nil, nil)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return team, password, nil
+}
+
+func (s *teamService) AdjustCredit(actor Actor, id uint, req models.AdjustCreditRequest) (*models.Team, error) {
+	justification := strings.TrimSpace(req.Justification)
+	if justification == "" {
+		return nil, invalid("Justification is required")
+	}
+	if req.Mode == "set" && req.Amount < 0 {
+		return nil, invalid("Credit cannot be negative")
+	}
+
+	var team models.Team
+	amount := req.Amount
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Locked before computing the difference, so that no purchase or refund changes
+		// the balance in between
+		if err := inEvent(tx, actor).Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, id).Error; err != nil {
+			return notFound("Team not found")
+		}
+
+		before := team.ToResponse()
+		if req.Mode == "set" {
+			amount = req.Amount - team.Credit
+		}
+
+		if amount != 0 {
+			if err := applyCreditChange(tx, &team, amount, models.CreditTransaction{
+				Reason:  models.CreditReasonAdjustment,
+				AdminID: actor.AdminID(),
+				Note:    justification,
+			}); err != nil {
+				if err == errInsufficientCredit {
+					return invalid("Credit cannot be negative")
+				}
+				return internal("Failed to update credit", err)
+			}
+		}
+
+		after := map[string]interface{}{"team": team.ToResponse(), "mode": req.Mode, "amount": req.Amount, "justification": justification}
+		return recordAudit(tx, actor, models.AuditTeamAdjustCredit, models.AuditEntityTeam, team.ID, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if amount != 0 {
+		s.publishCreditUpdate(team)
+	}
+	return &team, nil
+}
+
+func (s *teamService) SetSuspended(actor Actor, id uint, suspended bool) (*models.Team, error) {
+	team, err := s.find(actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := team.ToResponse()
+	team.IsSuspended = suspended
+
+	err = transaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Model(team).Update("is_suspended", suspended).Error; err != nil {
+			return internal("Failed to update team", err)
+		}
+
+		auditAction := models.AuditTeamSuspend
+		if !suspended {
+			auditAction = models.AuditTeamUnsuspend
+		}
+		return recordAudit(tx, actor, auditAction, models.AuditEntityTeam, team.ID, before, team.ToResponse())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *teamService) Ledger(id uint) (*models.LedgerResponse, error) {
+	team, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &models.LedgerResponse{
+		TeamID: team.ID,
+		Credit: team.Credit,
+	}
+
+	if err := s.db.Where("team_id = ?", team.ID).
+		Order("created_at ASC, id ASC").
+		Find(&ledger.Transactions).Error; err != nil {
+		return nil, internal("Failed to fetch ledger", err)
+	}
+
+	for _, t := range ledger.Transactions {
+		ledger.LedgerBalance += t.Amount
+	}
+	ledger.Consistent = ledger.LedgerBalance == team.Credit
+
+	return ledger, nil
+}
+
+func (s *teamService) CheckLedgers() (int, []LedgerMismatch, error) {
+	var balances []LedgerMismatch
+	if err := s.db.Model(&models.Team{}).
+		Select("teams.id AS team_id, teams.name, teams.credit, COALESCE(SUM(ct.amount), 0) AS ledger_balance").
+		Joins("LEFT JOIN credit_transactions ct ON ct.team_id = teams.id").
+		Group("teams.id, teams.name, teams.credit").
+		Scan(&balances).Error; err != nil {
+		return 0, nil, internal("Failed to check ledgers", err)
+	}
+
+	mismatches := make([]LedgerMismatch, 0)
+	for _, b := range balances {
+		if b.Credit != b.LedgerBalance {
+			mismatches = append(mismatches, b)
+		}
+	}
+
+	return len(balances), mismatches, nil
+}
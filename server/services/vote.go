@@ -0,0 +1,139 @@
+package services
+
+import (
+	"time"
+
+	"github.com/ericp/ylab-hackathon/events"
+	"github.com/ericp/ylab-hackathon/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// VoteService handles the credits staked by the teams on the polls
+type VoteService interface {
+	// Cast stakes credits of a team on an option of an open poll
+	Cast(teamID uint, req models.VoteRequest) (*models.Vote, error)
+	// ForTeam returns the votes of a team with their poll
+	ForTeam(teamID uint) ([]models.Vote, error)
+	// Get returns the vote of a team on a poll
+	Get(teamID, pollID uint) (*models.Vote, error)
+}
+
+type voteService struct {
+	base
+}
+
+func NewVoteService(deps Deps) VoteService {
+	return &voteService{newBase(deps)}
+}
+
+func (s *voteService) Cast(teamID uint, req models.VoteRequest) (*models.Vote, error) {
+	var team models.Team
+	var vote models.Vote
+
+	err := transaction(s.db, func(tx *gorm.DB) error {
+		// Share-lock the poll so that it cannot be closed, cancelled or resolved before
+		// the vote is committed
+		var poll models.Poll
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).First(&poll, req.PollID).Error; err != nil {
+			return notFound("Poll not found")
+		}
+
+		// Check if poll is open
+		if poll.Status != models.PollStatusOpen {
+			return invalid("Poll is closed")
+		}
+
+		// Check if poll is within date range
+		now := time.Now()
+		if now.Before(poll.StartDate) || now.After(poll.EndDate) {
+			return invalid("Poll is not currently active")
+		}
+
+		// Check if option is valid
+		if !poll.HasOption(req.ChosenOption) {
+			return invalid("Invalid option")
+		}
+
+		// Lock the team so that concurrent votes of the same team are serialized
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, teamID).Error; err != nil {
+			return notFound("Team not found")
+		}
+
+		// Teams only see the polls of their event
+		if poll.EventID != team.EventID {
+			return notFound("Poll not found")
+		}
+
+		// Check if team already voted
+		var existingVote models.Vote
+		if err := tx.Where("team_id = ? AND poll_id = ?", teamID, req.PollID).First(&existingVote).Error; err == nil {
+			return invalid("Team has already voted on this poll")
+		}
+
+		// Check team credit
+		if team.Credit < req.CreditStaked {
+			return invalid("Insufficient credit")
+		}
+
+		// Create vote
+		vote = models.Vote{
+			TeamID:       teamID,
+			PollID:       req.PollID,
+			ChosenOption: req.ChosenOption,
+			CreditStaked: req.CreditStaked,
+			VoteDate:     time.Now(),
+		}
+		if err := tx.Create(&vote).Error; err != nil {
+			return internal("Failed to create vote", err)
+		}
+
+		// Deduct credit
+		if err := applyCreditChange(tx, &team, -req.CreditStaked, models.CreditTransaction{
+			Reason: models.CreditReasonVoteStake,
+			VoteID: &vote.ID,
+		}); err != nil {
+			if err == errInsufficientCredit {
+				return invalid("Insufficient credit")
+			}
+			return internal("Failed to update credit", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Load relations for response
+	s.db.Preload("Poll").Preload("Team").First(&vote, vote.ID)
+
+	s.publishCreditUpdate(team)
+	if s.events != nil {
+		s.events.ToAdmins(team.EventID, events.VoteCreated, map[string]interface{}{
+			"vote_id":       vote.ID,
+			"poll_id":       vote.PollID,
+			"team_id":       vote.TeamID,
+			"chosen_option": vote.ChosenOption,
+			"credit_staked": vote.CreditStaked,
+		})
+	}
+
+	return &vote, nil
+}
+
+func (s *voteService) ForTeam(teamID uint) ([]models.Vote, error) {
+	var votes []models.Vote
+	if err := s.db.Where("team_id = ?", teamID).Preload("Poll").Find(&votes).Error; err != nil {
+		return nil, internal("Failed to fetch votes", err)
+	}
+	return votes, nil
+}
+
+func (s *voteService) Get(teamID, pollID uint) (*models.Vote, error) {
+	var vote models.Vote
+	if err := s.db.Where("team_id = ? AND poll_id = ?", teamID, pollID).
+		Preload("Poll").First(&vote).Error; err != nil {
+		return nil, notFound("Vote not found")
+	}
+	return &vote, nil
+}
@@ -1,10 +1,17 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
 	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net/smtp"
+	"net/textproto"
 	"strings"
+
+	"github.com/ericp/ylab-hackathon/emails"
 )
 
 type EmailService struct {
@@ -45,85 +52,63 @@ func NewEmailService(host, port, user, pass, from string) *EmailService {
 	}
 }
 
-func (e *EmailService) SendEmail(to, subject, body string) error {
+// Send sends a rendered email with its HTML body and its plain-text alternative
+func (e *EmailService) Send(to string, msg emails.Message) error {
 	// Check if SMTP credentials are set
 	if e.SMTPUser == "" || e.SMTPPass == "" {
 		// Email not configured, skip sending
 		return nil
 	}
 
-	auth := smtp.PlainAuth("", e.SMTPUser, e.SMTPPass, e.SMTPHost)
+	log.Printf("Sending email %q to %s\n", msg.Subject, to)
 
-	msg := []byte(fmt.Sprintf("From: %s\r\n"+
-		"To: %s\r\n"+
-		"Subject: %s\r\n"+
-		"Content-Type: text/html; charset=UTF-8\r\n"+
-		"\r\n"+
-		"%s\r\n", e.From, to, subject, body))
+	body, err := BuildMessage(e.From, to, msg)
+	if err != nil {
+		return err
+	}
 
+	auth := smtp.PlainAuth("", e.SMTPUser, e.SMTPPass, e.SMTPHost)
 	addr := fmt.Sprintf("%s:%s", e.SMTPHost, e.SMTPPort)
-	err := smtp.SendMail(addr, auth, e.From, []string{to}, msg)
-	return err
-}
-
-func (e *EmailService) SendPurchaseConfirmation(to, teamName, resourceName string, quantity int) error {
-	subject := "Achat confirmé - YLab Hackathon"
-
-	log.Printf("Sending purchase confirmation email to %s for team %s\n", to, teamName)
-
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>Achat confirmé</h2>
-			<p>Bonjour %s,</p>
-			<p>Votre achat a été confirmé avec succès :</p>
-			<ul>
-				<li>Ressource: %s</li>
-				<li>Quantité: %d</li>
-			</ul>
-			<p>Merci de votre participation au YLab Hackathon 2025 !</p>
-		</body>
-		</html>
-	`, teamName, resourceName, quantity)
-
-	return e.SendEmail(to, subject, body)
-}
-
-func (e *EmailService) SendPurchaseRejection(to, teamName, resourceName string, quantity int) error {
-	subject := "Achat refusé - YLab Hackathon"
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>Achat refusé</h2>
-			<p>Bonjour %s,</p>
-			<p>Votre demande d'achat a été refusée :</p>
-			<ul>
-				<li>Ressource: %s</li>
-				<li>Quantité: %d</li>
-			</ul>
-			<p>Vos crédits ont été restitués.</p>
-			<p>Pour plus d'informations, veuillez contacter l'organisation.</p>
-		</body>
-		</html>
-	`, teamName, resourceName, quantity)
-
-	return e.SendEmail(to, subject, body)
+	return smtp.SendMail(addr, auth, e.From, []string{to}, body)
 }
 
-func (e *EmailService) SendLowCreditAlert(to, teamName string, credit int) error {
-	subject := "Alerte crédit faible - YLab Hackathon"
-	body := fmt.Sprintf(`
-		<html>
-		<body>
-			<h2>Alerte crédit faible</h2>
-			<p>Bonjour %s,</p>
-			<p>Votre crédit est maintenant de %d points.</p>
-			<p>Pensez à gérer vos ressources avec précaution !</p>
-		</body>
-		</html>
-	`, teamName, credit)
-
-	return e.SendEmail(to, subject, body)
+// BuildMessage formats an email as multipart/alternative, so that mail clients
+// without HTML show the plain-text version
+func BuildMessage(from, to string, msg emails.Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", to)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+
+	// The preferred version comes last
+	alternatives := []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	}
+	for _, alt := range alternatives {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {alt.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(alt.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
 }
 
 func NormalizeEmail(email string) string {
@@ -1,23 +1,39 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// AccessTokenTTL is the lifetime of an access token. Clients get a new one from the
+// refresh token of their session.
+const AccessTokenTTL = 15 * time.Minute
+
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	UserType string `json:"user_type"` // "team" or "admin"
+	UserID    uint   `json:"user_id"`
+	UserType  string `json:"user_type"`      // "team" or "admin"
+	Role      string `json:"role,omitempty"` // Role of an admin, which decides the admin routes it can use
+	SessionID uint   `json:"sid"`            // Revoking the session rejects the token
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID uint, userType string, jwtSecret string) (string, error) {
+func GenerateToken(userID uint, userType, role string, sessionID uint, jwtSecret string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		UserID:   userID,
-		UserType: userType,
+		UserID:    userID,
+		UserType:  userType,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ID:        hex.EncodeToString(jti),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -30,7 +46,7 @@ func ValidateToken(tokenString string, jwtSecret string) (*Claims, error) {
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(jwtSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -1,9 +1,18 @@
 package utils
 
 import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+// passwordAlphabet leaves out characters that are easy to confuse (0/O, 1/l/I)
+const passwordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
 	return string(bytes), err
@@ -13,3 +22,56 @@ func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
+
+// GeneratePassword returns a random password of the given length
+func GeneratePassword(length int) (string, error) {
+	password := make([]byte, length)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// Password policy of the passwords chosen by teams and admins
+const (
+	MinPasswordLength = 10
+	MaxPasswordLength = 72 // bcrypt ignores the bytes beyond
+)
+
+// CheckPasswordPolicy returns why a chosen password is too weak, or an empty string
+// when it is acceptable. The password must not contain the names of the account.
+func CheckPasswordPolicy(password string, names ...string) string {
+	if len([]rune(password)) < MinPasswordLength {
+		return fmt.Sprintf("Password must be at least %d characters long", MinPasswordLength)
+	}
+	if len(password) > MaxPasswordLength {
+		return fmt.Sprintf("Password must be at most %d bytes long", MaxPasswordLength)
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return "Password must contain at least one letter and one digit"
+	}
+
+	lower := strings.ToLower(password)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) >= 3 && strings.Contains(lower, name) {
+			return "Password must not contain the account name"
+		}
+	}
+	return ""
+}